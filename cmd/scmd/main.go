@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"strings"
 
@@ -13,6 +12,7 @@ import (
 	"github.com/gcclinux/scmd/internal/cli"
 	"github.com/gcclinux/scmd/internal/config"
 	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/logging"
 	"github.com/gcclinux/scmd/internal/mcp"
 	"github.com/gcclinux/scmd/internal/mcpclient"
 	"github.com/gcclinux/scmd/internal/search"
@@ -47,6 +47,19 @@ func init() {
 		return json.Marshal(records)
 	}
 
+	database.MCPListPageFn = func(namespace string, limit, offset int) ([]byte, int, error) {
+		c, ok := database.MCPClient().(*mcpclient.Client)
+		if !ok {
+			return nil, 0, fmt.Errorf("MCP client not initialized")
+		}
+		records, total, err := c.ListDataPage(namespace, limit, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		data, err := json.Marshal(records)
+		return data, total, err
+	}
+
 	database.MCPStoreDataFn = func(key, content string, embedding []float64, metadata map[string]string) error {
 		c, ok := database.MCPClient().(*mcpclient.Client)
 		if !ok {
@@ -136,6 +149,13 @@ func main() {
 	// Warn the user when running inside snap confinement.
 	util.PrintSnapNotice()
 
+	applyGlobalFlags()
+
+	if err := logging.SetupFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	defer logging.CloseFiles()
+
 	// Log which storage backend is active
 	dbType := strings.ToLower(os.Getenv("DB_TYPE"))
 	if dbType == "" {
@@ -144,9 +164,27 @@ func main() {
 
 	switch dbType {
 	case "mcp":
-		log.Println("Storage backend: MCP")
+		logging.For(logging.CLI).Info("storage backend", "type", "mcp")
 	default:
-		log.Println("Storage backend: SQLite")
+		logging.For(logging.CLI).Info("storage backend", "type", "sqlite")
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "bulk" {
+		code := cli.RunBulk(os.Args[2:])
+		logging.CloseFiles()
+		os.Exit(code)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "stats" {
+		code := cli.RunStats(os.Args[2:])
+		logging.CloseFiles()
+		os.Exit(code)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "usage" {
+		code := cli.RunUsage(os.Args[2:])
+		logging.CloseFiles()
+		os.Exit(code)
 	}
 
 	msg, _, _ := updater.VersionRemote()
@@ -213,7 +251,10 @@ func main() {
 		}
 	} else if count == 3 {
 		if os.Args[1] == "--search" {
-			search.RunCLISearch(os.Args[2])
+			if err := search.RunCLISearch(os.Args[2]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
 		} else if os.Args[1] == "--web" && os.Args[2] == "-block" {
 			server.Routes()
 		} else if os.Args[1] == "--save" {
@@ -224,7 +265,10 @@ func main() {
 					fmt.Println("Error reading from stdin:", err)
 					return
 				}
-				cli.SaveCmd(string(stdin), os.Args[2])
+				if code := cli.SaveCmd(string(stdin), os.Args[2]); code != 0 {
+					logging.CloseFiles()
+					os.Exit(code)
+				}
 			} else {
 				fmt.Println("Usage: scmd --save [command] [description]")
 				fmt.Println("To save a large script/command: scmd --save [description] < script.sh")
@@ -238,13 +282,19 @@ func main() {
 		} else if os.Args[1] == "--ssl" {
 			server.Routes()
 		} else if os.Args[1] == "--save" {
-			cli.SaveCmd(os.Args[2], os.Args[3])
+			if code := cli.SaveCmd(os.Args[2], os.Args[3]); code != 0 {
+				logging.CloseFiles()
+				os.Exit(code)
+			}
 		} else {
 			cli.PrintWrongSyntax()
 		}
 	} else if count == 5 {
 		if os.Args[1] == "--save" {
-			cli.SaveCmd(os.Args[2], os.Args[3])
+			if code := cli.SaveCmd(os.Args[2], os.Args[3]); code != 0 {
+				logging.CloseFiles()
+				os.Exit(code)
+			}
 		} else {
 			server.Routes()
 		}
@@ -266,3 +316,34 @@ func main() {
 		cli.PrintWrongSyntax()
 	}
 }
+
+// applyGlobalFlags removes flags that may appear anywhere on the command line
+// from os.Args, exporting their values as environment variables, so the
+// positional argument handling below and in the server package is unchanged.
+// "scmd web ..." is accepted as an alias for "scmd --web ...".
+func applyGlobalFlags() {
+	args := []string{os.Args[0]}
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch {
+		case arg == "--tls" && i+1 < len(os.Args):
+			os.Setenv("TLS_MODE", os.Args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--tls="):
+			os.Setenv("TLS_MODE", strings.TrimPrefix(arg, "--tls="))
+		case arg == "--log-level" && i+1 < len(os.Args):
+			os.Setenv("LOG_LEVEL", os.Args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--log-level="):
+			os.Setenv("LOG_LEVEL", strings.TrimPrefix(arg, "--log-level="))
+		case arg == "--no-cache":
+			os.Setenv("AI_CACHE", "false")
+		default:
+			args = append(args, arg)
+		}
+	}
+	if len(args) > 1 && args[1] == "web" {
+		args[1] = "--web"
+	}
+	os.Args = args
+}
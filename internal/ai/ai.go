@@ -1,107 +1,101 @@
 package ai
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 
-	"github.com/gcclinux/scmd/internal/ai/gemini"
-	"github.com/gcclinux/scmd/internal/ai/ollama"
 	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/logging"
+	"github.com/gcclinux/scmd/internal/metrics"
 	"github.com/gcclinux/scmd/internal/search"
 	"github.com/gcclinux/scmd/internal/util"
 )
 
-// InitProviders initializes both AI providers and prints the active provider status.
+var logger = logging.For(logging.AI)
+
+// InitProviders initializes the registered AI providers and prints the
+// active provider status.
 func InitProviders() {
-	gemini.Init()
-	ollama.Init()
+	for _, p := range Providers() {
+		if i, ok := p.(initializer); ok {
+			i.Init()
+		}
+	}
 
-	preferred := strings.ToLower(os.Getenv("AGENT"))
 	embeddingDim := os.Getenv("EMBEDDING_DIM")
 	if embeddingDim == "" {
 		embeddingDim = "384"
 	}
 
-	switch preferred {
-	case "ollama":
-		if ollama.IsAvailable() {
-			fmt.Printf("✓ Ollama (host: %s, model: %s, embeddings: %s, dim: %s)\n",
-				ollama.Host(), ollama.ModelName(), ollama.EmbeddingModelName(), embeddingDim)
-		} else {
-			fmt.Println("⚠ Preferred agent 'ollama' is not available")
-		}
-	case "gemini":
-		if gemini.IsAvailable() {
-			fmt.Printf("✓ Gemini (chat: %s, embeddings: %s, dim: %s)\n",
-				gemini.ModelName(), gemini.EmbeddingModelName(), embeddingDim)
-		} else {
-			fmt.Println("⚠ Preferred agent 'gemini' is not available")
+	active := Active()
+	switch {
+	case active != nil:
+		models := active.Models()
+		attrs := []any{"provider", active.Name(), "model", models.Chat, "embeddings", models.Embedding, "dim", embeddingDim}
+		if h, ok := active.(interface{ Host() string }); ok {
+			attrs = append(attrs, "host", h.Host())
 		}
+		logger.Info("AI provider ready", attrs...)
+	case Preferred() != "":
+		logger.Warn("preferred AI provider is not available", "provider", Preferred())
 	default:
-		// No preference set — show whichever is available (Ollama first)
-		if ollama.IsAvailable() {
-			fmt.Printf("✓ Ollama (host: %s, model: %s, embeddings: %s, dim: %s)\n",
-				ollama.Host(), ollama.ModelName(), ollama.EmbeddingModelName(), embeddingDim)
-		} else if gemini.IsAvailable() {
-			fmt.Printf("✓ Gemini (chat: %s, embeddings: %s, dim: %s)\n",
-				gemini.ModelName(), gemini.EmbeddingModelName(), embeddingDim)
-		} else {
-			fmt.Println("⚠ No AI provider available")
-		}
+		logger.Warn("no AI provider available")
 	}
 }
 
-// GetBestEmbedding tries Ollama first, then Gemini to generate an embedding.
+// GetBestEmbedding generates an embedding with the first available provider
+// in priority order, falling back to the next one on failure.
 func GetBestEmbedding(text string) ([]float64, error) {
-	if ollama.IsAvailable() {
-		emb, err := ollama.GetEmbedding(text)
+	emb, _, err := embedWithFallback(text)
+	return emb, err
+}
+
+// embedWithFallback is GetBestEmbedding that also returns the provider used.
+func embedWithFallback(text string) ([]float64, Provider, error) {
+	var lastErr error
+	for _, p := range availableProviders() {
+		emb, err := cachedEmbed(p, text)
 		if err == nil {
-			return emb, nil
+			return emb, p, nil
 		}
+		lastErr = err
 	}
-	if gemini.IsAvailable() {
-		return gemini.GetEmbedding(text)
+	if lastErr != nil {
+		return nil, nil, lastErr
 	}
-	return nil, fmt.Errorf("no embedding provider available")
+	return nil, nil, fmt.Errorf("no embedding provider available")
 }
 
-// AskAI sends a question to the best available AI provider.
+// AskAI sends a question to the best available AI provider, falling back to
+// the next provider in the chain when one fails.
 // Returns (responseText, totalTokens, error).
 func AskAI(question string, context []database.CommandRecord) (string, int, error) {
 	util.StartSpinner()
 	defer util.StopSpinner()
+	return askChain(ChatRequest{Question: question, Context: context})
+}
 
-	var errs []error
-	preferredAgent := strings.ToLower(os.Getenv("AGENT"))
-
-	if preferredAgent == "ollama" && ollama.IsAvailable() {
-		return ollama.Ask(question, context)
-	} else if preferredAgent == "gemini" && gemini.IsAvailable() {
-		return gemini.Ask(question, context)
-	}
-
-	if preferredAgent != "" {
-		return "", 0, fmt.Errorf("preferred AI provider '%s' is not available or failed", preferredAgent)
+// askChain asks each provider in Chain until one answers.
+func askChain(req ChatRequest) (string, int, error) {
+	chain, err := Chain()
+	if err != nil {
+		return "", 0, err
 	}
-
-	if ollama.IsAvailable() {
-		response, tokens, err := ollama.Ask(question, context)
-		if err == nil {
-			return response, tokens, nil
-		}
-		errs = append(errs, fmt.Errorf("Ollama failed: %v", err))
+	if len(chain) == 1 && Preferred() != "" {
+		return cachedChat(context.Background(), chain[0], req, nil)
 	}
 
-	if gemini.IsAvailable() {
-		response, tokens, err := gemini.Ask(question, context)
+	var errs []error
+	for _, p := range chain {
+		response, tokens, err := cachedChat(context.Background(), p, req, nil)
 		if err == nil {
 			return response, tokens, nil
 		}
-		errs = append(errs, fmt.Errorf("Gemini failed: %v", err))
+		errs = append(errs, fmt.Errorf("%s failed: %v", p.Name(), err))
+		req = req.forFallback()
 	}
-
 	if len(errs) > 0 {
 		return "", 0, fmt.Errorf("all AI providers failed: %v", errs)
 	}
@@ -112,7 +106,9 @@ func AskAI(question string, context []database.CommandRecord) (string, int, erro
 // 1. PostgreSQL keyword search
 // 2. Vector search + AI chat
 // 3. Pure AI chat
-func SmartSearch(query string, useEmbeddings bool) ([]database.CommandRecord, string, int, error) {
+//
+// Matching commands whose IDs are in pinned rank above other matches.
+func SmartSearch(query string, useEmbeddings bool, pinned map[int]bool) ([]database.CommandRecord, string, int, error) {
 	cleanedQuery := search.ExtractKeywords(query)
 	if cleanedQuery == "" {
 		cleanedQuery = query
@@ -129,15 +125,16 @@ func SmartSearch(query string, useEmbeddings bool) ([]database.CommandRecord, st
 	}
 	var keywordResults []database.CommandRecord
 	json.Unmarshal(jsonData, &keywordResults)
-	scoredKeywords := search.ScoreCommands(keywordResults, cleanedQuery)
+	scoredKeywords := search.ScoreCommandsPinned(keywordResults, cleanedQuery, pinned)
 
 	if search.HasGoodMatches(scoredKeywords, 60) {
-		fmt.Println("✓ Found high-quality matches in database")
+		logger.Debug("found high-quality keyword matches", "query", cleanedQuery)
 		qualifiedScored := search.FilterByMinScore(scoredKeywords, 60)
 		bestScored := search.GetBestMatches(qualifiedScored, 10)
 		for _, s := range bestScored {
 			results = append(results, s.Record)
 		}
+		metrics.RecordSearchPath(metrics.PathKeyword)
 		return results, "", 0, nil
 	}
 
@@ -148,73 +145,20 @@ func SmartSearch(query string, useEmbeddings bool) ([]database.CommandRecord, st
 				results = append(results, s.Record)
 			}
 		}
+		metrics.RecordSearchPath(metrics.PathKeyword)
 		return results, "", 0, nil
 	}
 
-	preferredAgent := strings.ToLower(os.Getenv("AGENT"))
-
-	tryOllama := func() bool {
-		if !ollama.IsAvailable() {
-			return false
-		}
-		util.StartSpinner()
-		defer util.StopSpinner()
-		emb, err := ollama.GetEmbedding(query)
-		if err == nil {
-			vResults, err := database.SearchByVector(emb, 10)
-			if err == nil && len(vResults) > 0 {
-				scoredVector := search.ScoreCommands(vResults, cleanedQuery)
-				var filteredVector []database.CommandRecord
-				for _, s := range scoredVector {
-					if s.Score > 0 {
-						filteredVector = append(filteredVector, s.Record)
-					}
-				}
-				if len(filteredVector) > 0 {
-					results = filteredVector
-					res, tok, err := ollama.Ask(query, results)
-					if err == nil && res != "" {
-						aiResponse = res
-						aiTokens = tok
-						return true
-					} else if err != nil {
-						fmt.Printf("⚠ Ollama API error: %v\n", err)
-					}
-				}
-			}
-		}
-
-		if aiResponse == "" && len(scoredKeywords) > 0 {
-			var contextResults []database.CommandRecord
-			for _, s := range search.GetBestMatches(scoredKeywords, 5) {
-				if s.Score > 0 {
-					contextResults = append(contextResults, s.Record)
-				}
-			}
-			res, tok, err := ollama.Ask(query, contextResults)
-			if err == nil && res != "" {
-				results = contextResults
-				aiResponse = res
-				aiTokens = tok
-				return true
-			} else if err != nil {
-				fmt.Printf("⚠ Ollama API error: %v\n", err)
-			}
-		}
-		return false
-	}
+	path := metrics.PathFallback
 
-	tryGemini := func() bool {
-		if !gemini.IsAvailable() {
-			return false
-		}
+	tryProvider := func(p Provider) bool {
 		util.StartSpinner()
 		defer util.StopSpinner()
-		emb, err := gemini.GetEmbedding(query)
+		emb, err := cachedEmbed(p, query)
 		if err == nil {
 			vResults, err := database.SearchByVector(emb, 10)
 			if err == nil && len(vResults) > 0 {
-				scoredVector := search.ScoreCommands(vResults, cleanedQuery)
+				scoredVector := search.ScoreCommandsPinned(vResults, cleanedQuery, pinned)
 				var filteredVector []database.CommandRecord
 				for _, s := range scoredVector {
 					if s.Score > 0 {
@@ -223,13 +167,14 @@ func SmartSearch(query string, useEmbeddings bool) ([]database.CommandRecord, st
 				}
 				if len(filteredVector) > 0 {
 					results = filteredVector
-					res, tok, err := gemini.Ask(query, results)
+					res, tok, err := cachedChat(context.Background(), p, ChatRequest{Question: query, Context: results}, nil)
 					if err == nil && res != "" {
 						aiResponse = res
 						aiTokens = tok
+						path = metrics.PathVector
 						return true
 					} else if err != nil {
-						fmt.Printf("⚠ Gemini API error: %v\n", err)
+						logger.Warn("provider request failed", "provider", providerKey(p), "err", err)
 					}
 				}
 			}
@@ -242,44 +187,35 @@ func SmartSearch(query string, useEmbeddings bool) ([]database.CommandRecord, st
 					contextResults = append(contextResults, s.Record)
 				}
 			}
-			res, tok, err := gemini.Ask(query, contextResults)
+			res, tok, err := cachedChat(context.Background(), p, ChatRequest{Question: query, Context: contextResults}, nil)
 			if err == nil && res != "" {
 				results = contextResults
 				aiResponse = res
 				aiTokens = tok
+				path = metrics.PathAI
 				return true
 			} else if err != nil {
-				fmt.Printf("⚠ Gemini API error: %v\n", err)
+				logger.Warn("provider request failed", "provider", providerKey(p), "err", err)
 			}
 		}
 		return false
 	}
 
-	success := false
-	if preferredAgent == "ollama" {
-		success = tryOllama()
-	} else if preferredAgent == "gemini" {
-		success = tryGemini()
-	}
-
-	if success {
-		return results, aiResponse, aiTokens, nil
-	}
-
-	if preferredAgent == "" {
-		if tryOllama() {
-			return results, aiResponse, aiTokens, nil
-		}
-		if tryGemini() {
+	chain, _ := Chain()
+	for _, p := range chain {
+		if tryProvider(p) {
+			metrics.RecordSearchPath(path)
 			return results, aiResponse, aiTokens, nil
 		}
 	}
 
+	metrics.RecordSearchPath(metrics.PathFallback)
+
 	// Last resort: AI chat with no context
 	if aiResponse == "" {
 		resp, tok, err := AskAI(query, nil)
 		if err != nil {
-			fmt.Printf("⚠ AskAI Error: %v\n", err)
+			logger.Error("AI fallback failed", "err", err)
 			aiResponse = fmt.Sprintf("⚠️ **AI Provider Error**\n\n```text\n%v\n```\n\nPlease check your configuration, model name, and API keys.", err)
 		} else {
 			aiResponse = resp
@@ -288,7 +224,7 @@ func SmartSearch(query string, useEmbeddings bool) ([]database.CommandRecord, st
 	}
 
 	finalResults := []database.CommandRecord{}
-	scoredFinal := search.ScoreCommands(results, cleanedQuery)
+	scoredFinal := search.ScoreCommandsPinned(results, cleanedQuery, pinned)
 	for _, s := range scoredFinal {
 		if s.Score > 0 {
 			finalResults = append(finalResults, s.Record)
@@ -300,19 +236,8 @@ func SmartSearch(query string, useEmbeddings bool) ([]database.CommandRecord, st
 
 // GetProviderLabel returns a label describing the active AI provider.
 func GetProviderLabel() string {
-	preferredAgent := strings.ToLower(os.Getenv("AGENT"))
-
-	if preferredAgent == "ollama" && ollama.IsAvailable() {
-		return fmt.Sprintf("Ollama (%s)", ollama.ModelName())
-	} else if preferredAgent == "gemini" && gemini.IsAvailable() {
-		return fmt.Sprintf("Gemini (%s)", gemini.ModelName())
-	}
-
-	if ollama.IsAvailable() {
-		return fmt.Sprintf("Ollama (%s)", ollama.ModelName())
-	}
-	if gemini.IsAvailable() {
-		return fmt.Sprintf("Gemini (%s)", gemini.ModelName())
+	if p := Active(); p != nil {
+		return fmt.Sprintf("%s (%s)", p.Name(), p.Models().Chat)
 	}
 	return "None"
 }
@@ -1,22 +1,168 @@
 package ai
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 
+	"github.com/gcclinux/scmd/internal/ai/chat"
+	"github.com/gcclinux/scmd/internal/config"
 	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/prompts"
+	"github.com/gcclinux/scmd/internal/util"
+	"gopkg.in/yaml.v3"
 )
 
-// AIPersona represents a specific AI personality and focus.
+// AIPersona represents a specific AI personality and focus. Every persona
+// is available as a slash command named after its key, e.g. /ubuntu.
 type AIPersona struct {
-	Name        string
-	Description string
-	SystemPrompt string
+	Key          string   `json:"-" yaml:"-"`
+	Name         string   `json:"name" yaml:"name"`
+	Description  string   `json:"description" yaml:"description"`
+	SystemPrompt string   `json:"system_prompt" yaml:"system_prompt"`
+	Model        string   `json:"model,omitempty" yaml:"model,omitempty"`             // preferred chat model
+	Temperature  *float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"` // nil for the model default
+	Tags         []string `json:"tags,omitempty" yaml:"tags,omitempty"`               // limit context records to these tags
 }
 
-// GetPersonas returns the map of available AI personas.
+// personaFiles are the files in ~/.scmd that define personas; the first
+// one that exists is used.
+var personaFiles = []string{"personas.yaml", "personas.yml", "personas.json"}
+
+var personaKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ReservedPersonaKeys are the interactive mode's own slash commands, which
+// would shadow a persona with the same key. Keep it in step with
+// handleSlashCommand in internal/cli.
+var ReservedPersonaKeys = []string{
+	"help", "exit", "quit", "q", "clear", "cls", "search", "add", "delete",
+	"list", "trash", "restore", "purge", "pin", "unpin", "pins", "history",
+	"revert", "new", "context", "save", "env", "prompt", "ai", "config",
+	"embeddings", "generate", "show", "run",
+}
+
+// PersonasPath returns the personas file in use, or "" when there is none.
+func PersonasPath() string {
+	for _, name := range personaFiles {
+		path := filepath.Join(config.ConfigDir(), name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadPersonas returns the built-in personas merged with the personas
+// file, which maps keys to personas; an entry replaces the built-in with
+// the same key. Invalid entries are skipped and reported in the error,
+// which is also returned with the built-ins when the file cannot be read.
+func LoadPersonas() (map[string]AIPersona, error) {
+	personas := builtinPersonas()
+	path := PersonasPath()
+	if path == "" {
+		return personas, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return personas, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	var defined map[string]AIPersona
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &defined)
+	} else {
+		err = yaml.Unmarshal(data, &defined)
+	}
+	if err != nil {
+		return personas, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	var errs []error
+	for key, p := range defined {
+		key = strings.ToLower(strings.TrimSpace(key))
+		switch {
+		case !personaKeyPattern.MatchString(key):
+			errs = append(errs, fmt.Errorf("persona '%s': key must be lower-case letters, digits, '-' or '_'", key))
+			continue
+		case slices.Contains(ReservedPersonaKeys, key):
+			errs = append(errs, fmt.Errorf("persona '%s': key is reserved for the /%s command", key, key))
+			continue
+		case strings.TrimSpace(p.SystemPrompt) == "":
+			errs = append(errs, fmt.Errorf("persona '%s': system_prompt is required", key))
+			continue
+		case p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2):
+			errs = append(errs, fmt.Errorf("persona '%s': temperature must be between 0 and 2", key))
+			continue
+		}
+		p.Key = key
+		if p.Name == "" {
+			p.Name = key
+		}
+		personas[key] = p
+	}
+	if len(errs) > 0 {
+		return personas, fmt.Errorf("%s: %v", path, errors.Join(errs...))
+	}
+	return personas, nil
+}
+
+// GetPersonas returns the available AI personas by key. Problems with the
+// personas file are logged and the valid personas are still returned.
 func GetPersonas() map[string]AIPersona {
-	return map[string]AIPersona{
+	personas, err := LoadPersonas()
+	if err != nil {
+		logger.Warn("loading personas", "err", err)
+	}
+	return personas
+}
+
+// PersonaList returns the available personas sorted by key.
+func PersonaList() []AIPersona {
+	var list []AIPersona
+	for _, p := range GetPersonas() {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// LookupPersona returns the persona with the given key, ignoring case.
+func LookupPersona(key string) (AIPersona, bool) {
+	p, ok := GetPersonas()[strings.ToLower(strings.TrimSpace(key))]
+	return p, ok
+}
+
+// Options returns the chat options the persona asks for.
+func (p AIPersona) Options() chat.Options {
+	return chat.Options{Model: p.Model, Temperature: p.Temperature}
+}
+
+// Matches reports whether record carries one of the persona's tags. Every
+// record matches a persona without tags.
+func (p AIPersona) Matches(record database.CommandRecord) bool {
+	if len(p.Tags) == 0 {
+		return true
+	}
+	for _, want := range p.Tags {
+		for _, tag := range record.Tags {
+			if strings.EqualFold(tag, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// builtinPersonas returns the personas available without a personas file.
+func builtinPersonas() map[string]AIPersona {
+	personas := map[string]AIPersona{
 		"ubuntu": {
 			Name:        "Ubuntu Expert",
 			Description: "Fully focused on commands, patches, administration, and fixes for Ubuntu.",
@@ -84,23 +230,67 @@ When providing help:
 4. Format all commands in bash code blocks.`,
 		},
 	}
+	for key, p := range personas {
+		p.Key = key
+		personas[key] = p
+	}
+	return personas
+}
+
+// PersonaContext returns the stored commands sent as context with a
+// question asked through persona: the records SmartSearchStream would use,
+// limited to the persona's tags when it has any.
+func PersonaContext(persona AIPersona, query string, pinned map[int]bool) ([]database.CommandRecord, error) {
+	cleanedQuery, scoredKeywords, err := keywordMatches(query, pinned)
+	if err != nil {
+		return nil, err
+	}
+	results, _ := contextRecords(query, cleanedQuery, scoredKeywords, pinned)
+
+	var matching []database.CommandRecord
+	for _, r := range results {
+		if persona.Matches(r) {
+			matching = append(matching, r)
+		}
+	}
+	return matching, nil
 }
 
 // AskAIPersona sends a question to the AI using a specific persona.
-func AskAIPersona(personaKey string, question string, context []database.CommandRecord) (string, int, error) {
-	personas := GetPersonas()
-	persona, ok := personas[strings.ToLower(personaKey)]
+func AskAIPersona(personaKey string, question string, records []database.CommandRecord) (string, int, error) {
+	req, err := personaRequest(context.Background(), personaKey, question, records)
+	if err != nil {
+		return "", 0, err
+	}
+	util.StartSpinner()
+	defer util.StopSpinner()
+	return askChain(req)
+}
+
+// AskAIPersonaStream is the streaming counterpart of AskAIPersona; see
+// AskAIStream.
+func AskAIPersonaStream(ctx context.Context, personaKey string, question string, records []database.CommandRecord, onToken func(string)) (string, int, error) {
+	req, err := personaRequest(ctx, personaKey, question, records)
+	if err != nil {
+		return "", 0, err
+	}
+	return streamChain(ctx, req, onToken)
+}
+
+// personaRequest builds the chat request for question asked through the
+// persona. The persona template, rendered with the persona's instructions,
+// is sent as the system prompt in place of the default one.
+func personaRequest(ctx context.Context, personaKey string, question string, records []database.CommandRecord) (ChatRequest, error) {
+	persona, ok := LookupPersona(personaKey)
 	if !ok {
-		return "", 0, fmt.Errorf("persona '%s' not found", personaKey)
-	}
-
-	// We can prefix the system prompt to the query for now, 
-	// or better, if we update AskAI to support system prompts.
-	// For now, let's just combine them to avoid breaking the AskAI signature if we don't want to change it yet.
-	// But it's better to change AskAI if possible.
-	
-	pagedQuestion := fmt.Sprintf("PERSONA: %s\n\nINSTRUCTIONS: %s\n\nUSER QUESTION: %s", 
-		persona.Name, persona.SystemPrompt, question)
-	
-	return AskAI(pagedQuestion, context)
+		return ChatRequest{}, fmt.Errorf("persona '%s' not found", personaKey)
+	}
+
+	system := prompts.Render(prompts.PersonaTemplate, prompts.Data{
+		Query:   question,
+		Context: records,
+		Persona: prompts.Persona{Key: persona.Key, Name: persona.Name, Instructions: persona.SystemPrompt},
+		OS:      systemFor(ctx),
+	})
+	return ChatRequest{Question: question, Context: records, System: system, Options: persona.Options()}, nil
 }
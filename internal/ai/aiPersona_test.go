@@ -0,0 +1,167 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+// writePersonas writes a personas file to a fresh ~/.scmd.
+func writePersonas(t *testing.T, name, content string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), ".scmd")
+	t.Setenv("HOME", filepath.Dir(dir))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPersonas_Builtin(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	personas, err := LoadPersonas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(personas) != 6 || personas["ubuntu"].Key != "ubuntu" {
+		t.Errorf("got %d personas, ubuntu %+v", len(personas), personas["ubuntu"])
+	}
+	if PersonasPath() != "" {
+		t.Errorf("PersonasPath = %q without a file", PersonasPath())
+	}
+}
+
+func TestLoadPersonas_YAML(t *testing.T) {
+	writePersonas(t, "personas.yaml", `
+k8s:
+  name: Kubernetes Operator
+  description: kubectl and helm
+  system_prompt: You run Kubernetes clusters.
+  model: llama3.1:70b
+  temperature: 0.2
+  tags: [kubernetes, helm]
+Ubuntu:
+  system_prompt: Only answer with snap commands.
+`)
+	personas, err := LoadPersonas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k8s := personas["k8s"]
+	if k8s.Key != "k8s" || k8s.Name != "Kubernetes Operator" || k8s.Model != "llama3.1:70b" ||
+		k8s.Temperature == nil || *k8s.Temperature != 0.2 || len(k8s.Tags) != 2 {
+		t.Errorf("k8s = %+v", k8s)
+	}
+	if ubuntu := personas["ubuntu"]; ubuntu.SystemPrompt != "Only answer with snap commands." || ubuntu.Name != "ubuntu" {
+		t.Errorf("the file should replace the built-in ubuntu persona, got %+v", ubuntu)
+	}
+	if len(personas) != 7 {
+		t.Errorf("got %d personas, want 7", len(personas))
+	}
+
+	list := PersonaList()
+	if list[0].Key != "archlinux" || list[len(list)-1].Key != "windows" {
+		t.Errorf("PersonaList is not sorted: %s ... %s", list[0].Key, list[len(list)-1].Key)
+	}
+	if p, ok := LookupPersona(" K8S "); !ok || p.Key != "k8s" {
+		t.Errorf("LookupPersona = %+v, %v", p, ok)
+	}
+}
+
+func TestLoadPersonas_JSON(t *testing.T) {
+	writePersonas(t, "personas.json", `{"sre": {"name": "SRE", "system_prompt": "You keep services up."}}`)
+	if p, ok := LookupPersona("sre"); !ok || p.Name != "SRE" || p.Temperature != nil {
+		t.Errorf("LookupPersona = %+v, %v", p, ok)
+	}
+}
+
+func TestLoadPersonas_Invalid(t *testing.T) {
+	writePersonas(t, "personas.yaml", `
+"bad key":
+  system_prompt: x
+empty:
+  name: Empty
+hot:
+  system_prompt: x
+  temperature: 3
+save:
+  system_prompt: x
+good:
+  system_prompt: x
+`)
+	personas, err := LoadPersonas()
+	if err == nil {
+		t.Fatal("expected an error for the invalid personas")
+	}
+	for _, want := range []string{"bad key", "system_prompt is required", "temperature must be between 0 and 2", "persona 'save': key is reserved"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if _, ok := personas["save"]; ok {
+		t.Error("a persona shadowing /save was loaded")
+	}
+	if _, ok := personas["good"]; !ok || len(personas) != 7 {
+		t.Errorf("valid personas should still load, got %d", len(personas))
+	}
+
+	writePersonas(t, "personas.yaml", "k8s: [not, a, persona]")
+	if personas, err := LoadPersonas(); err == nil || len(personas) != 6 {
+		t.Errorf("unparsable file: %d personas, err %v", len(personas), err)
+	}
+}
+
+func TestPersonaMatches(t *testing.T) {
+	record := database.CommandRecord{Tags: []string{"Docker", "network"}}
+	if !(AIPersona{}).Matches(record) {
+		t.Error("a persona without tags should match every record")
+	}
+	if !(AIPersona{Tags: []string{"docker"}}).Matches(record) {
+		t.Error("tags should match ignoring case")
+	}
+	if (AIPersona{Tags: []string{"kubernetes"}}).Matches(record) {
+		t.Error("a record without the persona's tags should not match")
+	}
+}
+
+func TestAskAIPersonaStream_Options(t *testing.T) {
+	writePersonas(t, "personas.yaml", `
+k8s:
+  name: Kubernetes Operator
+  system_prompt: You run Kubernetes clusters.
+  model: big-model
+  temperature: 0.3
+`)
+	primary := &fakeProvider{name: "ollama", available: true, err: errors.New("down")}
+	fallback := &fakeProvider{name: "gemini", available: true, answer: "kubectl get pods"}
+	useProviders(t, primary, fallback)
+
+	answer, _, err := AskAIPersonaStream(context.Background(), "k8s", "list pods", nil, nil)
+	if err != nil || answer != "kubectl get pods" {
+		t.Fatalf("AskAIPersonaStream = %q, %v", answer, err)
+	}
+	if got := primary.last.Options; got.Model != "big-model" || got.Temperature == nil || *got.Temperature != 0.3 {
+		t.Errorf("first provider got options %+v", got)
+	}
+	if got := fallback.last.Options; got.Model != "" || got.Temperature == nil || *got.Temperature != 0.3 {
+		t.Errorf("fallback should keep the temperature but not the model, got %+v", got)
+	}
+	system, messages := fallback.last.Messages()
+	if !strings.HasPrefix(system, "You run Kubernetes clusters.") {
+		t.Errorf("system prompt = %q, want the persona instructions", system)
+	}
+	if len(messages) != 1 || strings.Contains(messages[0].Content, "Kubernetes") || !strings.HasSuffix(messages[0].Content, "list pods") {
+		t.Errorf("the user message should hold only the question, got %+v", messages)
+	}
+
+	if _, _, err := AskAIPersonaStream(context.Background(), "nope", "q", nil, nil); err == nil {
+		t.Error("an unknown persona should fail")
+	}
+}
@@ -0,0 +1,174 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/config"
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/logging"
+	"github.com/gcclinux/scmd/internal/metrics"
+)
+
+// Cache defaults, used when ai_cache_ttl or ai_cache_max_size are unset or
+// invalid.
+const (
+	DefaultCacheTTL     = 7 * 24 * time.Hour
+	DefaultCacheMaxSize = 50 << 20
+)
+
+// CacheEnabled reports whether AI answers and embeddings are cached
+// (ai_cache, default true; the --no-cache flag turns it off).
+func CacheEnabled() bool {
+	return config.GetBool("AI_CACHE", true)
+}
+
+// CacheTTL returns how long a cached answer stays valid (ai_cache_ttl).
+func CacheTTL() time.Duration {
+	return config.ParseDuration(os.Getenv("AI_CACHE_TTL"), DefaultCacheTTL)
+}
+
+// CacheMaxSize returns the size in bytes the cache is trimmed to
+// (ai_cache_max_size, e.g. "50MB").
+func CacheMaxSize() int64 {
+	if n, err := logging.ParseSize(os.Getenv("AI_CACHE_MAX_SIZE")); err == nil && n > 0 {
+		return n
+	}
+	return DefaultCacheMaxSize
+}
+
+type bypassKey struct{}
+
+// BypassCache returns a context whose chat requests skip cached answers.
+// The fresh answer still replaces the cached one, so regenerating an answer
+// also refreshes the cache.
+func BypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	b, _ := ctx.Value(bypassKey{}).(bool)
+	return b
+}
+
+// cachedChat answers req with p, using the cache when it is enabled. A
+// cached answer is passed to onToken in one piece and reports no tokens,
+// since the provider was not called. Failed, empty and cancelled answers
+// are not cached.
+func cachedChat(ctx context.Context, p Provider, req ChatRequest, onToken func(string)) (string, int, error) {
+	req.OS = systemFor(ctx)
+	model := req.Options.ModelOr(p.Models().Chat)
+	if !CacheEnabled() {
+		return meteredChat(ctx, p, model, req, onToken)
+	}
+	key := chatCacheKey(providerKey(p), model, req)
+	if !bypassed(ctx) {
+		if e := cacheGet(database.CacheChat, key); e != nil {
+			if onToken != nil {
+				onToken(e.Value)
+			}
+			return e.Value, 0, nil
+		}
+	}
+
+	response, tokens, err := meteredChat(ctx, p, model, req, onToken)
+	if err == nil && ctx.Err() == nil && strings.TrimSpace(response) != "" {
+		cachePut(database.AICacheEntry{
+			Key: key, Kind: database.CacheChat, Provider: providerKey(p), Model: model,
+			Value: response, Tokens: tokens,
+		})
+	}
+	return response, tokens, err
+}
+
+// cachedEmbed returns the embedding of text from p, using the cache when
+// it is enabled.
+func cachedEmbed(p Provider, text string) ([]float64, error) {
+	if !CacheEnabled() {
+		return p.Embed(text)
+	}
+	model := p.Models().Embedding
+	h := sha256.New()
+	writeFields(h, database.CacheEmbed, providerKey(p), model, text)
+	key := hex.EncodeToString(h.Sum(nil))
+
+	if e := cacheGet(database.CacheEmbed, key); e != nil {
+		var emb []float64
+		if err := json.Unmarshal([]byte(e.Value), &emb); err == nil && len(emb) > 0 {
+			return emb, nil
+		}
+	}
+
+	emb, err := p.Embed(text)
+	if err == nil && len(emb) > 0 {
+		if data, err := json.Marshal(emb); err == nil {
+			cachePut(database.AICacheEntry{
+				Key: key, Kind: database.CacheEmbed, Provider: providerKey(p), Model: model,
+				Value: string(data),
+			})
+		}
+	}
+	return emb, err
+}
+
+// chatCacheKey hashes everything that shapes an answer: the provider and
+// model, the temperature, the rendered system prompt and messages, and the
+// IDs of the context records.
+func chatCacheKey(provider, model string, req ChatRequest) string {
+	temperature := ""
+	if t := req.Options.Temperature; t != nil {
+		temperature = fmt.Sprint(*t)
+	}
+	ids := make([]int, 0, len(req.Context))
+	for _, r := range req.Context {
+		ids = append(ids, r.Id)
+	}
+	sort.Ints(ids)
+
+	system, messages := req.Messages()
+	h := sha256.New()
+	writeFields(h, database.CacheChat, provider, model, temperature, fmt.Sprint(ids), system)
+	for _, m := range messages {
+		writeFields(h, m.Role, m.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeFields writes each field to h followed by a separator, so that
+// different splits of the same text hash differently.
+func writeFields(h hash.Hash, fields ...string) {
+	for _, f := range fields {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+	}
+}
+
+// cacheGet looks key up and counts the hit or miss. Backends without a
+// cache are not counted.
+func cacheGet(kind, key string) *database.AICacheEntry {
+	e, err := database.GetAICache(key, CacheTTL())
+	if errors.Is(err, database.ErrNoAICache) {
+		return nil
+	}
+	if err != nil {
+		logger.Debug("AI cache lookup failed", "kind", kind, "err", err)
+	}
+	metrics.RecordCache(kind, e != nil)
+	return e
+}
+
+func cachePut(e database.AICacheEntry) {
+	err := database.PutAICache(e, CacheTTL(), CacheMaxSize())
+	if err != nil && !errors.Is(err, database.ErrNoAICache) {
+		logger.Debug("AI cache store failed", "kind", e.Kind, "err", err)
+	}
+}
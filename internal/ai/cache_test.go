@@ -0,0 +1,94 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/metrics"
+)
+
+func TestCachedChat(t *testing.T) {
+	setupTestDB(t)
+	p := &fakeProvider{name: "ollama", available: true, answer: "docker ps"}
+	useProviders(t, p)
+	ctx := context.Background()
+	hits := metrics.AICache.Value(database.CacheChat, "hit")
+
+	if answer, tokens, err := AskAIStream(ctx, "list containers", nil, nil); err != nil || answer != "docker ps" || tokens == 0 {
+		t.Fatalf("first answer = %q, %d, %v", answer, tokens, err)
+	}
+	var streamed string
+	answer, tokens, err := AskAIStream(ctx, "list containers", nil, func(tok string) { streamed += tok })
+	if err != nil || answer != "docker ps" || streamed != "docker ps" || tokens != 0 {
+		t.Errorf("cached answer = %q (streamed %q), %d tokens, %v", answer, streamed, tokens, err)
+	}
+	if p.calls != 1 {
+		t.Errorf("provider called %d times, want 1", p.calls)
+	}
+	if got := metrics.AICache.Value(database.CacheChat, "hit") - hits; got != 1 {
+		t.Errorf("counted %v cache hits, want 1", got)
+	}
+
+	records := []database.CommandRecord{{Id: 7, Key: "docker ps -a", Data: "all containers"}}
+	for _, ask := range []func() error{
+		func() error { _, _, err := AskAIStream(ctx, "list images", nil, nil); return err },
+		func() error { _, _, err := AskAIStream(ctx, "list containers", records, nil); return err },
+		func() error { _, _, err := AskAIStream(BypassCache(ctx), "list containers", nil, nil); return err },
+	} {
+		calls := p.calls
+		if err := ask(); err != nil || p.calls != calls+1 {
+			t.Errorf("expected a provider call, got %d, err %v", p.calls-calls, err)
+		}
+	}
+
+	t.Setenv("AI_CACHE", "false")
+	if _, _, err := AskAIStream(ctx, "list containers", nil, nil); err != nil || p.calls != 5 {
+		t.Errorf("with the cache off the provider should be asked, calls %d, err %v", p.calls, err)
+	}
+}
+
+func TestCachedChat_SkipsFailures(t *testing.T) {
+	setupTestDB(t)
+	p := &fakeProvider{name: "ollama", available: true, err: errors.New("down")}
+	useProviders(t, p)
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := AskAIStream(context.Background(), "list containers", nil, nil); err == nil {
+			t.Fatal("expected the provider error")
+		}
+	}
+	if p.calls != 2 {
+		t.Errorf("failed answers should not be cached, provider called %d times", p.calls)
+	}
+}
+
+func TestCachedEmbed(t *testing.T) {
+	setupTestDB(t)
+	p := &countingEmbedder{fakeProvider: fakeProvider{name: "gemini", available: true}}
+	useProviders(t, p)
+
+	for i := 0; i < 2; i++ {
+		emb, err := GetBestEmbedding("docker ps")
+		if err != nil || len(emb) != 1 || emb[0] != 6 {
+			t.Fatalf("GetBestEmbedding = %v, %v", emb, err)
+		}
+	}
+	if p.embeds != 1 {
+		t.Errorf("provider embedded %d times, want 1", p.embeds)
+	}
+	if stats, err := database.AICacheStatus(); err != nil || stats.Entries != 1 || stats.Hits != 1 {
+		t.Errorf("AICacheStatus = %+v, %v", stats, err)
+	}
+}
+
+type countingEmbedder struct {
+	fakeProvider
+	embeds int
+}
+
+func (c *countingEmbedder) Embed(text string) ([]float64, error) {
+	c.embeds++
+	return c.fakeProvider.Embed(text)
+}
@@ -0,0 +1,40 @@
+// Package chat holds the request types shared by the AI provider packages.
+package chat
+
+// Options tunes a single chat request. Zero values keep the provider's
+// configured model and the model's default sampling.
+type Options struct {
+	Model       string   // chat model to use instead of the configured one
+	Temperature *float64 // sampling temperature; nil for the model default
+}
+
+// ModelOr returns the requested model, or fallback when none was set.
+func (o Options) ModelOr(fallback string) string {
+	if o.Model != "" {
+		return o.Model
+	}
+	return fallback
+}
+
+// Message roles. The system prompt is passed separately from the messages.
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one message of a conversation sent to a provider.
+type Message struct {
+	Role    string // RoleUser or RoleAssistant
+	Content string
+}
+
+// Usage is the token count a provider reported for one answer.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Total returns the prompt and completion tokens together.
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
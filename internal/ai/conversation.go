@@ -0,0 +1,128 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/metrics"
+)
+
+// DefaultConversationTokens is the default token budget for the earlier
+// turns sent with a follow-up question.
+const DefaultConversationTokens = 2000
+
+// Turn is one question and its answer in a conversation.
+type Turn struct {
+	Question string
+	Answer   string
+}
+
+// Conversation holds the turns of an interactive session so that follow-up
+// questions such as "now do the same for podman" can refer to earlier
+// answers. All turns are kept; only the most recent ones that fit in the
+// token budget are sent to the provider.
+type Conversation struct {
+	Turns []Turn
+}
+
+// Len returns the number of turns.
+func (c *Conversation) Len() int {
+	return len(c.Turns)
+}
+
+// Add appends a turn.
+func (c *Conversation) Add(question, answer string) {
+	c.Turns = append(c.Turns, Turn{Question: question, Answer: answer})
+}
+
+// DropLast removes the most recent turn, e.g. before regenerating its
+// answer, and reports whether there was one.
+func (c *Conversation) DropLast() bool {
+	if len(c.Turns) == 0 {
+		return false
+	}
+	c.Turns = c.Turns[:len(c.Turns)-1]
+	return true
+}
+
+// Reset forgets all turns.
+func (c *Conversation) Reset() {
+	c.Turns = nil
+}
+
+// Recent returns the most recent turns whose estimated size fits in
+// maxTokens, oldest first.
+func (c *Conversation) Recent(maxTokens int) []Turn {
+	used := 0
+	start := len(c.Turns)
+	for start > 0 {
+		n := c.Turns[start-1].Tokens()
+		if used+n > maxTokens {
+			break
+		}
+		used += n
+		start--
+	}
+	return c.Turns[start:]
+}
+
+// Markdown formats the conversation as a markdown document with one section
+// per question, for saving it as a record.
+func (c *Conversation) Markdown() string {
+	var b strings.Builder
+	for i, t := range c.Turns {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "## %s\n\n%s", t.Question, strings.TrimSpace(t.Answer))
+	}
+	return b.String()
+}
+
+// Tokens returns the estimated token count of the turn.
+func (t Turn) Tokens() int {
+	return EstimateTokens(t.Question) + EstimateTokens(t.Answer)
+}
+
+// EstimateTokens estimates the token count of text at four characters per
+// token, which is close enough for budgeting English text and code.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// ConversationBudget returns the token budget for earlier turns from
+// CONVERSATION_MAX_TOKENS, or DefaultConversationTokens when it is unset or
+// invalid.
+func ConversationBudget() int {
+	if n, err := strconv.Atoi(os.Getenv("CONVERSATION_MAX_TOKENS")); err == nil && n >= 0 {
+		return n
+	}
+	return DefaultConversationTokens
+}
+
+// ConverseStream answers query as the next turn of conv, streaming the
+// answer through onToken. Relevant commands are looked up as context like
+// in SmartSearchStream, but the AI is always asked, with the most recent
+// turns that fit in ConversationBudget. On success the turn is added to
+// conv.
+func ConverseStream(ctx context.Context, conv *Conversation, query string, pinned map[int]bool, onToken func(string)) ([]database.CommandRecord, string, int, error) {
+	cleanedQuery, scoredKeywords, err := keywordMatches(query, pinned)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	results, path := contextRecords(query, cleanedQuery, scoredKeywords, pinned)
+	metrics.RecordSearchPath(path)
+
+	req := ChatRequest{Question: query, Context: results, History: conv.Recent(ConversationBudget())}
+	response, tokens, err := streamChain(ctx, req, onToken)
+	if err != nil {
+		return results, response, tokens, err
+	}
+	conv.Add(query, response)
+	return results, response, tokens, nil
+}
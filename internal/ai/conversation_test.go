@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/ai/chat"
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DB_TYPE", "sqlite")
+	if err := os.MkdirAll(filepath.Join(home, ".scmd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InitSQLiteDB(); err != nil {
+		t.Fatalf("InitSQLiteDB: %v", err)
+	}
+	t.Cleanup(database.CloseDB)
+}
+
+func TestConversationRecent(t *testing.T) {
+	var c Conversation
+	c.Add("first", strings.Repeat("a", 400))  // ~102 tokens
+	c.Add("second", strings.Repeat("b", 200)) // ~52 tokens
+	c.Add("third", "short")                   // ~4 tokens
+
+	if got := c.Recent(60); len(got) != 2 || got[0].Question != "second" {
+		t.Errorf("Recent(60) = %+v, want the last two turns", got)
+	}
+	if got := c.Recent(1000); len(got) != 3 {
+		t.Errorf("Recent(1000) returned %d turns, want 3", len(got))
+	}
+	if got := c.Recent(0); len(got) != 0 {
+		t.Errorf("Recent(0) returned %d turns", len(got))
+	}
+
+	if !c.DropLast() || c.Len() != 2 {
+		t.Errorf("DropLast left %d turns", c.Len())
+	}
+	c.Reset()
+	if c.DropLast() || c.Len() != 0 {
+		t.Error("Reset should leave an empty conversation")
+	}
+}
+
+func TestConversationMarkdown(t *testing.T) {
+	var c Conversation
+	c.Add("list containers", "Use `docker ps`.\n")
+	c.Add("now for podman", "Use `podman ps`.")
+
+	want := "## list containers\n\nUse `docker ps`.\n\n## now for podman\n\nUse `podman ps`."
+	if got := c.Markdown(); got != want {
+		t.Errorf("Markdown =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestConversationBudget(t *testing.T) {
+	t.Setenv("CONVERSATION_MAX_TOKENS", "")
+	if got := ConversationBudget(); got != DefaultConversationTokens {
+		t.Errorf("default budget = %d", got)
+	}
+	t.Setenv("CONVERSATION_MAX_TOKENS", "500")
+	if got := ConversationBudget(); got != 500 {
+		t.Errorf("budget = %d, want 500", got)
+	}
+	t.Setenv("CONVERSATION_MAX_TOKENS", "lots")
+	if got := ConversationBudget(); got != DefaultConversationTokens {
+		t.Errorf("invalid budget = %d, want the default", got)
+	}
+}
+
+func TestConverseStream(t *testing.T) {
+	setupTestDB(t)
+	p := &fakeProvider{name: "Fake", available: true, answer: "Use `podman ps`."}
+	useProviders(t, p)
+	t.Setenv("CONVERSATION_MAX_TOKENS", "")
+
+	var c Conversation
+	c.Add("list running docker containers", "Use `docker ps`.")
+	_, answer, _, err := ConverseStream(context.Background(), &c, "now do the same for podman", nil, nil)
+	if err != nil || answer != "Use `podman ps`." {
+		t.Fatalf("ConverseStream = %q, %v", answer, err)
+	}
+	if len(p.last.History) != 1 || p.last.Question != "now do the same for podman" {
+		t.Errorf("request = %+v, want the earlier turn as history", p.last)
+	}
+	if _, messages := p.last.Messages(); len(messages) != 3 || messages[1].Role != chat.RoleAssistant ||
+		messages[1].Content != "Use `docker ps`." || !strings.HasSuffix(messages[2].Content, "now do the same for podman") {
+		t.Errorf("messages = %+v", messages)
+	}
+	if c.Len() != 2 || c.Turns[1].Answer != answer {
+		t.Errorf("conversation has %d turns, want the new one added", c.Len())
+	}
+
+	p.err = errors.New("down")
+	if _, _, _, err := ConverseStream(context.Background(), &c, "and for nerdctl", nil, nil); err == nil || c.Len() != 2 {
+		t.Errorf("failed turn: err %v, %d turns", err, c.Len())
+	}
+}
@@ -2,11 +2,8 @@ package ai
 
 import (
 	"fmt"
-	"log"
 	"time"
 
-	"github.com/gcclinux/scmd/internal/ai/gemini"
-	"github.com/gcclinux/scmd/internal/ai/ollama"
 	"github.com/gcclinux/scmd/internal/database"
 )
 
@@ -17,7 +14,7 @@ func GenerateEmbeddingsForAll() error {
 	fmt.Println("This may take a few minutes depending on the number of commands.")
 	fmt.Println()
 
-	if !gemini.IsAvailable() && !ollama.IsAvailable() {
+	if len(availableProviders()) == 0 {
 		return fmt.Errorf("no embedding provider available (need Gemini API or Ollama)")
 	}
 
@@ -36,53 +33,31 @@ func GenerateEmbeddingsForAll() error {
 
 	successCount := 0
 	failCount := 0
-	provider := "Ollama"
-	if !ollama.IsAvailable() {
-		provider = "Gemini"
-	}
+	provider := availableProviders()[0].Name()
 
 	for i, cmd := range commands {
 		if i%10 == 0 && i > 0 {
 			fmt.Printf("Progress: %d/%d (%.1f%%)\n", i, len(commands), float64(i)/float64(len(commands))*100)
 		}
 
-		text := cmd.Key + " " + cmd.Data
-		var embedding []float64
-		var embErr error
-
-		if ollama.IsAvailable() {
-			embedding, embErr = ollama.GetEmbedding(text)
-			if embErr != nil {
-				if gemini.IsAvailable() {
-					embedding, embErr = gemini.GetEmbedding(text)
-					if embErr == nil {
-						provider = "Gemini"
-					}
-				}
-			} else {
-				provider = "Ollama"
-			}
-		} else if gemini.IsAvailable() {
-			embedding, embErr = gemini.GetEmbedding(text)
-			provider = "Gemini"
-		}
-
+		embedding, used, embErr := embedWithFallback(cmd.Key + " " + cmd.Data)
 		if embErr != nil {
-			log.Printf("Failed to generate embedding for ID %d: %v\n", cmd.Id, embErr)
+			logger.Warn("embedding generation failed", "id", cmd.Id, "err", embErr)
 			failCount++
 			continue
 		}
+		provider = used.Name()
 
 		if err := database.UpdateEmbedding(cmd.Id, embedding); err != nil {
-			log.Printf("Failed to update embedding for ID %d: %v\n", cmd.Id, err)
+			logger.Warn("embedding update failed", "id", cmd.Id, "err", err)
 			failCount++
 			continue
 		}
 
 		successCount++
 
-		if gemini.IsAvailable() && i < len(commands)-1 {
-			time.Sleep(100 * time.Millisecond)
+		if t, ok := used.(embedThrottler); ok && i < len(commands)-1 {
+			time.Sleep(t.EmbedInterval())
 		}
 	}
 
@@ -1,16 +1,20 @@
 package gemini
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/ai/chat"
+	"github.com/gcclinux/scmd/internal/metrics"
 )
 
 // Config holds Gemini API configuration.
@@ -35,7 +39,13 @@ type embeddingResponse struct {
 }
 
 type chatRequest struct {
-	Contents []chatContent `json:"contents"`
+	SystemInstruction *chatContent      `json:"systemInstruction,omitempty"`
+	Contents          []chatContent     `json:"contents"`
+	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+}
+
+type generationConfig struct {
+	Temperature *float64 `json:"temperature,omitempty"`
 }
 
 type chatContent struct {
@@ -62,6 +72,18 @@ type chatResponse struct {
 	} `json:"usageMetadata"`
 }
 
+// usage splits the reported tokens into prompt and completion tokens.
+// Tokens counted in the total but not in either part, such as thinking
+// tokens, are billed as output and counted as completion tokens.
+func (r chatResponse) usage() chat.Usage {
+	m := r.UsageMetadata
+	u := chat.Usage{PromptTokens: m.PromptTokenCount, CompletionTokens: m.CandidatesTokenCount}
+	if m.TotalTokenCount > u.Total() {
+		u.CompletionTokens = m.TotalTokenCount - m.PromptTokenCount
+	}
+	return u
+}
+
 var (
 	available bool
 	cfg       Config
@@ -103,6 +125,13 @@ func IsAvailable() bool {
 
 // GetEmbedding gets an embedding vector from Gemini API.
 func GetEmbedding(text string) ([]float64, error) {
+	start := time.Now()
+	embedding, err := getEmbedding(text)
+	metrics.RecordAI("gemini", "embed", start, 0, err)
+	return embedding, err
+}
+
+func getEmbedding(text string) ([]float64, error) {
 	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:embedContent?key=%s",
 		cfg.EmbeddingModel, cfg.APIKey)
 
@@ -153,76 +182,153 @@ func GetEmbedding(text string) ([]float64, error) {
 	return embedding, nil
 }
 
-// Ask sends a question to Gemini and gets a response.
-// Returns (responseText, totalTokens, error).
-func Ask(question string, context []database.CommandRecord) (string, int, error) {
-	if !IsAvailable() {
-		return "", 0, fmt.Errorf("Gemini API is not available")
+// buildChatRequest assembles the request body for a conversation. Gemini
+// takes the system prompt as a separate instruction and calls the
+// assistant role "model".
+func buildChatRequest(system string, messages []chat.Message, opts chat.Options) chatRequest {
+	var req chatRequest
+	if system != "" {
+		req.SystemInstruction = &chatContent{Parts: []chatPart{{Text: system}}}
 	}
-
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s",
-		cfg.Model, cfg.APIKey)
-
-	contextStr := ""
-	if len(context) > 0 {
-		contextStr = "Here are some relevant commands from the database:\n\n"
-		for i, cmd := range context {
-			contextStr += fmt.Sprintf("%d. Description: %s\n   Command: %s\n\n", i+1, cmd.Data, cmd.Key)
+	for _, m := range messages {
+		role := m.Role
+		if role == chat.RoleAssistant {
+			role = "model"
 		}
+		req.Contents = append(req.Contents, chatContent{Role: role, Parts: []chatPart{{Text: m.Content}}})
 	}
+	if opts.Temperature != nil {
+		req.GenerationConfig = &generationConfig{Temperature: opts.Temperature}
+	}
+	return req
+}
 
-	systemPrompt := `You are a helpful assistant that helps users find and understand command-line commands. 
-You have access to a database of commands. When answering questions:
-1. Always start with a brief, natural introduction
-2. Reference the specific commands from the context provided (if any)
-3. ALWAYS format commands in code blocks with the appropriate language tag (bash, powershell, sql, docker, etc.)
-4. Use triple backticks with language tags for code blocks
-5. Explain what the command does after showing it
-6. Be concise but informative
-7. If multiple commands are relevant, show each in its own code block
-8. Detect the command type and use the correct language tag (bash, powershell, postgresql, mysql, docker, kubernetes, python, etc.)
-9. If no commands are relevant or no context provided, provide the best answer you can based on your knowledge.`
-
-	userPrompt := fmt.Sprintf("%s\nUser question: %s", contextStr, question)
+// Chat sends the system prompt and messages to Gemini and returns the
+// answer.
+// Returns (responseText, usage, error).
+func Chat(system string, messages []chat.Message, opts chat.Options) (string, chat.Usage, error) {
+	start := time.Now()
+	response, usage, err := chatOnce(system, messages, opts)
+	metrics.RecordAI("gemini", "chat", start, usage.Total(), err)
+	return response, usage, err
+}
 
-	reqBody := chatRequest{
-		Contents: []chatContent{
-			{
-				Role: "user",
-				Parts: []chatPart{
-					{Text: systemPrompt + "\n\n" + userPrompt},
-				},
-			},
-		},
+func chatOnce(system string, messages []chat.Message, opts chat.Options) (string, chat.Usage, error) {
+	if !IsAvailable() {
+		return "", chat.Usage{}, fmt.Errorf("Gemini API is not available")
 	}
 
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s",
+		opts.ModelOr(cfg.Model), cfg.APIKey)
+
+	reqBody := buildChatRequest(system, messages, opts)
+
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", 0, fmt.Errorf("error marshaling request: %v", err)
+		return "", chat.Usage{}, fmt.Errorf("error marshaling request: %v", err)
 	}
 
 	client := &http.Client{Timeout: 60 * time.Second}
 	resp, err := client.Post(url, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", 0, fmt.Errorf("error calling Gemini API: %v", err)
+		return "", chat.Usage{}, fmt.Errorf("error calling Gemini API: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", 0, fmt.Errorf("Gemini API returned status %d: %s", resp.StatusCode, string(body))
+		return "", chat.Usage{}, fmt.Errorf("Gemini API returned status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var response chatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", 0, fmt.Errorf("error decoding response: %v", err)
+		return "", chat.Usage{}, fmt.Errorf("error decoding response: %v", err)
 	}
 
 	if len(response.Candidates) == 0 || len(response.Candidates[0].Content.Parts) == 0 {
-		return "", 0, fmt.Errorf("empty response from Gemini API")
+		return "", chat.Usage{}, fmt.Errorf("empty response from Gemini API")
+	}
+
+	return response.Candidates[0].Content.Parts[0].Text, response.usage(), nil
+}
+
+// ChatStream sends the system prompt and messages to Gemini and calls
+// onToken for every chunk of the answer as it arrives. The request is aborted when ctx is done.
+// Returns (responseText, usage, error).
+func ChatStream(ctx context.Context, system string, messages []chat.Message, opts chat.Options, onToken func(string)) (string, chat.Usage, error) {
+	start := time.Now()
+	response, usage, err := chatStream(ctx, system, messages, opts, onToken)
+	metrics.RecordAI("gemini", "stream", start, usage.Total(), err)
+	return response, usage, err
+}
+
+func chatStream(ctx context.Context, system string, messages []chat.Message, opts chat.Options, onToken func(string)) (string, chat.Usage, error) {
+	if !IsAvailable() {
+		return "", chat.Usage{}, fmt.Errorf("Gemini API is not available")
+	}
+
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse&key=%s",
+		opts.ModelOr(cfg.Model), cfg.APIKey)
+
+	jsonData, err := json.Marshal(buildChatRequest(system, messages, opts))
+	if err != nil {
+		return "", chat.Usage{}, fmt.Errorf("error marshaling request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", chat.Usage{}, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", chat.Usage{}, fmt.Errorf("error calling Gemini API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", chat.Usage{}, fmt.Errorf("Gemini API returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	return response.Candidates[0].Content.Parts[0].Text, response.UsageMetadata.TotalTokenCount, nil
+	// With alt=sse every chunk is a "data: {...}" line holding a partial
+	// response; usage metadata is cumulative, so the last value wins.
+	var answer strings.Builder
+	var usage chat.Usage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var chunk chatResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &chunk); err != nil {
+			return answer.String(), usage, fmt.Errorf("error decoding stream: %v", err)
+		}
+		if chunk.UsageMetadata.TotalTokenCount > 0 {
+			usage = chunk.usage()
+		}
+		for _, c := range chunk.Candidates {
+			for _, part := range c.Content.Parts {
+				if part.Text == "" {
+					continue
+				}
+				answer.WriteString(part.Text)
+				if onToken != nil {
+					onToken(part.Text)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return answer.String(), usage, fmt.Errorf("error reading stream: %v", err)
+	}
+	if answer.Len() == 0 {
+		return "", usage, fmt.Errorf("empty response from Gemini API")
+	}
+	return answer.String(), usage, nil
 }
 
 // ModelName returns the configured chat model name.
@@ -0,0 +1,44 @@
+package gemini
+
+import (
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/ai/chat"
+)
+
+func TestBuildChatRequest(t *testing.T) {
+	temperature := 0.5
+	req := buildChatRequest("be brief", []chat.Message{
+		{Role: chat.RoleUser, Content: "list files"},
+		{Role: chat.RoleAssistant, Content: "Use ls."},
+		{Role: chat.RoleUser, Content: "and hidden ones?"},
+	}, chat.Options{Temperature: &temperature})
+
+	if req.SystemInstruction == nil || req.SystemInstruction.Parts[0].Text != "be brief" {
+		t.Errorf("system instruction = %+v", req.SystemInstruction)
+	}
+	roles := ""
+	for _, c := range req.Contents {
+		roles += c.Role + " "
+	}
+	if roles != "user model user " || req.Contents[2].Parts[0].Text != "and hidden ones?" {
+		t.Errorf("contents = %+v", req.Contents)
+	}
+	if req.GenerationConfig == nil || *req.GenerationConfig.Temperature != 0.5 {
+		t.Errorf("generation config = %+v", req.GenerationConfig)
+	}
+
+	if req := buildChatRequest("", nil, chat.Options{}); req.SystemInstruction != nil || req.GenerationConfig != nil {
+		t.Errorf("empty request = %+v", req)
+	}
+}
+
+func TestChatResponseUsage(t *testing.T) {
+	var r chatResponse
+	r.UsageMetadata.PromptTokenCount = 10
+	r.UsageMetadata.CandidatesTokenCount = 4
+	r.UsageMetadata.TotalTokenCount = 20
+	if got := r.usage(); got != (chat.Usage{PromptTokens: 10, CompletionTokens: 10}) {
+		t.Errorf("usage = %+v, thinking tokens should count as completion tokens", got)
+	}
+}
@@ -2,15 +2,18 @@ package ollama
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/ai/chat"
+	"github.com/gcclinux/scmd/internal/metrics"
 )
 
 // Config holds Ollama configuration.
@@ -31,9 +34,14 @@ type embeddingResponse struct {
 }
 
 type chatRequest struct {
-	Model    string    `json:"model"`
-	Messages []message `json:"messages"`
-	Stream   bool      `json:"stream"`
+	Model    string       `json:"model"`
+	Messages []message    `json:"messages"`
+	Stream   bool         `json:"stream"`
+	Options  *chatOptions `json:"options,omitempty"`
+}
+
+type chatOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
 }
 
 type message struct {
@@ -48,6 +56,11 @@ type chatResponse struct {
 	Done            bool    `json:"done"`
 	PromptEvalCount int     `json:"prompt_eval_count"`
 	EvalCount       int     `json:"eval_count"`
+	Error           string  `json:"error,omitempty"`
+}
+
+func (r chatResponse) usage() chat.Usage {
+	return chat.Usage{PromptTokens: r.PromptEvalCount, CompletionTokens: r.EvalCount}
 }
 
 var (
@@ -100,6 +113,13 @@ func checkAvailability() bool {
 
 // GetEmbedding gets an embedding vector from Ollama.
 func GetEmbedding(text string) ([]float64, error) {
+	start := time.Now()
+	embedding, err := getEmbedding(text)
+	metrics.RecordAI("ollama", "embed", start, 0, err)
+	return embedding, err
+}
+
+func getEmbedding(text string) ([]float64, error) {
 	url := fmt.Sprintf("http://%s:%s/api/embeddings", cfg.Host, cfg.Port)
 
 	reqBody := embeddingRequest{
@@ -147,65 +167,129 @@ func GetEmbedding(text string) ([]float64, error) {
 	return embedding, nil
 }
 
-// Ask sends a question to Ollama and gets a response.
-// Returns (responseText, totalTokens, error).
-func Ask(question string, context []database.CommandRecord) (string, int, error) {
-	url := fmt.Sprintf("http://%s:%s/api/chat", cfg.Host, cfg.Port)
-
-	contextStr := ""
-	if len(context) > 0 {
-		contextStr = "Here are some relevant commands from the database:\n\n"
-		for i, cmd := range context {
-			contextStr += fmt.Sprintf("%d. Description: %s\n   Command: %s\n\n", i+1, cmd.Data, cmd.Key)
-		}
+// buildChatRequest assembles the chat request for a conversation: the
+// system prompt followed by messages.
+func buildChatRequest(system string, messages []chat.Message, opts chat.Options, stream bool) chatRequest {
+	msgs := make([]message, 0, len(messages)+1)
+	if system != "" {
+		msgs = append(msgs, message{Role: "system", Content: system})
+	}
+	for _, m := range messages {
+		msgs = append(msgs, message{Role: m.Role, Content: m.Content})
+	}
+	req := chatRequest{
+		Model:    opts.ModelOr(cfg.Model),
+		Messages: msgs,
+		Stream:   stream,
 	}
+	if opts.Temperature != nil {
+		req.Options = &chatOptions{Temperature: opts.Temperature}
+	}
+	return req
+}
 
-	systemPrompt := `You are a helpful assistant that helps users find and understand command-line commands. 
-You have access to a database of commands. When answering questions:
-1. Always start with a brief, natural introduction
-2. Reference the specific commands from the context provided
-3. ALWAYS format commands in code blocks with the appropriate language tag (bash, powershell, sql, docker, etc.)
-4. Use triple backticks with language tags for code blocks
-5. Explain what the command does after showing it
-6. Be concise but informative
-7. If multiple commands are relevant, show each in its own code block
-8. Detect the command type and use the correct language tag (bash, powershell, postgresql, mysql, docker, kubernetes, python, etc.)`
+// Chat sends the system prompt and messages to Ollama and returns the
+// answer.
+// Returns (responseText, usage, error).
+func Chat(system string, messages []chat.Message, opts chat.Options) (string, chat.Usage, error) {
+	start := time.Now()
+	response, usage, err := chatOnce(system, messages, opts)
+	metrics.RecordAI("ollama", "chat", start, usage.Total(), err)
+	return response, usage, err
+}
 
-	userPrompt := fmt.Sprintf("%s\nUser question: %s", contextStr, question)
+func chatOnce(system string, messages []chat.Message, opts chat.Options) (string, chat.Usage, error) {
+	url := fmt.Sprintf("http://%s:%s/api/chat", cfg.Host, cfg.Port)
 
-	reqBody := chatRequest{
-		Model: cfg.Model,
-		Messages: []message{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: userPrompt},
-		},
-		Stream: false,
-	}
+	reqBody := buildChatRequest(system, messages, opts, false)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", 0, fmt.Errorf("error marshaling request: %v", err)
+		return "", chat.Usage{}, fmt.Errorf("error marshaling request: %v", err)
 	}
 
 	client := &http.Client{Timeout: 60 * time.Second}
 	resp, err := client.Post(url, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", 0, fmt.Errorf("error calling Ollama: %v", err)
+		return "", chat.Usage{}, fmt.Errorf("error calling Ollama: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", 0, fmt.Errorf("Ollama returned status %d: %s", resp.StatusCode, string(body))
+		return "", chat.Usage{}, fmt.Errorf("Ollama returned status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var response chatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", 0, fmt.Errorf("error decoding response: %v", err)
+		return "", chat.Usage{}, fmt.Errorf("error decoding response: %v", err)
 	}
 
-	totalTokens := response.PromptEvalCount + response.EvalCount
-	return response.Message.Content, totalTokens, nil
+	return response.Message.Content, response.usage(), nil
+}
+
+// ChatStream sends the system prompt and messages to Ollama and calls
+// onToken for every chunk of the answer as it is generated. The request is
+// aborted when ctx is done.
+// Returns (responseText, usage, error).
+func ChatStream(ctx context.Context, system string, messages []chat.Message, opts chat.Options, onToken func(string)) (string, chat.Usage, error) {
+	start := time.Now()
+	response, usage, err := chatStream(ctx, system, messages, opts, onToken)
+	metrics.RecordAI("ollama", "stream", start, usage.Total(), err)
+	return response, usage, err
+}
+
+func chatStream(ctx context.Context, system string, messages []chat.Message, opts chat.Options, onToken func(string)) (string, chat.Usage, error) {
+	url := fmt.Sprintf("http://%s:%s/api/chat", cfg.Host, cfg.Port)
+
+	jsonData, err := json.Marshal(buildChatRequest(system, messages, opts, true))
+	if err != nil {
+		return "", chat.Usage{}, fmt.Errorf("error marshaling request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", chat.Usage{}, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// No client timeout: the answer may take minutes on CPU-only hosts and
+	// the caller controls the lifetime through ctx.
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", chat.Usage{}, fmt.Errorf("error calling Ollama: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", chat.Usage{}, fmt.Errorf("Ollama returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	// Ollama streams one JSON object per line.
+	var answer strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk chatResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return answer.String(), chat.Usage{}, nil
+			}
+			return answer.String(), chat.Usage{}, fmt.Errorf("error decoding stream: %v", err)
+		}
+		if chunk.Error != "" {
+			return answer.String(), chat.Usage{}, fmt.Errorf("Ollama error: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			answer.WriteString(chunk.Message.Content)
+			if onToken != nil {
+				onToken(chunk.Message.Content)
+			}
+		}
+		if chunk.Done {
+			return answer.String(), chunk.usage(), nil
+		}
+	}
 }
 
 // ModelName returns the configured model name.
@@ -0,0 +1,96 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/ai/chat"
+)
+
+// useTestServer points the package configuration at srv.
+func useTestServer(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("split %s: %v", srv.URL, err)
+	}
+	prev := cfg
+	cfg = Config{Host: host, Port: port, Model: "test-model", EmbeddingModel: "test-model"}
+	t.Cleanup(func() { cfg = prev })
+}
+
+func userMessage(content string) []chat.Message {
+	return []chat.Message{{Role: chat.RoleUser, Content: content}}
+}
+
+func TestChatStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			t.Errorf("expected a streaming request, got %+v (%v)", req, err)
+		}
+		if len(req.Messages) != 2 || req.Messages[0] != (message{"system", "be brief"}) || req.Messages[1] != (message{"user", "list files"}) {
+			t.Errorf("messages = %+v, want the system prompt then the question", req.Messages)
+		}
+		for _, part := range []string{"Use ", "`ls -la`", "."} {
+			fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q},"done":false}`+"\n", part)
+		}
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":7,"eval_count":5}`)
+	}))
+	defer srv.Close()
+	useTestServer(t, srv)
+
+	var chunks []string
+	answer, usage, err := ChatStream(context.Background(), "be brief", userMessage("list files"), chat.Options{}, func(s string) {
+		chunks = append(chunks, s)
+	})
+	if err != nil {
+		t.Fatalf("AskStream: %v", err)
+	}
+	if answer != "Use `ls -la`." {
+		t.Errorf("answer = %q", answer)
+	}
+	if len(chunks) != 3 {
+		t.Errorf("got %d chunks, want 3", len(chunks))
+	}
+	if usage != (chat.Usage{PromptTokens: 7, CompletionTokens: 5}) {
+		t.Errorf("usage = %+v, want 7 prompt and 5 completion tokens", usage)
+	}
+}
+
+func TestAskStream_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"error":"model not found"}`)
+	}))
+	defer srv.Close()
+	useTestServer(t, srv)
+
+	if _, _, err := ChatStream(context.Background(), "", userMessage("q"), chat.Options{}, nil); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("err = %v, want model not found", err)
+	}
+}
+
+func TestAskStream_Cancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"content":"partial"},"done":false}`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	useTestServer(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	answer, _, err := ChatStream(ctx, "", userMessage("q"), chat.Options{}, func(string) { cancel() })
+	if err == nil {
+		t.Fatal("expected an error after cancellation")
+	}
+	if answer != "partial" {
+		t.Errorf("answer = %q, want partial", answer)
+	}
+}
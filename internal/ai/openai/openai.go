@@ -0,0 +1,357 @@
+// Package openai talks to any server exposing the OpenAI chat completions
+// and embeddings API, such as vLLM, LM Studio, llama.cpp server or LocalAI.
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/ai/chat"
+	"github.com/gcclinux/scmd/internal/metrics"
+)
+
+// Config holds OpenAI-compatible server configuration.
+type Config struct {
+	BaseURL        string // e.g. http://localhost:8000/v1
+	APIKey         string // optional for local servers
+	Model          string
+	EmbeddingModel string
+}
+
+type embeddingRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []message      `json:"messages"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message message `json:"message"`
+		Delta   message `json:"delta"`
+	} `json:"choices"`
+	Usage *usage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type modelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+var (
+	available bool
+	cfg       Config
+	checked   bool
+)
+
+// Init reads the OpenAI-compatible server configuration and checks
+// availability. The provider is only used when OPENAI_BASE_URL is set.
+// Without OPENAI_MODEL the first model the server lists is used.
+func Init() {
+	cfg = Config{
+		BaseURL:        strings.TrimRight(os.Getenv("OPENAI_BASE_URL"), "/"),
+		APIKey:         os.Getenv("OPENAI_API_KEY"),
+		Model:          os.Getenv("OPENAI_MODEL"),
+		EmbeddingModel: os.Getenv("OPENAI_EMBEDDING_MODEL"),
+	}
+
+	available = cfg.BaseURL != "" && checkAvailability()
+	if cfg.EmbeddingModel == "" {
+		cfg.EmbeddingModel = cfg.Model
+	}
+	checked = true
+}
+
+// IsAvailable returns whether the OpenAI-compatible server is available.
+func IsAvailable() bool {
+	if !checked {
+		Init()
+	}
+	return available
+}
+
+// checkAvailability lists the server's models, filling in cfg.Model when
+// it is not configured.
+func checkAvailability() bool {
+	req, err := http.NewRequest(http.MethodGet, cfg.BaseURL+"/models", nil)
+	if err != nil {
+		return false
+	}
+	setAuth(req)
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	if cfg.Model == "" {
+		var models modelsResponse
+		if err := json.NewDecoder(resp.Body).Decode(&models); err != nil || len(models.Data) == 0 {
+			return false
+		}
+		cfg.Model = models.Data[0].ID
+	}
+	return true
+}
+
+func setAuth(req *http.Request) {
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+}
+
+// post sends body as JSON to path under the base URL.
+func post(ctx context.Context, client *http.Client, path string, body any) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.BaseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setAuth(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling OpenAI-compatible server: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("OpenAI-compatible server returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
+
+// GetEmbedding gets an embedding vector from the /embeddings endpoint.
+func GetEmbedding(text string) ([]float64, error) {
+	start := time.Now()
+	embedding, err := getEmbedding(text)
+	metrics.RecordAI("openai", "embed", start, 0, err)
+	return embedding, err
+}
+
+func getEmbedding(text string) ([]float64, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := post(context.Background(), client, "/embeddings", embeddingRequest{Model: cfg.EmbeddingModel, Input: text})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("empty embedding response")
+	}
+
+	targetDim := 384
+	if dimStr := os.Getenv("EMBEDDING_DIM"); dimStr != "" {
+		if dim, err := strconv.Atoi(dimStr); err == nil {
+			targetDim = dim
+		}
+	}
+
+	embedding := response.Data[0].Embedding
+	if len(embedding) > targetDim {
+		embedding = embedding[:targetDim]
+	} else if len(embedding) < targetDim {
+		padding := make([]float64, targetDim-len(embedding))
+		embedding = append(embedding, padding...)
+	}
+
+	return embedding, nil
+}
+
+// buildChatRequest assembles the chat request for a conversation: the
+// system prompt followed by messages.
+func buildChatRequest(system string, messages []chat.Message, opts chat.Options, stream bool) chatRequest {
+	msgs := make([]message, 0, len(messages)+1)
+	if system != "" {
+		msgs = append(msgs, message{Role: "system", Content: system})
+	}
+	for _, m := range messages {
+		msgs = append(msgs, message{Role: m.Role, Content: m.Content})
+	}
+	req := chatRequest{
+		Model:       opts.ModelOr(cfg.Model),
+		Messages:    msgs,
+		Stream:      stream,
+		Temperature: opts.Temperature,
+	}
+	if stream {
+		req.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	return req
+}
+
+// Chat sends the system prompt and messages to the chat completions
+// endpoint.
+// Returns (responseText, usage, error).
+func Chat(system string, messages []chat.Message, opts chat.Options) (string, chat.Usage, error) {
+	start := time.Now()
+	response, usage, err := chatOnce(system, messages, opts)
+	metrics.RecordAI("openai", "chat", start, usage.Total(), err)
+	return response, usage, err
+}
+
+func chatOnce(system string, messages []chat.Message, opts chat.Options) (string, chat.Usage, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := post(context.Background(), client, "/chat/completions", buildChatRequest(system, messages, opts, false))
+	if err != nil {
+		return "", chat.Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var response chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", chat.Usage{}, fmt.Errorf("error decoding response: %v", err)
+	}
+	if response.Error != nil {
+		return "", chat.Usage{}, fmt.Errorf("OpenAI-compatible server error: %s", response.Error.Message)
+	}
+	if len(response.Choices) == 0 {
+		return "", chat.Usage{}, fmt.Errorf("empty response from OpenAI-compatible server")
+	}
+
+	return response.Choices[0].Message.Content, response.Usage.chatUsage(), nil
+}
+
+// ChatStream sends the system prompt and messages to the chat completions
+// endpoint and calls onToken for every chunk of the answer as it is
+// generated. The request is aborted when ctx is done.
+// Returns (responseText, usage, error).
+func ChatStream(ctx context.Context, system string, messages []chat.Message, opts chat.Options, onToken func(string)) (string, chat.Usage, error) {
+	start := time.Now()
+	response, usage, err := chatStream(ctx, system, messages, opts, onToken)
+	metrics.RecordAI("openai", "stream", start, usage.Total(), err)
+	return response, usage, err
+}
+
+func chatStream(ctx context.Context, system string, messages []chat.Message, opts chat.Options, onToken func(string)) (string, chat.Usage, error) {
+	// No client timeout: the caller controls the lifetime through ctx.
+	resp, err := post(ctx, http.DefaultClient, "/chat/completions", buildChatRequest(system, messages, opts, true))
+	if err != nil {
+		return "", chat.Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	// Server-sent events: "data: {...}" lines ending with "data: [DONE]".
+	// Usage arrives in a final chunk with no choices when include_usage is
+	// honoured; servers that ignore it report no token count.
+	var answer strings.Builder
+	var usage chat.Usage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+		var chunk chatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return answer.String(), usage, fmt.Errorf("error decoding stream: %v", err)
+		}
+		if chunk.Error != nil {
+			return answer.String(), usage, fmt.Errorf("OpenAI-compatible server error: %s", chunk.Error.Message)
+		}
+		if u := chunk.Usage.chatUsage(); u.Total() > 0 {
+			usage = u
+		}
+		for _, c := range chunk.Choices {
+			if c.Delta.Content == "" {
+				continue
+			}
+			answer.WriteString(c.Delta.Content)
+			if onToken != nil {
+				onToken(c.Delta.Content)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return answer.String(), usage, fmt.Errorf("error reading stream: %v", err)
+	}
+	if answer.Len() == 0 {
+		return "", usage, fmt.Errorf("empty response from OpenAI-compatible server")
+	}
+	return answer.String(), usage, nil
+}
+
+// chatUsage converts the reported usage. Servers that only report
+// total_tokens have it counted as completion tokens.
+func (u *usage) chatUsage() chat.Usage {
+	if u == nil {
+		return chat.Usage{}
+	}
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+		return chat.Usage{CompletionTokens: u.TotalTokens}
+	}
+	return chat.Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
+
+// ModelName returns the configured chat model name.
+func ModelName() string {
+	return cfg.Model
+}
+
+// EmbeddingModelName returns the configured embedding model name.
+func EmbeddingModelName() string {
+	return cfg.EmbeddingModel
+}
+
+// BaseURL returns the configured server URL.
+func BaseURL() string {
+	return cfg.BaseURL
+}
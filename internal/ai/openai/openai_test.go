@@ -0,0 +1,180 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/ai/chat"
+)
+
+// useTestServer points the package configuration at srv.
+func useTestServer(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	prev, prevAvailable, prevChecked := cfg, available, checked
+	cfg = Config{BaseURL: srv.URL + "/v1", APIKey: "secret", Model: "test-model", EmbeddingModel: "test-embed"}
+	available, checked = true, true
+	t.Cleanup(func() { cfg, available, checked = prev, prevAvailable, prevChecked })
+}
+
+func userMessage(content string) []chat.Message {
+	return []chat.Message{{Role: chat.RoleUser, Content: content}}
+}
+
+func TestInit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"qwen2.5-7b"},{"id":"other"}]}`)
+	}))
+	defer srv.Close()
+	useTestServer(t, srv)
+
+	t.Setenv("OPENAI_BASE_URL", srv.URL+"/v1/")
+	t.Setenv("OPENAI_API_KEY", "secret")
+	t.Setenv("OPENAI_MODEL", "")
+	t.Setenv("OPENAI_EMBEDDING_MODEL", "")
+	Init()
+	if !IsAvailable() || ModelName() != "qwen2.5-7b" || EmbeddingModelName() != "qwen2.5-7b" {
+		t.Errorf("available %v, model %q, embedding %q", IsAvailable(), ModelName(), EmbeddingModelName())
+	}
+
+	t.Setenv("OPENAI_API_KEY", "wrong")
+	if Init(); IsAvailable() {
+		t.Error("a rejected API key should leave the provider unavailable")
+	}
+	t.Setenv("OPENAI_BASE_URL", "")
+	if Init(); IsAvailable() {
+		t.Error("the provider should be unavailable without a base URL")
+	}
+}
+
+func TestChat(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Stream || req.Model != "test-model" {
+			t.Errorf("unexpected request %+v (%v)", req, err)
+		}
+		want := []message{{"system", "be brief"}, {"user", "list files"}, {"assistant", "Use ls."}, {"user", "and hidden ones?"}}
+		if r.URL.Path != "/v1/chat/completions" || fmt.Sprint(req.Messages) != fmt.Sprint(want) {
+			t.Errorf("unexpected path %s or messages %+v", r.URL.Path, req.Messages)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Use ls -la."}}],"usage":{"prompt_tokens":20,"completion_tokens":4,"total_tokens":24}}`)
+	}))
+	defer srv.Close()
+	useTestServer(t, srv)
+
+	messages := []chat.Message{
+		{Role: chat.RoleUser, Content: "list files"},
+		{Role: chat.RoleAssistant, Content: "Use ls."},
+		{Role: chat.RoleUser, Content: "and hidden ones?"},
+	}
+	answer, usage, err := Chat("be brief", messages, chat.Options{})
+	if err != nil || answer != "Use ls -la." || usage != (chat.Usage{PromptTokens: 20, CompletionTokens: 4}) {
+		t.Errorf("Chat = %q, %+v, %v", answer, usage, err)
+	}
+}
+
+func TestChat_Options(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "persona-model" || req.Temperature == nil || *req.Temperature != 0.2 {
+			t.Errorf("model %q, temperature %v; want persona-model, 0.2", req.Model, req.Temperature)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+	useTestServer(t, srv)
+
+	temperature := 0.2
+	if _, _, err := Chat("", userMessage("q"), chat.Options{Model: "persona-model", Temperature: &temperature}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChat_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"model not loaded"}}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+	useTestServer(t, srv)
+
+	if _, _, err := Chat("", userMessage("q"), chat.Options{}); err == nil || !strings.Contains(err.Error(), "model not loaded") {
+		t.Errorf("err = %v, want model not loaded", err)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("expected a streaming request with usage, got %+v (%v)", req, err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, part := range []string{"Use ", "`ls -la`", "."} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", part)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":5}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+	useTestServer(t, srv)
+
+	var chunks []string
+	answer, usage, err := ChatStream(context.Background(), "be brief", userMessage("list files"), chat.Options{}, func(s string) {
+		chunks = append(chunks, s)
+	})
+	if err != nil {
+		t.Fatalf("AskStream: %v", err)
+	}
+	if answer != "Use `ls -la`." || len(chunks) != 3 {
+		t.Errorf("answer = %q in %d chunks", answer, len(chunks))
+	}
+	if usage.Total() != 12 || usage.PromptTokens != 7 {
+		t.Errorf("usage = %+v, want 7 prompt and 5 completion tokens", usage)
+	}
+}
+
+func TestAskStream_Cancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	useTestServer(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	answer, _, err := ChatStream(ctx, "", userMessage("q"), chat.Options{}, func(string) { cancel() })
+	if err == nil {
+		t.Fatal("expected an error after cancellation")
+	}
+	if answer != "partial" {
+		t.Errorf("answer = %q, want partial", answer)
+	}
+}
+
+func TestGetEmbedding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.URL.Path != "/v1/embeddings" || req.Model != "test-embed" || req.Input != "docker ps" {
+			t.Errorf("unexpected embedding request %s %+v (%v)", r.URL.Path, req, err)
+		}
+		fmt.Fprint(w, `{"data":[{"embedding":[0.1,0.2,0.3,0.4]}]}`)
+	}))
+	defer srv.Close()
+	useTestServer(t, srv)
+	t.Setenv("EMBEDDING_DIM", "3")
+
+	emb, err := GetEmbedding("docker ps")
+	if err != nil || len(emb) != 3 || emb[2] != 0.3 {
+		t.Errorf("GetEmbedding = %v, %v; want truncated to 3 dimensions", emb, err)
+	}
+}
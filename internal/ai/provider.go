@@ -0,0 +1,217 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gcclinux/scmd/internal/ai/chat"
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/prompts"
+)
+
+// Provider is an AI backend that answers questions and generates embeddings.
+// Adding a backend means implementing Provider and calling Register; the
+// functions in this package pick providers from the registry in priority
+// order.
+type Provider interface {
+	// Name is the display name, e.g. "Ollama". Its lower-case form is the
+	// key used by the agent and ai_priority settings.
+	Name() string
+	// Available reports whether the provider is configured and reachable.
+	Available() bool
+	// Models returns the configured chat and embedding models.
+	Models() Models
+	// Chat answers req. When onToken is non-nil the answer is streamed
+	// through it as it is generated and ctx can cancel the request.
+	// Returns (responseText, usage, error).
+	Chat(ctx context.Context, req ChatRequest, onToken func(string)) (string, chat.Usage, error)
+	// Embed returns the embedding vector for text.
+	Embed(text string) ([]float64, error)
+}
+
+// ChatRequest is a question together with the stored commands that give
+// the model context and, for follow-up questions, the earlier turns of the
+// conversation.
+type ChatRequest struct {
+	Question string
+	Context  []database.CommandRecord
+	History  []Turn
+	// System replaces the system prompt rendered from the system template,
+	// e.g. with a persona's instructions.
+	System string
+	// Options such as a persona's model and temperature. The model only
+	// applies to the first provider tried; fallbacks use their own.
+	Options chat.Options
+	// OS describes the asking user's machine for the system prompt. It is
+	// filled in from the context; see WithLocalSystem.
+	OS prompts.OSInfo
+}
+
+type localSystemKey struct{}
+
+// WithLocalSystem returns a context whose chat requests describe this
+// machine (OS, distribution, package manager and tools) in the system
+// prompt. Only the CLI uses it: web users ask from their own machines,
+// which the server knows nothing about.
+func WithLocalSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, localSystemKey{}, true)
+}
+
+// systemFor returns the machine to describe for requests made with ctx:
+// this one after WithLocalSystem, otherwise none.
+func systemFor(ctx context.Context) prompts.OSInfo {
+	if local, _ := ctx.Value(localSystemKey{}).(bool); local {
+		return prompts.CurrentOS()
+	}
+	return prompts.OSInfo{}
+}
+
+// Messages returns the system prompt and the messages sent to a provider
+// for req: the earlier turns as user and assistant messages, then the
+// question with its context records rendered from the user template.
+func (req ChatRequest) Messages() (string, []chat.Message) {
+	system, user := prompts.Chat(req.Question, req.Context, req.OS)
+	if req.System != "" {
+		system = req.System
+	}
+	messages := make([]chat.Message, 0, 2*len(req.History)+1)
+	for _, t := range req.History {
+		messages = append(messages,
+			chat.Message{Role: chat.RoleUser, Content: t.Question},
+			chat.Message{Role: chat.RoleAssistant, Content: strings.TrimSpace(t.Answer)})
+	}
+	messages = append(messages, chat.Message{Role: chat.RoleUser, Content: user})
+	return system, messages
+}
+
+// forFallback returns req for the providers tried after the first one,
+// without the model override, which names a model of the first provider.
+func (req ChatRequest) forFallback() ChatRequest {
+	req.Options.Model = ""
+	return req
+}
+
+// Models names the models a provider is configured to use.
+type Models struct {
+	Chat      string
+	Embedding string
+}
+
+// initializer is implemented by providers that read their configuration
+// from the environment; InitProviders calls Init after the config is loaded.
+type initializer interface {
+	Init()
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Provider
+)
+
+// Register adds p to the provider registry, replacing a provider with the
+// same name. Registration order is the default priority order.
+func Register(p Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for i, existing := range registry {
+		if providerKey(existing) == providerKey(p) {
+			registry[i] = p
+			return
+		}
+	}
+	registry = append(registry, p)
+}
+
+// Lookup returns the registered provider with the given name, ignoring case.
+func Lookup(name string) (Provider, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, p := range registry {
+		if providerKey(p) == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Providers returns all registered providers in priority order: those named
+// in AI_PRIORITY (comma-separated) first, in that order, then the rest in
+// registration order.
+func Providers() []Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var ordered []Provider
+	used := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv("AI_PRIORITY"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || used[name] {
+			continue
+		}
+		for _, p := range registry {
+			if providerKey(p) == name {
+				ordered = append(ordered, p)
+				used[name] = true
+			}
+		}
+	}
+	for _, p := range registry {
+		if !used[providerKey(p)] {
+			ordered = append(ordered, p)
+		}
+	}
+	return ordered
+}
+
+// Preferred returns the provider name set by the agent setting (AGENT),
+// lower-cased, or "" when any provider may be used.
+func Preferred() string {
+	return strings.ToLower(strings.TrimSpace(os.Getenv("AGENT")))
+}
+
+// Chain returns the providers to try for a chat request, in order. When an
+// agent is preferred only that provider is used, and an error is returned
+// if it is unknown or unavailable; otherwise every available provider is
+// returned in priority order, forming the fallback chain. Once the daily
+// budget is spent the chain is limited as described in applyBudget.
+func Chain() ([]Provider, error) {
+	if name := Preferred(); name != "" {
+		p, ok := Lookup(name)
+		if !ok || !p.Available() {
+			return nil, fmt.Errorf("preferred AI provider '%s' is not available or failed", name)
+		}
+		return applyBudget([]Provider{p})
+	}
+	return applyBudget(availableProviders())
+}
+
+// Active returns the provider that would answer the next chat request, or
+// nil when none is available.
+func Active() Provider {
+	chain, err := Chain()
+	if err != nil || len(chain) == 0 {
+		return nil
+	}
+	return chain[0]
+}
+
+// availableProviders returns the available providers in priority order.
+// Embeddings use this chain regardless of the agent preference so that new
+// embeddings keep coming from the same provider as the stored ones.
+func availableProviders() []Provider {
+	var chain []Provider
+	for _, p := range Providers() {
+		if p.Available() {
+			chain = append(chain, p)
+		}
+	}
+	return chain
+}
+
+func providerKey(p Provider) string {
+	return strings.ToLower(p.Name())
+}
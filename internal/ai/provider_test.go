@@ -0,0 +1,202 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/ai/chat"
+)
+
+// fakeProvider is a Provider whose answers and failures are set by the test.
+type fakeProvider struct {
+	name      string
+	available bool
+	answer    string
+	err       error
+	calls     int
+	last      ChatRequest // the most recent request passed to Chat
+}
+
+func (f *fakeProvider) Name() string    { return f.name }
+func (f *fakeProvider) Available() bool { return f.available }
+func (f *fakeProvider) Models() Models {
+	return Models{Chat: f.name + "-chat", Embedding: f.name + "-embed"}
+}
+
+func (f *fakeProvider) Chat(ctx context.Context, req ChatRequest, onToken func(string)) (string, chat.Usage, error) {
+	f.calls++
+	f.last = req
+	if f.err != nil {
+		return "", chat.Usage{}, f.err
+	}
+	if onToken != nil {
+		onToken(f.answer)
+	}
+	return f.answer, chat.Usage{PromptTokens: len(req.Question), CompletionTokens: len(f.answer)}, nil
+}
+
+func (f *fakeProvider) Embed(text string) ([]float64, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []float64{float64(len(f.name))}, nil
+}
+
+// useProviders replaces the registry with providers for the test.
+func useProviders(t *testing.T, providers ...Provider) {
+	t.Helper()
+	registryMu.Lock()
+	prev := registry
+	registry = nil
+	registryMu.Unlock()
+	for _, p := range providers {
+		Register(p)
+	}
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = prev
+		registryMu.Unlock()
+	})
+	t.Setenv("AGENT", "")
+	t.Setenv("AI_PRIORITY", "")
+}
+
+func names(providers []Provider) string {
+	var n []string
+	for _, p := range providers {
+		n = append(n, p.Name())
+	}
+	return strings.Join(n, ",")
+}
+
+func TestChatRequestMessages(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	req := ChatRequest{Question: "list files", History: []Turn{{Question: "hi", Answer: "Hello!\n"}}}
+
+	system, messages := req.Messages()
+	if !strings.Contains(system, "You are a helpful assistant") {
+		t.Errorf("system = %q, want the system template", system)
+	}
+	if len(messages) != 3 || messages[0].Content != "hi" || messages[1].Role != "assistant" ||
+		messages[1].Content != "Hello!" || messages[2].Role != "user" || !strings.HasSuffix(messages[2].Content, "list files") {
+		t.Errorf("messages = %+v", messages)
+	}
+
+	req.System = "You are a pirate."
+	if system, _ := req.Messages(); system != "You are a pirate." {
+		t.Errorf("system = %q, want the request's own", system)
+	}
+}
+
+func TestProvidersPriority(t *testing.T) {
+	a := &fakeProvider{name: "Alpha", available: true}
+	b := &fakeProvider{name: "Beta", available: true}
+	c := &fakeProvider{name: "Gamma"}
+	useProviders(t, a, b, c)
+
+	if got := names(Providers()); got != "Alpha,Beta,Gamma" {
+		t.Errorf("default order = %s", got)
+	}
+	t.Setenv("AI_PRIORITY", "gamma, beta ,unknown")
+	if got := names(Providers()); got != "Gamma,Beta,Alpha" {
+		t.Errorf("ai_priority order = %s", got)
+	}
+	chain, err := Chain()
+	if err != nil || names(chain) != "Beta,Alpha" {
+		t.Errorf("chain = %s, %v; want available providers only", names(chain), err)
+	}
+
+	t.Setenv("AGENT", "alpha")
+	if chain, err := Chain(); err != nil || names(chain) != "Alpha" {
+		t.Errorf("preferred chain = %s, %v", names(chain), err)
+	}
+	t.Setenv("AGENT", "gamma")
+	if _, err := Chain(); err == nil {
+		t.Error("unavailable preferred provider should fail")
+	}
+	if Active() != nil {
+		t.Error("Active should be nil when the preferred provider is unavailable")
+	}
+
+	Register(&fakeProvider{name: "beta"})
+	if p, _ := Lookup("BETA"); p.Available() {
+		t.Error("Register should replace a provider with the same name")
+	}
+}
+
+func TestAskChainFallback(t *testing.T) {
+	broken := &fakeProvider{name: "Broken", available: true, err: errors.New("down")}
+	good := &fakeProvider{name: "Good", available: true, answer: "use ls"}
+	useProviders(t, broken, good)
+
+	answer, _, err := askChain(ChatRequest{Question: "list files"})
+	if err != nil || answer != "use ls" || broken.calls != 1 {
+		t.Errorf("askChain = %q, %v (broken calls %d)", answer, err, broken.calls)
+	}
+	if got := GetProviderLabel(); got != "Broken (Broken-chat)" {
+		t.Errorf("label = %q", got)
+	}
+
+	t.Setenv("AGENT", "broken")
+	if _, _, err := askChain(ChatRequest{Question: "list files"}); err == nil || good.calls != 1 {
+		t.Errorf("preferred provider failure should not fall back (err %v, good calls %d)", err, good.calls)
+	}
+
+	good.err = errors.New("also down")
+	t.Setenv("AGENT", "")
+	if _, _, err := askChain(ChatRequest{Question: "q"}); err == nil || !strings.Contains(err.Error(), "Good failed") {
+		t.Errorf("all failing err = %v", err)
+	}
+}
+
+func TestAskAIStreamFallback(t *testing.T) {
+	broken := &fakeProvider{name: "Broken", available: true, err: errors.New("down")}
+	good := &fakeProvider{name: "Good", available: true, answer: "streamed"}
+	useProviders(t, broken, good)
+
+	var got strings.Builder
+	answer, _, err := AskAIStream(context.Background(), "q", nil, func(tok string) { got.WriteString(tok) })
+	if err != nil || answer != "streamed" || got.String() != "streamed" {
+		t.Errorf("AskAIStream = %q, %v, tokens %q", answer, err, got.String())
+	}
+}
+
+func TestGetBestEmbeddingFallback(t *testing.T) {
+	broken := &fakeProvider{name: "Broken", available: true, err: errors.New("down")}
+	good := &fakeProvider{name: "Good", available: true}
+	useProviders(t, broken, good)
+	t.Setenv("AGENT", "broken")
+
+	emb, err := GetBestEmbedding("text")
+	if err != nil || len(emb) != 1 || emb[0] != 4 {
+		t.Errorf("GetBestEmbedding = %v, %v; want Good's embedding despite the agent preference", emb, err)
+	}
+
+	useProviders(t)
+	if _, err := GetBestEmbedding("text"); err == nil {
+		t.Error("no providers should fail")
+	}
+}
+
+func TestLocalSystemIsOptIn(t *testing.T) {
+	t.Setenv("AI_CACHE", "false")
+	p := &fakeProvider{name: "ollama", available: true, answer: "ok"}
+	useProviders(t, p)
+
+	if _, _, err := AskAIStream(context.Background(), "list files", nil, nil); err != nil {
+		t.Fatalf("AskAIStream: %v", err)
+	}
+	if p.last.OS.OS != "" {
+		t.Errorf("request without WithLocalSystem describes the host: %+v", p.last.OS)
+	}
+
+	if _, _, err := AskAIStream(WithLocalSystem(context.Background()), "list files", nil, nil); err != nil {
+		t.Fatalf("AskAIStream: %v", err)
+	}
+	if p.last.OS.OS != runtime.GOOS {
+		t.Errorf("request with WithLocalSystem has OS %+v", p.last.OS)
+	}
+}
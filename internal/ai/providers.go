@@ -0,0 +1,94 @@
+package ai
+
+import (
+	"context"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/ai/chat"
+	"github.com/gcclinux/scmd/internal/ai/gemini"
+	"github.com/gcclinux/scmd/internal/ai/ollama"
+	"github.com/gcclinux/scmd/internal/ai/openai"
+)
+
+func init() {
+	Register(ollamaProvider{})
+	Register(geminiProvider{})
+	Register(openaiProvider{})
+}
+
+// embedThrottler is implemented by providers whose embedding API is rate
+// limited; GenerateEmbeddingsForAll waits EmbedInterval between requests.
+type embedThrottler interface {
+	EmbedInterval() time.Duration
+}
+
+// ollamaProvider adapts the ollama package to Provider.
+type ollamaProvider struct{}
+
+func (ollamaProvider) Name() string    { return "Ollama" }
+func (ollamaProvider) Init()           { ollama.Init() }
+func (ollamaProvider) Available() bool { return ollama.IsAvailable() }
+
+func (ollamaProvider) Models() Models {
+	return Models{Chat: ollama.ModelName(), Embedding: ollama.EmbeddingModelName()}
+}
+
+func (ollamaProvider) Chat(ctx context.Context, req ChatRequest, onToken func(string)) (string, chat.Usage, error) {
+	system, messages := req.Messages()
+	if onToken != nil {
+		return ollama.ChatStream(ctx, system, messages, req.Options, onToken)
+	}
+	return ollama.Chat(system, messages, req.Options)
+}
+
+func (ollamaProvider) Embed(text string) ([]float64, error) { return ollama.GetEmbedding(text) }
+
+// Host returns the Ollama host, shown in the provider status line.
+func (ollamaProvider) Host() string { return ollama.Host() }
+
+// geminiProvider adapts the gemini package to Provider.
+type geminiProvider struct{}
+
+func (geminiProvider) Name() string    { return "Gemini" }
+func (geminiProvider) Init()           { gemini.Init() }
+func (geminiProvider) Available() bool { return gemini.IsAvailable() }
+
+func (geminiProvider) Models() Models {
+	return Models{Chat: gemini.ModelName(), Embedding: gemini.EmbeddingModelName()}
+}
+
+func (geminiProvider) Chat(ctx context.Context, req ChatRequest, onToken func(string)) (string, chat.Usage, error) {
+	system, messages := req.Messages()
+	if onToken != nil {
+		return gemini.ChatStream(ctx, system, messages, req.Options, onToken)
+	}
+	return gemini.Chat(system, messages, req.Options)
+}
+
+func (geminiProvider) Embed(text string) ([]float64, error) { return gemini.GetEmbedding(text) }
+
+func (geminiProvider) EmbedInterval() time.Duration { return 100 * time.Millisecond }
+
+// openaiProvider adapts the openai package to Provider.
+type openaiProvider struct{}
+
+func (openaiProvider) Name() string    { return "OpenAI" }
+func (openaiProvider) Init()           { openai.Init() }
+func (openaiProvider) Available() bool { return openai.IsAvailable() }
+
+func (openaiProvider) Models() Models {
+	return Models{Chat: openai.ModelName(), Embedding: openai.EmbeddingModelName()}
+}
+
+func (openaiProvider) Chat(ctx context.Context, req ChatRequest, onToken func(string)) (string, chat.Usage, error) {
+	system, messages := req.Messages()
+	if onToken != nil {
+		return openai.ChatStream(ctx, system, messages, req.Options, onToken)
+	}
+	return openai.Chat(system, messages, req.Options)
+}
+
+func (openaiProvider) Embed(text string) ([]float64, error) { return openai.GetEmbedding(text) }
+
+// Host returns the server base URL, shown in the provider status line.
+func (openaiProvider) Host() string { return openai.BaseURL() }
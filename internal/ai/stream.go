@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/metrics"
+	"github.com/gcclinux/scmd/internal/search"
+)
+
+// AskAIStream sends a question to the best available AI provider and calls
+// onToken with each chunk of the answer as it is generated. Provider
+// selection follows AskAI; a fallback provider is only tried when the
+// previous one failed before producing any output.
+// Returns (responseText, totalTokens, error).
+func AskAIStream(ctx context.Context, question string, context []database.CommandRecord, onToken func(string)) (string, int, error) {
+	return streamChain(ctx, ChatRequest{Question: question, Context: context}, onToken)
+}
+
+// streamChain streams the answer to req from the first provider in Chain
+// that produces one.
+func streamChain(ctx context.Context, req ChatRequest, onToken func(string)) (string, int, error) {
+	chain, err := Chain()
+	if err != nil {
+		return "", 0, err
+	}
+	if onToken == nil {
+		onToken = func(string) {}
+	}
+	if len(chain) == 1 && Preferred() != "" {
+		return cachedChat(ctx, chain[0], req, onToken)
+	}
+
+	var errs []error
+	emitted := false
+	track := func(tok string) {
+		emitted = true
+		onToken(tok)
+	}
+	for _, p := range chain {
+		response, tokens, err := cachedChat(ctx, p, req, track)
+		if err == nil || emitted || ctx.Err() != nil {
+			return response, tokens, err
+		}
+		errs = append(errs, fmt.Errorf("%s failed: %v", p.Name(), err))
+		req = req.forFallback()
+	}
+
+	if len(errs) > 0 {
+		return "", 0, fmt.Errorf("all AI providers failed: %v", errs)
+	}
+	return "", 0, fmt.Errorf("no AI provider available")
+}
+
+// SmartSearchStream is the streaming counterpart of SmartSearch used by the
+// web interface. It reports the matching database records through onRecords
+// as soon as they are known and then streams the AI answer through onToken.
+// When the keyword search already has high-quality matches no AI request is
+// made and the returned response is empty. Pinned commands rank first as
+// in SmartSearch.
+func SmartSearchStream(ctx context.Context, query string, pinned map[int]bool, onRecords func([]database.CommandRecord), onToken func(string)) ([]database.CommandRecord, string, int, error) {
+	cleanedQuery, scoredKeywords, err := keywordMatches(query, pinned)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	if search.HasGoodMatches(scoredKeywords, 60) {
+		var results []database.CommandRecord
+		for _, s := range search.GetBestMatches(search.FilterByMinScore(scoredKeywords, 60), 10) {
+			results = append(results, s.Record)
+		}
+		if onRecords != nil {
+			onRecords(results)
+		}
+		metrics.RecordSearchPath(metrics.PathKeyword)
+		return results, "", 0, nil
+	}
+
+	results, path := contextRecords(query, cleanedQuery, scoredKeywords, pinned)
+	metrics.RecordSearchPath(path)
+
+	if onRecords != nil {
+		onRecords(results)
+	}
+
+	response, tokens, err := AskAIStream(ctx, query, results, onToken)
+	return results, response, tokens, err
+}
+
+// keywordMatches runs the keyword search for query and scores the matches.
+// It returns the cleaned query the scores are based on.
+func keywordMatches(query string, pinned map[int]bool) (string, []search.CommandScore, error) {
+	cleanedQuery := search.ExtractKeywords(query)
+	if cleanedQuery == "" {
+		cleanedQuery = query
+	}
+
+	jsonData, err := database.SearchCommands(cleanedQuery, "json")
+	if err != nil {
+		return "", nil, err
+	}
+	var keywordResults []database.CommandRecord
+	json.Unmarshal(jsonData, &keywordResults)
+	return cleanedQuery, search.ScoreCommandsPinned(keywordResults, cleanedQuery, pinned), nil
+}
+
+// contextRecords picks the commands sent to the AI as context for query:
+// semantically similar records when embeddings are available, otherwise the
+// best keyword matches. It also returns the search path taken.
+func contextRecords(query, cleanedQuery string, scoredKeywords []search.CommandScore, pinned map[int]bool) ([]database.CommandRecord, string) {
+	var results []database.CommandRecord
+	path := metrics.PathFallback
+	if emb, err := GetBestEmbedding(query); err == nil {
+		if vResults, err := database.SearchByVector(emb, 10); err == nil {
+			for _, s := range search.ScoreCommandsPinned(vResults, cleanedQuery, pinned) {
+				if s.Score > 0 {
+					results = append(results, s.Record)
+				}
+			}
+			if len(results) > 0 {
+				path = metrics.PathVector
+			}
+		}
+	}
+	if len(results) == 0 {
+		for _, s := range search.GetBestMatches(scoredKeywords, 5) {
+			if s.Score > 0 {
+				results = append(results, s.Record)
+			}
+		}
+		if len(results) > 0 {
+			path = metrics.PathAI
+		}
+	}
+	return results, path
+}
@@ -0,0 +1,162 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/ai/chat"
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+// Price is what a model costs in US dollars per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`  // per million prompt tokens
+	Output float64 `json:"output"` // per million completion tokens
+}
+
+// Cost returns the estimated cost of usage at price p.
+func (p Price) Cost(u chat.Usage) float64 {
+	return (float64(u.PromptTokens)*p.Input + float64(u.CompletionTokens)*p.Output) / 1e6
+}
+
+// Free reports whether requests at price p cost nothing.
+func (p Price) Free() bool {
+	return p.Input == 0 && p.Output == 0
+}
+
+// Budget actions (ai_budget_action).
+const (
+	BudgetFallback = "fallback" // only use providers without a price
+	BudgetRefuse   = "refuse"   // refuse every AI request
+)
+
+// Prices returns the price table from ai_prices, keyed by "provider/model"
+// or "provider", in lower case. Providers and models without an entry are
+// free.
+func Prices() (map[string]Price, error) {
+	raw := strings.TrimSpace(os.Getenv("AI_PRICES"))
+	if raw == "" {
+		return nil, nil
+	}
+	var prices map[string]Price
+	if err := json.Unmarshal([]byte(raw), &prices); err != nil {
+		return nil, fmt.Errorf("invalid ai_prices: %v", err)
+	}
+	table := make(map[string]Price, len(prices))
+	for key, price := range prices {
+		table[strings.ToLower(strings.TrimSpace(key))] = price
+	}
+	return table, nil
+}
+
+// PriceFor returns the price of model on provider: the "provider/model"
+// entry, else the "provider" entry, else free.
+func PriceFor(provider, model string) Price {
+	prices, err := Prices()
+	if err != nil {
+		logger.Warn("ignoring AI prices", "err", err)
+		return Price{}
+	}
+	provider = strings.ToLower(provider)
+	if p, ok := prices[provider+"/"+strings.ToLower(model)]; ok {
+		return p
+	}
+	return prices[provider]
+}
+
+// DailyBudget returns the daily AI spending limit in US dollars
+// (ai_daily_budget), or 0 when there is none.
+func DailyBudget() float64 {
+	budget, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(os.Getenv("AI_DAILY_BUDGET")), "$"), 64)
+	if err != nil || budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+// BudgetAction returns what happens once the daily budget is spent:
+// BudgetFallback (the default) or BudgetRefuse.
+func BudgetAction() string {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("AI_BUDGET_ACTION")), BudgetRefuse) {
+		return BudgetRefuse
+	}
+	return BudgetFallback
+}
+
+// SpentToday returns the estimated cost of today's AI requests, counted
+// from local midnight.
+func SpentToday() (float64, error) {
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return database.AICostSince(midnight)
+}
+
+// applyBudget returns the providers of chain that may still be used today.
+// Once the daily budget is spent, BudgetRefuse refuses every request and
+// BudgetFallback keeps only the providers without a price, such as a local
+// Ollama. Without a budget, or when usage is not recorded, chain is
+// returned unchanged.
+func applyBudget(chain []Provider) ([]Provider, error) {
+	budget := DailyBudget()
+	if budget <= 0 {
+		return chain, nil
+	}
+	spent, err := SpentToday()
+	if err != nil {
+		if !errors.Is(err, database.ErrNoAIUsage) {
+			logger.Warn("checking the AI budget", "err", err)
+		}
+		return chain, nil
+	}
+	if spent < budget {
+		return chain, nil
+	}
+
+	reached := fmt.Sprintf("daily AI budget of $%.2f reached ($%.2f spent today)", budget, spent)
+	if BudgetAction() == BudgetRefuse {
+		return nil, errors.New(reached)
+	}
+	var free []Provider
+	for _, p := range chain {
+		if PriceFor(providerKey(p), p.Models().Chat).Free() {
+			free = append(free, p)
+		}
+	}
+	if len(free) == 0 {
+		return nil, fmt.Errorf("%s and no free AI provider is available", reached)
+	}
+	return free, nil
+}
+
+// meteredChat asks p and records the request's tokens, latency and
+// estimated cost. Failed requests are recorded when the provider reported
+// tokens for them.
+// Returns (responseText, totalTokens, error).
+func meteredChat(ctx context.Context, p Provider, model string, req ChatRequest, onToken func(string)) (string, int, error) {
+	start := time.Now()
+	response, usage, err := p.Chat(ctx, req, onToken)
+	if err == nil || usage.Total() > 0 {
+		recordUsage(providerKey(p), model, usage, time.Since(start))
+	}
+	return response, usage.Total(), err
+}
+
+func recordUsage(provider, model string, usage chat.Usage, latency time.Duration) {
+	err := database.RecordAIUsage(database.AIUsage{
+		Provider:         provider,
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Latency:          latency,
+		Cost:             PriceFor(provider, model).Cost(usage),
+	})
+	if err != nil && !errors.Is(err, database.ErrNoAIUsage) {
+		logger.Debug("recording AI usage failed", "provider", provider, "err", err)
+	}
+}
@@ -0,0 +1,100 @@
+package ai
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/ai/chat"
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func TestPriceFor(t *testing.T) {
+	t.Setenv("AI_PRICES", `{"gemini": {"input": 0.1, "output": 0.4}, "OpenAI/GPT-4o": {"input": 2.5, "output": 10}}`)
+
+	if p := PriceFor("Gemini", "gemini-2.5-flash-lite"); p != (Price{Input: 0.1, Output: 0.4}) {
+		t.Errorf("provider price = %+v", p)
+	}
+	if p := PriceFor("openai", "gpt-4o"); p.Input != 2.5 {
+		t.Errorf("model price = %+v", p)
+	}
+	if p := PriceFor("openai", "local-model"); !p.Free() {
+		t.Errorf("a model without a price should be free, got %+v", p)
+	}
+	cost := Price{Input: 2.5, Output: 10}.Cost(chat.Usage{PromptTokens: 1000, CompletionTokens: 500})
+	if math.Abs(cost-0.0075) > 1e-12 {
+		t.Errorf("Cost = %v, want 0.0075", cost)
+	}
+
+	t.Setenv("AI_PRICES", "not json")
+	if _, err := Prices(); err == nil {
+		t.Error("invalid ai_prices should fail")
+	}
+	if p := PriceFor("gemini", "x"); !p.Free() {
+		t.Errorf("invalid prices should be ignored, got %+v", p)
+	}
+}
+
+func TestUsageRecorded(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("AI_CACHE", "false")
+	t.Setenv("AI_PRICES", `{"gemini": {"input": 1000, "output": 2000}}`)
+	useProviders(t, &fakeProvider{name: "gemini", available: true, answer: "docker ps"})
+
+	if _, tokens, err := AskAIStream(context.Background(), "list containers", nil, nil); err != nil || tokens == 0 {
+		t.Fatalf("AskAIStream = %d tokens, %v", tokens, err)
+	}
+	report, err := database.AIUsageReport(time.Time{})
+	if err != nil || len(report) != 1 {
+		t.Fatalf("AIUsageReport = %+v, %v", report, err)
+	}
+	r := report[0]
+	if r.Provider != "gemini" || r.Model != "gemini-chat" || r.CompletionTokens != len("docker ps") || r.PromptTokens == 0 {
+		t.Errorf("usage = %+v", r)
+	}
+	want := (float64(r.PromptTokens)*1000 + float64(r.CompletionTokens)*2000) / 1e6
+	if math.Abs(r.Cost-want) > 1e-9 {
+		t.Errorf("cost = %v, want %v", r.Cost, want)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("AI_PRICES", `{"gemini": {"input": 1, "output": 1}}`)
+	paid := &fakeProvider{name: "gemini", available: true, answer: "paid"}
+	free := &fakeProvider{name: "ollama", available: true, answer: "free"}
+	useProviders(t, paid, free)
+	t.Setenv("AI_DAILY_BUDGET", "0.50")
+
+	ask := func() (string, error) {
+		answer, _, err := AskAIStream(BypassCache(context.Background()), "list containers", nil, nil)
+		return answer, err
+	}
+	if answer, err := ask(); err != nil || answer != "paid" {
+		t.Fatalf("under budget = %q, %v", answer, err)
+	}
+
+	if err := database.RecordAIUsage(database.AIUsage{Provider: "gemini", Model: "gemini-chat", Cost: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if answer, err := ask(); err != nil || answer != "free" {
+		t.Errorf("over budget should fall back to the free provider, got %q, %v", answer, err)
+	}
+
+	useProviders(t, paid)
+	if _, err := ask(); err == nil || !strings.Contains(err.Error(), "no free AI provider") {
+		t.Errorf("without a free provider the request should fail, got %v", err)
+	}
+
+	useProviders(t, paid, free)
+	t.Setenv("AI_BUDGET_ACTION", "refuse")
+	calls := free.calls
+	if _, err := ask(); err == nil || !strings.Contains(err.Error(), "daily AI budget of $0.50 reached") {
+		t.Errorf("refuse should fail the request, got %v", err)
+	}
+	if free.calls != calls {
+		t.Error("refuse should not ask any provider")
+	}
+}
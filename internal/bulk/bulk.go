@@ -0,0 +1,217 @@
+// Package bulk applies one operation (delete, retag, re-embed or export) to
+// a selection of stored commands. It is shared by `scmd bulk` and the web
+// stored page.
+package bulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+// Supported operations.
+const (
+	OpDelete  = "delete"
+	OpRetag   = "retag"
+	OpReembed = "reembed"
+	OpExport  = "export"
+)
+
+// Ops lists the supported operations in help order.
+var Ops = []string{OpDelete, OpRetag, OpReembed, OpExport}
+
+// Selection chooses the commands an operation applies to. Query and Tag
+// filter like the stored page; IDs restricts the result to those IDs. At
+// least one criterion is required unless All is set.
+type Selection struct {
+	Query string `json:"query,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+	IDs   []int  `json:"ids,omitempty"`
+	All   bool   `json:"all,omitempty"`
+}
+
+// Empty reports whether the selection has no criteria.
+func (s Selection) Empty() bool {
+	return strings.TrimSpace(s.Query) == "" && strings.TrimSpace(s.Tag) == "" && len(s.IDs) == 0 && !s.All
+}
+
+// Options describes one bulk operation.
+type Options struct {
+	Op         string
+	Selection  Selection
+	DryRun     bool
+	AddTags    []string                        // retag
+	RemoveTags []string                        // retag
+	Origin     database.Origin                 // retag: author of the revisions
+	Embed      func(string) ([]float64, error) // reembed
+	Output     io.Writer                       // export destination
+}
+
+// Result summarises a bulk operation.
+type Result struct {
+	Op       string                   `json:"op"`
+	DryRun   bool                     `json:"dry_run"`
+	Matched  int                      `json:"matched"`
+	Affected int                      `json:"affected"`
+	Failed   int                      `json:"failed,omitempty"`
+	Records  []database.CommandRecord `json:"records"`
+}
+
+// ParseIDs parses a list of IDs and inclusive ranges such as "3,7-10,42".
+func ParseIDs(spec string) ([]int, error) {
+	seen := make(map[int]bool)
+	var ids []int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		to, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || from <= 0 || to < from {
+			return nil, fmt.Errorf("invalid ID or range %q", part)
+		}
+		if to-from > 100000 {
+			return nil, fmt.Errorf("range %q is too large", part)
+		}
+		for id := from; id <= to; id++ {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// Resolve returns the commands matched by sel in ID order.
+func Resolve(sel Selection) ([]database.CommandRecord, error) {
+	if sel.Empty() {
+		return nil, fmt.Errorf("no selection: give a query, tag or IDs")
+	}
+
+	// IDs alone: look each one up instead of scanning the table.
+	if strings.TrimSpace(sel.Query) == "" && strings.TrimSpace(sel.Tag) == "" && !sel.All {
+		var records []database.CommandRecord
+		for _, id := range sel.IDs {
+			if r, err := database.GetCommandByID(id); err == nil {
+				records = append(records, *r)
+			}
+		}
+		return records, nil
+	}
+
+	want := make(map[int]bool, len(sel.IDs))
+	for _, id := range sel.IDs {
+		want[id] = true
+	}
+	var records []database.CommandRecord
+	opts := database.ListOptions{Query: sel.Query, Tag: sel.Tag, Limit: database.MaxPageSize}
+	for {
+		page, err := database.ListCommands(opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page.Records {
+			if len(want) == 0 || want[r.Id] {
+				records = append(records, r)
+			}
+		}
+		if page.NextCursor == "" {
+			return records, nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// Run resolves the selection and applies the operation. With DryRun set it
+// only reports what would change; export always writes its output.
+func Run(opts Options) (*Result, error) {
+	switch opts.Op {
+	case OpDelete, OpReembed, OpExport:
+	case OpRetag:
+		if len(database.NormalizeTags(opts.AddTags)) == 0 && len(database.NormalizeTags(opts.RemoveTags)) == 0 {
+			return nil, fmt.Errorf("retag needs tags to add or remove")
+		}
+	default:
+		return nil, fmt.Errorf("unknown bulk operation %q (want %s)", opts.Op, strings.Join(Ops, ", "))
+	}
+
+	records, err := Resolve(opts.Selection)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Op: opts.Op, DryRun: opts.DryRun, Matched: len(records), Records: records}
+	if res.Records == nil {
+		res.Records = []database.CommandRecord{}
+	}
+
+	if opts.Op == OpExport {
+		if opts.Output != nil {
+			enc := json.NewEncoder(opts.Output)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(res.Records); err != nil {
+				return nil, fmt.Errorf("error writing export: %v", err)
+			}
+		}
+		res.Affected = len(records)
+		return res, nil
+	}
+	if opts.DryRun || len(records) == 0 {
+		return res, nil
+	}
+
+	ids := make([]int, len(records))
+	for i, r := range records {
+		ids[i] = r.Id
+	}
+
+	switch opts.Op {
+	case OpDelete:
+		res.Affected, err = database.DeleteCommands(ids)
+	case OpRetag:
+		res.Affected, err = database.RetagCommands(ids, opts.AddTags, opts.RemoveTags, opts.Origin)
+	case OpReembed:
+		err = reembed(records, opts.Embed, res)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// reembed generates embeddings for records and stores them together.
+// Records whose embedding fails are counted in res.Failed and left as they
+// were.
+func reembed(records []database.CommandRecord, embed func(string) ([]float64, error), res *Result) error {
+	if embed == nil {
+		return fmt.Errorf("no embedding provider available")
+	}
+	embeddings := make(map[int][]float64, len(records))
+	for _, r := range records {
+		emb, err := embed(r.Key + " " + r.Data)
+		if err != nil || len(emb) == 0 {
+			res.Failed++
+			continue
+		}
+		embeddings[r.Id] = emb
+	}
+	if len(embeddings) == 0 {
+		return fmt.Errorf("embedding failed for all %d commands", len(records))
+	}
+	if err := database.UpdateEmbeddings(embeddings); err != nil {
+		return err
+	}
+	res.Affected = len(embeddings)
+	return nil
+}
@@ -0,0 +1,142 @@
+package bulk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DB_TYPE", "sqlite")
+	if err := os.MkdirAll(filepath.Join(home, ".scmd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InitSQLiteDB(); err != nil {
+		t.Fatalf("InitSQLiteDB: %v", err)
+	}
+	t.Cleanup(database.CloseDB)
+
+	for i, c := range []string{"docker ps", "docker images", "git status", "git log", "ls -la"} {
+		if _, err := database.AddCommand(c, fmt.Sprintf("description %d", i+1), database.Origin{}, nil); err != nil {
+			t.Fatalf("AddCommand: %v", err)
+		}
+	}
+}
+
+func recordIDs(records []database.CommandRecord) string {
+	ids := make([]int, len(records))
+	for i, r := range records {
+		ids[i] = r.Id
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestParseIDs(t *testing.T) {
+	ids, err := ParseIDs("9, 3-5,4,1")
+	if err != nil || fmt.Sprint(ids) != "[1 3 4 5 9]" {
+		t.Errorf("ParseIDs = %v, %v", ids, err)
+	}
+	for _, bad := range []string{"a", "5-3", "0", "-2", "1-x"} {
+		if _, err := ParseIDs(bad); err == nil {
+			t.Errorf("ParseIDs(%q) should fail", bad)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	setupTestDB(t)
+
+	if _, err := Resolve(Selection{}); err == nil {
+		t.Error("empty selection should fail")
+	}
+	tests := []struct {
+		sel  Selection
+		want string
+	}{
+		{Selection{Query: "docker"}, "[1 2]"},
+		{Selection{IDs: []int{5, 2, 42}}, "[5 2]"},
+		{Selection{Query: "git", IDs: []int{1, 4}}, "[4]"},
+		{Selection{All: true}, "[1 2 3 4 5]"},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(tt.sel)
+		if err != nil {
+			t.Fatalf("Resolve(%+v): %v", tt.sel, err)
+		}
+		if recordIDs(got) != tt.want {
+			t.Errorf("Resolve(%+v) = %s, want %s", tt.sel, recordIDs(got), tt.want)
+		}
+	}
+}
+
+func TestRun_DryRunAndDelete(t *testing.T) {
+	setupTestDB(t)
+
+	res, err := Run(Options{Op: OpDelete, Selection: Selection{Query: "git"}, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if res.Matched != 2 || res.Affected != 0 {
+		t.Errorf("dry run = %+v", res)
+	}
+	if all, _ := Resolve(Selection{All: true}); len(all) != 5 {
+		t.Fatalf("dry run deleted commands: %d left", len(all))
+	}
+
+	res, err = Run(Options{Op: OpDelete, Selection: Selection{Query: "git"}})
+	if err != nil || res.Affected != 2 {
+		t.Fatalf("delete = %+v, %v", res, err)
+	}
+	if all, _ := Resolve(Selection{All: true}); recordIDs(all) != "[1 2 5]" {
+		t.Errorf("after delete = %s", recordIDs(all))
+	}
+}
+
+func TestRun_RetagReembedExport(t *testing.T) {
+	setupTestDB(t)
+
+	if _, err := Run(Options{Op: OpRetag, Selection: Selection{All: true}}); err == nil {
+		t.Error("retag without tags should fail")
+	}
+	if _, err := Run(Options{Op: "shred", Selection: Selection{All: true}}); err == nil {
+		t.Error("unknown op should fail")
+	}
+
+	res, err := Run(Options{Op: OpRetag, Selection: Selection{Query: "docker"}, AddTags: []string{"containers"}})
+	if err != nil || res.Affected != 2 {
+		t.Fatalf("retag = %+v, %v", res, err)
+	}
+	tagged, _ := Resolve(Selection{Tag: "containers"})
+	if recordIDs(tagged) != "[1 2]" {
+		t.Errorf("tagged = %s", recordIDs(tagged))
+	}
+
+	embed := func(text string) ([]float64, error) {
+		if text == "ls -la description 5" {
+			return nil, fmt.Errorf("provider down")
+		}
+		return []float64{1, 2, 3}, nil
+	}
+	res, err = Run(Options{Op: OpReembed, Selection: Selection{IDs: []int{4, 5}}, Embed: embed})
+	if err != nil || res.Affected != 1 || res.Failed != 1 {
+		t.Errorf("reembed = %+v, %v", res, err)
+	}
+
+	var buf bytes.Buffer
+	res, err = Run(Options{Op: OpExport, Selection: Selection{Tag: "containers"}, Output: &buf})
+	if err != nil || res.Affected != 2 {
+		t.Fatalf("export = %+v, %v", res, err)
+	}
+	var exported []database.CommandRecord
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil || recordIDs(exported) != "[1 2]" {
+		t.Errorf("exported %s: %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gcclinux/scmd/internal/ai"
+	"github.com/gcclinux/scmd/internal/bulk"
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+// previewLimit caps how many matched commands are listed before acting.
+const previewLimit = 20
+
+// RunBulk implements `scmd bulk <op> [flags]` and returns the process exit
+// code.
+func RunBulk(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		printBulkUsage(os.Stderr)
+		return 2
+	}
+	op := args[0]
+
+	fs := flag.NewFlagSet("bulk "+op, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	query := fs.String("query", "", "select commands matching these words")
+	tag := fs.String("tag", "", "select commands with this tag")
+	ids := fs.String("ids", "", "select IDs and ranges, e.g. 3,7-10")
+	all := fs.Bool("all", false, "select every stored command")
+	dryRun := fs.Bool("dry-run", false, "show what would change without changing it")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	add := fs.String("add", "", "comma separated tags to add (retag)")
+	remove := fs.String("remove", "", "comma separated tags to remove (retag)")
+	output := fs.String("output", "", "file to write (export, default stdout)")
+	fs.Usage = func() { printBulkUsage(os.Stderr); fs.PrintDefaults() }
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	opts := bulk.Options{
+		Op:         op,
+		Selection:  bulk.Selection{Query: *query, Tag: *tag, All: *all},
+		DryRun:     *dryRun,
+		AddTags:    strings.Split(*add, ","),
+		RemoveTags: strings.Split(*remove, ","),
+		Origin:     database.LocalOrigin(database.SourceCLI),
+	}
+	if *ids != "" {
+		parsed, err := bulk.ParseIDs(*ids)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		if len(parsed) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: --ids selects nothing")
+			return 2
+		}
+		opts.Selection.IDs = parsed
+	}
+	if opts.Selection.Empty() {
+		fmt.Fprintln(os.Stderr, "Error: select commands with --query, --tag, --ids or --all")
+		return 2
+	}
+
+	if err := database.InitDB(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer database.CloseDB()
+
+	if op == bulk.OpReembed {
+		ai.InitProviders()
+		opts.Embed = ai.GetBestEmbedding
+	}
+
+	if op == bulk.OpExport {
+		if *dryRun {
+			opts.Output = nil
+		} else if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			defer f.Close()
+			opts.Output = f
+		} else {
+			opts.Output = os.Stdout
+		}
+	} else if !*dryRun {
+		// Preview first so the user confirms against the actual selection.
+		preview := opts
+		preview.DryRun = true
+		res, err := bulk.Run(preview)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		printBulkPreview(os.Stdout, res)
+		if res.Matched == 0 {
+			return 0
+		}
+		if !*yes && !confirmBulk(op, res.Matched) {
+			fmt.Println("Cancelled.")
+			return 0
+		}
+	}
+
+	res, err := bulk.Run(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	switch {
+	case res.DryRun:
+		printBulkPreview(os.Stdout, res)
+		fmt.Printf("Dry run: %s would affect %d command(s).\n", op, res.Matched)
+	case op == bulk.OpExport:
+		if *output != "" {
+			fmt.Printf("Exported %d command(s) to %s\n", res.Affected, *output)
+		}
+	default:
+		fmt.Printf(NoticeColor, fmt.Sprintf("%s: %d of %d command(s) updated\n", op, res.Affected, res.Matched))
+		if res.Failed > 0 {
+			fmt.Printf(WarningColor, fmt.Sprintf("%d command(s) failed and were left unchanged\n", res.Failed))
+		}
+	}
+	return 0
+}
+
+func printBulkPreview(w io.Writer, res *bulk.Result) {
+	fmt.Fprintf(w, "%d command(s) selected for %s:\n", res.Matched, res.Op)
+	for i, r := range res.Records {
+		if i == previewLimit {
+			fmt.Fprintf(w, "  ... and %d more\n", len(res.Records)-previewLimit)
+			break
+		}
+		fmt.Fprintf(w, "  %5d  %s\n", r.Id, truncate(r.Key, 70))
+	}
+}
+
+func confirmBulk(op string, n int) bool {
+	fmt.Printf("Apply %s to %d command(s)? (y/n): ", op, n)
+	reader := bufio.NewReader(os.Stdin)
+	response, _ := reader.ReadString('\n')
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+func printBulkUsage(w io.Writer) {
+	name := GetName()
+	fmt.Fprintf(w, "Usage: %s bulk <%s> [--query words | --tag name | --ids 1-5,9 | --all] [--dry-run] [--yes]\n",
+		name, strings.Join(bulk.Ops, "|"))
+	fmt.Fprintf(w, "       %s bulk retag --tag old --add new --remove old\n", name)
+	fmt.Fprintf(w, "       %s bulk export --query docker --output docker.json\n", name)
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/ai"
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/metrics"
+)
+
+// printCacheStatus shows the AI cache settings and usage under /ai.
+func printCacheStatus(w io.Writer) {
+	stats, err := database.AICacheStatus()
+	printCache(w, ai.CacheEnabled(), stats, err)
+}
+
+// printCache shows the cache state: its settings, what the database holds
+// and the hits and misses of this session.
+func printCache(w io.Writer, enabled bool, stats database.AICacheStats, err error) {
+	fmt.Fprintln(w)
+	switch {
+	case errors.Is(err, database.ErrNoAICache):
+		fmt.Fprintln(w, "💾 AI cache: not available with the MCP backend")
+		return
+	case !enabled:
+		fmt.Fprintln(w, "💾 AI cache: off (\"ai_cache\": \"false\" or --no-cache)")
+	default:
+		fmt.Fprintf(w, "💾 AI cache: on (ttl %s, max %s)\n", formatTTL(ai.CacheTTL()), formatBytes(ai.CacheMaxSize()))
+	}
+	if err != nil {
+		fmt.Fprintf(w, "  Error reading the cache: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "  Stored: %d entries, %s, %d hits\n", stats.Entries, formatBytes(stats.Bytes), stats.Hits)
+
+	var hits, misses float64
+	for _, kind := range []string{database.CacheChat, database.CacheEmbed} {
+		hits += metrics.AICache.Value(kind, "hit")
+		misses += metrics.AICache.Value(kind, "miss")
+	}
+	fmt.Fprintf(w, "  This session: %.0f hits, %.0f misses\n", hits, misses)
+}
+
+// formatBytes renders a byte count such as "1.5 MB".
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// formatTTL renders whole days as "7d", the form ai_cache_ttl accepts.
+func formatTTL(d time.Duration) string {
+	if day := 24 * time.Hour; d >= day && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func TestPrintCache(t *testing.T) {
+	t.Setenv("AI_CACHE_TTL", "")
+	t.Setenv("AI_CACHE_MAX_SIZE", "10MB")
+
+	var out strings.Builder
+	printCache(&out, true, database.AICacheStats{Entries: 3, Bytes: 1536, Hits: 4}, nil)
+	got := out.String()
+	for _, want := range []string{"AI cache: on (ttl 7d, max 10.0 MB)", "3 entries, 1.5 KB, 4 hits", "This session:"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+
+	out.Reset()
+	printCache(&out, false, database.AICacheStats{}, nil)
+	if got := out.String(); !strings.Contains(got, "AI cache: off") {
+		t.Errorf("output = %q", got)
+	}
+
+	out.Reset()
+	printCache(&out, true, database.AICacheStats{}, database.ErrNoAICache)
+	if got := out.String(); !strings.Contains(got, "not available") || strings.Contains(got, "Stored") {
+		t.Errorf("output = %q", got)
+	}
+}
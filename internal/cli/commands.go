@@ -3,6 +3,7 @@ package cli
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -10,13 +11,16 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gcclinux/scmd/internal/ai"
-	"github.com/gcclinux/scmd/internal/ai/gemini"
-	"github.com/gcclinux/scmd/internal/ai/ollama"
 	"github.com/gcclinux/scmd/internal/config"
 	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/dedupe"
+	"github.com/gcclinux/scmd/internal/diff"
+	"github.com/gcclinux/scmd/internal/logging"
 	"github.com/gcclinux/scmd/internal/markdown"
+	"github.com/gcclinux/scmd/internal/sysenv"
 	"github.com/gcclinux/scmd/internal/util"
 )
 
@@ -24,6 +28,18 @@ import (
 // description, so the feedback loop can use it for regeneration.
 var showOriginalQuery string
 
+// showID holds the ID of the record last displayed by /show, so running its
+// code blocks can be recorded as usage.
+var showID int
+
+// recordUsage records a usage event without interrupting the session;
+// statistics are best effort.
+func recordUsage(id int, event string) {
+	if err := database.RecordUsage(id, event); err != nil {
+		logging.For(logging.CLI).Debug("recording usage", "id", id, "event", event, "err", err)
+	}
+}
+
 func handleSlashCommand(input string) string {
 	parts := strings.SplitN(input, " ", 2)
 	command := parts[0]
@@ -61,6 +77,52 @@ func handleSlashCommand(input string) string {
 		handleDeleteCommand(args)
 	case "/list":
 		handleListCommand()
+	case "/trash":
+		handleTrashCommand()
+	case "/restore":
+		if args == "" {
+			fmt.Println("Usage: /restore <id>")
+			return ""
+		}
+		handleRestoreCommand(args)
+	case "/purge":
+		handlePurgeCommand()
+	case "/pin":
+		if args == "" {
+			fmt.Println("Usage: /pin <id>")
+			return ""
+		}
+		handlePinCommand(args)
+	case "/unpin":
+		if args == "" {
+			fmt.Println("Usage: /unpin <id>")
+			return ""
+		}
+		handleUnpinCommand(args)
+	case "/pins":
+		handlePinsCommand()
+	case "/history":
+		if args == "" {
+			fmt.Println("Usage: /history <id> [from-rev to-rev]")
+			return ""
+		}
+		handleHistoryCommand(args)
+	case "/revert":
+		if len(strings.Fields(args)) != 2 {
+			fmt.Println("Usage: /revert <id> <rev>")
+			return ""
+		}
+		handleRevertCommand(args)
+	case "/new":
+		handleNewConversation()
+	case "/context":
+		printContext(os.Stdout, &conversation, ai.ConversationBudget())
+	case "/save":
+		handleSaveConversation(args)
+	case "/env":
+		handleEnvCommand()
+	case "/prompt":
+		handlePromptCommand(args)
 	case "/ai":
 		handleAIStatus()
 	case "/config":
@@ -83,45 +145,50 @@ func handleSlashCommand(input string) string {
 			fmt.Println("Usage: /run <command>")
 			return ""
 		}
-		handleRunCommand(args)
-	case "/ubuntu", "/debian", "/fedora", "/windows", "/powershell", "/archlinux":
-		if args == "" {
-			fmt.Printf("Usage: %s <question>\n", command)
-			return ""
+		if handleRunCommand(args) {
+			if err := database.RecordUsageByKey(args, database.UsageExec); err != nil {
+				logging.For(logging.CLI).Debug("recording usage", "event", database.UsageExec, "err", err)
+			}
 		}
-		return handlePersonaCommand(command[1:], args)
 	default:
+		if _, ok := ai.LookupPersona(command[1:]); ok {
+			if args == "" {
+				fmt.Printf("Usage: %s <question>\n", command)
+				return ""
+			}
+			return handlePersonaCommand(command[1:], args)
+		}
 		fmt.Printf("Unknown command: %s\n", command)
 		fmt.Println("Type '/help' for available commands")
 	}
 	return ""
 }
 
-func handlePersonaCommand(persona, query string) string {
-	fmt.Printf("🤖 Processing with %s persona...\n", persona)
+func handlePersonaCommand(key, query string) string {
+	persona, ok := ai.LookupPersona(key)
+	if !ok {
+		fmt.Printf("Unknown persona: %s\n", key)
+		return ""
+	}
+	fmt.Printf("🤖 Processing with %s persona...\n", persona.Name)
 
-	// We'll perform a search to get context for the persona
-	results, _, _, err := ai.SmartSearch(query, true)
+	results, err := ai.PersonaContext(persona, query, localPins())
 	if err != nil {
 		fmt.Printf("Error searching: %v\n", err)
 	}
 
-	aiResp, _, err := ai.AskAIPersona(persona, query, results)
+	title := fmt.Sprintf("🤖 AI %s:", persona.Name)
+	aiResp, err := streamAnswer(os.Stdout, title, func(ctx context.Context, onToken func(string)) (string, error) {
+		response, _, err := ai.AskAIPersonaStream(ctx, persona.Key, query, results, onToken)
+		return response, err
+	})
 	if err != nil {
-		fmt.Printf("Error from AI: %v\n", err)
+		if !isCancelled(err) {
+			fmt.Printf("Error from AI: %v\n", err)
+		}
 		return ""
 	}
 
-	fmt.Println()
-	fmt.Printf("🤖 AI %s Persona:\n", strings.Title(persona))
-	fmt.Println("══════════════════════════════════════════════════════════════")
-	fmt.Print(markdown.Render(aiResp))
-	if !strings.HasSuffix(aiResp, "\n") {
-		fmt.Println()
-	}
-	fmt.Println("══════════════════════════════════════════════════════════════")
-	fmt.Println()
-
 	return aiResp
 }
 
@@ -141,19 +208,12 @@ func handleAddCommand(args string) {
 		return
 	}
 
-	exists, err := database.CheckCommandExists(command)
+	action, success, err := saveCommand(command, description, database.LocalOrigin(database.SourceCLI))
 	if err != nil {
-		fmt.Printf("Error checking command: %v\n", err)
-		return
-	}
-	if exists {
-		fmt.Println("Error: This command already exists in the database")
+		fmt.Printf("Error adding command: %v\n", err)
 		return
 	}
-
-	success, err := database.AddCommand(command, description, ai.GetBestEmbedding)
-	if err != nil {
-		fmt.Printf("Error adding command: %v\n", err)
+	if action != dedupe.ActionSave {
 		return
 	}
 
@@ -193,12 +253,270 @@ func handleDeleteCommand(args string) {
 	}
 
 	if success {
-		fmt.Printf("✓ Command %d deleted successfully.\n", id)
+		if database.IsMCP() {
+			fmt.Printf("✓ Command %d deleted successfully.\n", id)
+		} else {
+			fmt.Printf("✓ Command %d moved to the trash. Use /restore %d to undo.\n", id, id)
+		}
 	} else {
 		fmt.Printf("Command %d not found or could not be deleted.\n", id)
 	}
 }
 
+func handleHistoryCommand(args string) {
+	var nums []int
+	for _, f := range strings.Fields(args) {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			fmt.Println("Error: IDs and revisions must be numbers.")
+			return
+		}
+		nums = append(nums, n)
+	}
+	if len(nums) != 1 && len(nums) != 3 {
+		fmt.Println("Usage: /history <id> [from-rev to-rev]")
+		return
+	}
+
+	revs, err := database.ListRevisions(nums[0])
+	if err != nil {
+		fmt.Printf("Error reading history: %v\n", err)
+		return
+	}
+	if len(revs) == 0 {
+		fmt.Printf("Command %d has no recorded revisions.\n", nums[0])
+		return
+	}
+
+	fmt.Println()
+	fmt.Printf("History of command %d:\n", nums[0])
+	fmt.Println("══════════════════════════════════════════════════════════════")
+	for _, r := range revs {
+		author, source := r.Author, r.Source
+		if author == "" {
+			author = "-"
+		}
+		if source == "" {
+			source = "original"
+		}
+		fmt.Printf("  rev %-3d  %s  %-6s  %s\n", r.Rev, r.Created, source, author)
+	}
+
+	// Without explicit revisions, compare the latest two.
+	var from, to *database.Revision
+	if len(nums) == 3 {
+		for i := range revs {
+			if revs[i].Rev == nums[1] {
+				from = &revs[i]
+			}
+			if revs[i].Rev == nums[2] {
+				to = &revs[i]
+			}
+		}
+		if from == nil || to == nil {
+			fmt.Printf("Command %d has no revision %d or %d.\n", nums[0], nums[1], nums[2])
+			return
+		}
+	} else if len(revs) > 1 {
+		from, to = &revs[len(revs)-2], &revs[len(revs)-1]
+	}
+	if from != nil {
+		fmt.Println()
+		fmt.Printf("Changes from rev %d to rev %d:\n", from.Rev, to.Rev)
+		printRevisionDiff(from, to)
+	}
+	fmt.Println()
+	fmt.Println("Use /revert <id> <rev> to restore a revision.")
+}
+
+// printRevisionDiff prints coloured diffs of the command text and the
+// description between two revisions.
+func printRevisionDiff(from, to *database.Revision) {
+	for _, part := range []struct {
+		label, a, b string
+	}{
+		{"command", from.Key, to.Key},
+		{"description", from.Data, to.Data},
+		{"tags", strings.Join(from.Tags, ", "), strings.Join(to.Tags, ", ")},
+	} {
+		lines, err := diff.Lines(part.a, part.b)
+		if err != nil {
+			fmt.Printf("--- %s: revisions too large to diff\n", part.label)
+			continue
+		}
+		if !diff.Changed(lines) {
+			continue
+		}
+		fmt.Printf("--- %s\n", part.label)
+		for _, l := range strings.Split(strings.TrimSuffix(diff.Unified(lines, 2), "\n"), "\n") {
+			switch {
+			case strings.HasPrefix(l, "-"):
+				fmt.Printf(ErrorColor, l+"\n")
+			case strings.HasPrefix(l, "+"):
+				fmt.Printf("\033[1;32m%s\033[0m\n", l)
+			case strings.HasPrefix(l, "@@"):
+				fmt.Printf(DebugColor, l+"\n")
+			default:
+				fmt.Println(l)
+			}
+		}
+	}
+}
+
+func handleRevertCommand(args string) {
+	fields := strings.Fields(args)
+	id, err1 := strconv.Atoi(fields[0])
+	rev, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		fmt.Println("Error: IDs and revisions must be numbers.")
+		return
+	}
+	if err := database.RevertCommand(id, rev, database.LocalOrigin(database.SourceCLI), ai.GetBestEmbedding); err != nil {
+		fmt.Printf("Error reverting command: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Command %d reverted to revision %d.\n", id, rev)
+}
+
+func handleTrashCommand() {
+	records, err := database.ListTrash()
+	if err != nil {
+		fmt.Printf("Error listing trash: %v\n", err)
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("The trash is empty")
+		return
+	}
+
+	fmt.Println()
+	fmt.Printf("Trash (%d commands", len(records))
+	if retention := database.TrashRetention(); retention > 0 {
+		fmt.Printf(", purged after %s", formatRetention(retention))
+	}
+	fmt.Println("):")
+	fmt.Println("══════════════════════════════════════════════════════════════")
+	for _, r := range records {
+		fmt.Printf("\nID: %d - %s\n", r.Id, r.Data)
+		fmt.Printf("    %s\n", truncate(r.Key, 80))
+		fmt.Printf("    deleted %s\n", r.Deleted)
+	}
+	fmt.Println()
+	fmt.Println("Use /restore <id> to bring a command back or /purge to empty the trash.")
+}
+
+func handleRestoreCommand(args string) {
+	id, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil {
+		fmt.Println("Error: Invalid ID. Please provide a number.")
+		return
+	}
+	restored, err := database.RestoreCommand(id)
+	if err != nil {
+		fmt.Printf("Error restoring command: %v\n", err)
+		return
+	}
+	if restored {
+		fmt.Printf("✓ Command %d restored.\n", id)
+	} else {
+		fmt.Printf("Command %d is not in the trash.\n", id)
+	}
+}
+
+func handlePurgeCommand() {
+	fmt.Print("Permanently delete every command in the trash? (y/n): ")
+	reader := bufio.NewReader(os.Stdin)
+	response, _ := reader.ReadString('\n')
+	response = strings.TrimSpace(strings.ToLower(response))
+	if response != "y" && response != "yes" {
+		fmt.Println("Cancelled.")
+		return
+	}
+	n, err := database.PurgeTrash(0)
+	if err != nil {
+		fmt.Printf("Error purging trash: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Permanently deleted %d command(s).\n", n)
+}
+
+func handlePinCommand(args string) {
+	id, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil {
+		fmt.Println("Error: Invalid ID. Please provide a number.")
+		return
+	}
+	added, err := database.PinCommand(database.LocalPinOwner(), id)
+	if err != nil {
+		fmt.Printf("Error pinning command: %v\n", err)
+		return
+	}
+	if added {
+		fmt.Printf("✓ Command %d pinned.\n", id)
+	} else {
+		fmt.Printf("Command %d is already pinned.\n", id)
+	}
+}
+
+func handleUnpinCommand(args string) {
+	id, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil {
+		fmt.Println("Error: Invalid ID. Please provide a number.")
+		return
+	}
+	removed, err := database.UnpinCommand(database.LocalPinOwner(), id)
+	if err != nil {
+		fmt.Printf("Error unpinning command: %v\n", err)
+		return
+	}
+	if removed {
+		fmt.Printf("✓ Command %d unpinned.\n", id)
+	} else {
+		fmt.Printf("Command %d is not pinned.\n", id)
+	}
+}
+
+func handlePinsCommand() {
+	records, err := database.ListPins(database.LocalPinOwner())
+	if err != nil {
+		fmt.Printf("Error listing pins: %v\n", err)
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("No pinned commands. Use /pin <id> to pin one.")
+		return
+	}
+
+	fmt.Println()
+	fmt.Printf("Pinned commands (%d of %d):\n", len(records), database.MaxPins)
+	fmt.Println("══════════════════════════════════════════════════════════════")
+	for _, r := range records {
+		fmt.Printf("\n📌 ID: %d - %s\n", r.Id, r.Data)
+		fmt.Printf("    %s\n", truncate(r.Key, 80))
+	}
+	fmt.Println()
+	fmt.Println("Use /show <id> to open one or /unpin <id> to remove it.")
+}
+
+// localPins returns the IDs pinned by the local user for search ranking.
+// Pins are a ranking hint, so failures just mean no boost.
+func localPins() map[int]bool {
+	pinned, err := database.PinnedIDs(database.LocalPinOwner())
+	if err != nil {
+		logging.For(logging.CLI).Debug("loading pins", "err", err)
+	}
+	return pinned
+}
+
+// formatRetention prints whole days as "30d" and anything shorter as a Go
+// duration.
+func formatRetention(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
 func handleListCommand() {
 	received, err := database.SearchCommands("", "json")
 	if err != nil {
@@ -243,32 +561,41 @@ func clearScreen() {
 }
 
 func handleAIStatus() {
-	if gemini.IsAvailable() {
-		fmt.Println()
-		fmt.Println("🚀 Gemini API is available and active")
-		fmt.Printf("  Model: %s\n", os.Getenv("GEMINIMODEL"))
-		fmt.Printf("  Embedding: %s\n", os.Getenv("GEMINI_EMBEDDING_MODEL"))
-		fmt.Println()
-	}
-
-	if ollama.IsAvailable() {
+	anyAvailable := false
+	for _, p := range ai.Providers() {
+		if !p.Available() {
+			continue
+		}
+		anyAvailable = true
+		models := p.Models()
 		fmt.Println()
-		fmt.Println("🤖 Ollama is available and active")
-		fmt.Printf("  Host: %s\n", os.Getenv("OLLAMA"))
-		fmt.Printf("  Model: %s\n", os.Getenv("MODEL"))
-		fmt.Printf("  Embedding Model: %s\n", os.Getenv("EMBEDDING_MODEL"))
+		fmt.Printf("🤖 %s is available and active\n", p.Name())
+		if h, ok := p.(interface{ Host() string }); ok {
+			fmt.Printf("  Host: %s\n", h.Host())
+		}
+		fmt.Printf("  Model: %s\n", models.Chat)
+		fmt.Printf("  Embedding Model: %s\n", models.Embedding)
 		fmt.Println()
 	}
 
-	if !gemini.IsAvailable() && !ollama.IsAvailable() {
+	if !anyAvailable {
 		fmt.Println()
 		fmt.Println("⚠ No AI providers available")
-		fmt.Println("To enable AI features, set gemini_api in ~/.scmd/config.json or run Ollama locally.")
+		fmt.Println("To enable AI features, set gemini_api or openai_base_url in ~/.scmd/config.json or run Ollama locally.")
 		fmt.Println()
 		return
 	}
 
+	if p := ai.Active(); p != nil {
+		fmt.Printf("Answers come from %s first", p.Name())
+		if ai.Preferred() == "" {
+			fmt.Print(", falling back to the next available provider")
+		}
+		fmt.Println(".")
+	}
 	fmt.Println("AI-enhanced search is automatically used when available.")
+	printCacheStatus(os.Stdout)
+	printBudgetStatus(os.Stdout)
 	fmt.Println()
 }
 func handleConfigShow() {
@@ -310,6 +637,15 @@ func handleConfigShow() {
 	fmt.Println()
 	fmt.Println("  AI Settings:")
 	fmt.Printf("    agent:                  %s\n", cfg.Agent)
+	fmt.Printf("    ai_priority:            %s\n", cfg.AIPriority)
+	fmt.Printf("    conversation_max_tokens: %s\n", cfg.ConversationMaxTokens)
+	fmt.Printf("    system_context:         %s\n", cfg.SystemContext)
+	fmt.Printf("    ai_cache:               %s\n", cfg.AICache)
+	fmt.Printf("    ai_cache_ttl:           %s\n", cfg.AICacheTTL)
+	fmt.Printf("    ai_cache_max_size:      %s\n", cfg.AICacheMaxSize)
+	fmt.Printf("    ai_prices:              %s\n", cfg.AIPrices)
+	fmt.Printf("    ai_daily_budget:        %s\n", cfg.AIDailyBudget)
+	fmt.Printf("    ai_budget_action:       %s\n", cfg.AIBudgetAction)
 	fmt.Println()
 	fmt.Println("  Gemini:")
 	fmt.Printf("    gemini_api:             %s\n", mask(cfg.GeminiAPI))
@@ -322,6 +658,12 @@ func handleConfigShow() {
 	fmt.Printf("    embedding_model:        %s\n", cfg.EmbeddingModel)
 	fmt.Printf("    embedding_dim:          %s\n", cfg.EmbeddingDim)
 	fmt.Println()
+	fmt.Println("  OpenAI-compatible:")
+	fmt.Printf("    openai_base_url:        %s\n", cfg.OpenAIBaseURL)
+	fmt.Printf("    openai_api_key:         %s\n", mask(cfg.OpenAIAPIKey))
+	fmt.Printf("    openai_model:           %s\n", cfg.OpenAIModel)
+	fmt.Printf("    openai_embedding_model: %s\n", cfg.OpenAIEmbeddingModel)
+	fmt.Println()
 	fmt.Println()
 	fmt.Println("══════════════════════════════════════════════════════════════")
 	fmt.Println()
@@ -367,6 +709,8 @@ func handleShowCommand(args string) (string, string) {
 		fmt.Printf("Error: %v\n", err)
 		return "", ""
 	}
+	showID = record.Id
+	recordUsage(record.Id, database.UsageView)
 
 	// Extract the original query from the description if it was AI-generated.
 	originalQuery := ""
@@ -416,7 +760,9 @@ func handleShowCommand(args string) (string, string) {
 	return content, originalQuery
 }
 
-func handleRunCommand(args string) {
+// handleRunCommand runs a non-interactive system command and reports
+// whether it was started; blocked interactive commands are refused.
+func handleRunCommand(args string) bool {
 	fmt.Println()
 	fmt.Println("═══════════════════════════════════════════════════════════════")
 	fmt.Println("  SYSTEM COMMAND EXECUTION")
@@ -428,7 +774,7 @@ func handleRunCommand(args string) {
 	cmdParts := strings.Fields(args)
 	if len(cmdParts) == 0 {
 		fmt.Println("Error: No command provided")
-		return
+		return false
 	}
 
 	blockedCommands := []string{
@@ -452,10 +798,18 @@ func handleRunCommand(args string) {
 			fmt.Println()
 			fmt.Println("═══════════════════════════════════════════════════════════════")
 			fmt.Println()
-			return
+			return false
 		}
 	}
 
+	if !confirmForEnvironment(bufio.NewReader(os.Stdin), os.Stdout, sysenv.Current(), args) {
+		fmt.Println("Cancelled.")
+		fmt.Println()
+		fmt.Println("═══════════════════════════════════════════════════════════════")
+		fmt.Println()
+		return false
+	}
+
 	// Run through the user's login shell so that shell built-ins,
 	// aliases, functions (e.g. nvm), and the full PATH/environment
 	// from ~/.bashrc / ~/.profile are available.
@@ -498,6 +852,7 @@ func handleRunCommand(args string) {
 	fmt.Println()
 	fmt.Println("═══════════════════════════════════════════════════════════════")
 	fmt.Println()
+	return true
 }
 
 // enrichPathEnv returns a copy of env with common system directories merged
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gcclinux/scmd/internal/ai"
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/dedupe"
+)
+
+// conversation holds the AI turns of the interactive session. Once it has
+// a turn, plain input is sent as a follow-up question with the earlier
+// turns instead of starting a new search; /new resets it.
+var conversation ai.Conversation
+
+// performFollowUp asks question as the next turn of the conversation and
+// prints the answer as it streams in. A fresh answer skips the AI cache.
+func performFollowUp(question string, fresh bool) string {
+	answer, err := streamAnswer(os.Stdout, "🤖 AI Assistant:", func(ctx context.Context, onToken func(string)) (string, error) {
+		if fresh {
+			ctx = ai.BypassCache(ctx)
+		}
+		_, response, _, err := ai.ConverseStream(ctx, &conversation, question, localPins(), onToken)
+		return response, err
+	})
+	if err != nil {
+		if !isCancelled(err) {
+			fmt.Printf("Error from AI: %v\n", err)
+		}
+		return ""
+	}
+	return answer
+}
+
+func handleNewConversation() {
+	conversation.Reset()
+	fmt.Println("✓ Started a new conversation.")
+}
+
+// printContext shows the conversation turns and which of them fit in the
+// token budget sent with the next question.
+func printContext(w io.Writer, conv *ai.Conversation, budget int) {
+	if conv.Len() == 0 {
+		fmt.Fprintln(w, "The conversation is empty; the next question starts a new search.")
+		return
+	}
+
+	sent := len(conv.Recent(budget))
+	total := 0
+	for _, t := range conv.Turns {
+		total += t.Tokens()
+	}
+	fmt.Fprintf(w, "Conversation: %d turn(s), ~%d tokens. Sent with the next question: %d turn(s) (budget %d tokens).\n",
+		conv.Len(), total, sent, budget)
+	fmt.Fprintln(w, "──────────────────────────────────────────────────────────────")
+	for i, t := range conv.Turns {
+		mark := " "
+		if i >= conv.Len()-sent {
+			mark = "✓"
+		}
+		fmt.Fprintf(w, "%s %2d. %s (~%d tokens)\n", mark, i+1, truncate(t.Question, 60), t.Tokens())
+	}
+}
+
+// handleSaveConversation saves the whole conversation as one markdown
+// record.
+func handleSaveConversation(description string) {
+	if conversation.Len() == 0 {
+		fmt.Println("Nothing to save: the conversation is empty.")
+		return
+	}
+	if description == "" {
+		description = fmt.Sprintf("Conversation: %s", conversation.Turns[0].Question)
+	}
+
+	action, _, err := saveCommand(conversation.Markdown(), description, database.LocalOrigin(database.SourceAI))
+	if err != nil {
+		fmt.Printf("Error saving conversation: %v\n", err)
+		return
+	}
+	if action == dedupe.ActionSave {
+		fmt.Printf("✓ Conversation (%d turn(s)) saved to database!\n", conversation.Len())
+	}
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/ai"
+)
+
+func TestPrintContext(t *testing.T) {
+	var out strings.Builder
+	var c ai.Conversation
+	printContext(&out, &c, 100)
+	if !strings.Contains(out.String(), "empty") {
+		t.Errorf("empty conversation output = %q", out.String())
+	}
+
+	c.Add("list running docker containers", strings.Repeat("x", 400))
+	c.Add("now do the same for podman", "Use podman ps.")
+	out.Reset()
+	printContext(&out, &c, 50)
+	got := out.String()
+	if !strings.Contains(got, "2 turn(s)") || !strings.Contains(got, "next question: 1 turn(s)") {
+		t.Errorf("summary missing:\n%s", got)
+	}
+	if !strings.Contains(got, "   1. list running docker containers") || !strings.Contains(got, "✓  2. now do the same for podman") {
+		t.Errorf("turn list wrong:\n%s", got)
+	}
+}
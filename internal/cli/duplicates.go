@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gcclinux/scmd/internal/ai"
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/dedupe"
+)
+
+// errDuplicate is returned by saveCommand when the command duplicates stored
+// ones and stdin is not a terminal to ask on.
+var errDuplicate = errors.New("similar commands already exist; nothing saved")
+
+// stdinIsTerminal reports whether the user can answer prompts on stdin. It
+// is swapped out by tests.
+var stdinIsTerminal = func() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// saveCommand stores command unless it duplicates stored commands, in which
+// case the nearest ones are listed and the user chooses to merge, skip or
+// save anyway. Without a terminal nothing is saved and errDuplicate is
+// returned. It returns the action taken and whether the store changed;
+// merge and skip outcomes are reported here, a plain save is left to the
+// caller.
+func saveCommand(command, description string, origin database.Origin) (string, bool, error) {
+	embed := dedupe.CacheEmbedding(ai.GetBestEmbedding)
+	matches, err := dedupe.Find(command, description, embed)
+	if err != nil {
+		return "", false, fmt.Errorf("error checking for duplicates: %v", err)
+	}
+
+	action := dedupe.ActionSave
+	if len(matches) > 0 {
+		printDuplicates(os.Stdout, matches)
+		if !stdinIsTerminal() {
+			return dedupe.ActionSkip, false, errDuplicate
+		}
+		action = promptDuplicateAction(bufio.NewReader(os.Stdin), os.Stdout, matches)
+	}
+	changed, err := dedupe.Apply(action, matches, command, description, origin, embed)
+	if err != nil {
+		return action, false, err
+	}
+
+	switch action {
+	case dedupe.ActionMerge:
+		if changed {
+			fmt.Printf("✓ Description merged into command ID %d\n", matches[0].Record.Id)
+		} else {
+			fmt.Printf("Command ID %d already has that description; nothing changed.\n", matches[0].Record.Id)
+		}
+	case dedupe.ActionSkip:
+		fmt.Println("Skipped; nothing saved.")
+	}
+	return action, changed, nil
+}
+
+func printDuplicates(w io.Writer, matches []dedupe.Match) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "⚠ Similar commands already exist:")
+	for _, m := range matches {
+		fmt.Fprintf(w, "  [%d] %s  (%s)\n", m.Record.Id, truncate(m.Record.Key, 60), m.Reason())
+		fmt.Fprintf(w, "       %s\n", truncate(m.Record.Data, 70))
+	}
+	fmt.Fprintln(w)
+}
+
+// promptDuplicateAction asks how to handle a duplicate. Saving anyway is not
+// offered for an exact duplicate. Anything unrecognised, including end of
+// input, skips.
+func promptDuplicateAction(r *bufio.Reader, w io.Writer, matches []dedupe.Match) string {
+	if dedupe.HasExact(matches) {
+		fmt.Fprintf(w, "[m]erge description into ID %d or [s]kip? (m/S): ", matches[0].Record.Id)
+	} else {
+		fmt.Fprintf(w, "[m]erge description into ID %d, [s]kip or save [a]nyway? (m/S/a): ", matches[0].Record.Id)
+	}
+	response, _ := r.ReadString('\n')
+	switch strings.TrimSpace(strings.ToLower(response)) {
+	case "m", "merge":
+		return dedupe.ActionMerge
+	case "a", "anyway", "save":
+		if !dedupe.HasExact(matches) {
+			return dedupe.ActionSave
+		}
+	}
+	return dedupe.ActionSkip
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/dedupe"
+)
+
+func TestPromptDuplicateAction(t *testing.T) {
+	similar := []dedupe.Match{{Record: database.CommandRecord{Id: 4}, Similarity: 0.97}}
+	exact := []dedupe.Match{{Record: database.CommandRecord{Id: 4}, Exact: true}}
+	tests := []struct {
+		input   string
+		matches []dedupe.Match
+		want    string
+	}{
+		{"m\n", similar, dedupe.ActionMerge},
+		{"merge\n", exact, dedupe.ActionMerge},
+		{"a\n", similar, dedupe.ActionSave},
+		{"a\n", exact, dedupe.ActionSkip},
+		{"s\n", similar, dedupe.ActionSkip},
+		{"\n", similar, dedupe.ActionSkip},
+		{"", similar, dedupe.ActionSkip},
+	}
+	for _, tt := range tests {
+		got := promptDuplicateAction(bufio.NewReader(strings.NewReader(tt.input)), io.Discard, tt.matches)
+		if got != tt.want {
+			t.Errorf("input %q (exact %v) = %q, want %q", tt.input, tt.matches[0].Exact, got, tt.want)
+		}
+	}
+}
+
+func TestSaveCommand_DuplicateWithoutTerminal(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DB_TYPE", "sqlite")
+	if err := os.MkdirAll(filepath.Join(home, ".scmd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InitSQLiteDB(); err != nil {
+		t.Fatalf("InitSQLiteDB: %v", err)
+	}
+	t.Cleanup(database.CloseDB)
+	database.AddCommand("ls -la", "list files", database.Origin{}, nil)
+
+	orig := stdinIsTerminal
+	defer func() { stdinIsTerminal = orig }()
+	stdinIsTerminal = func() bool { return false }
+
+	action, changed, err := saveCommand("ls -la", "list all files", database.Origin{})
+	if !errors.Is(err, errDuplicate) || changed || action != dedupe.ActionSkip {
+		t.Errorf("saveCommand = %q, %v, %v; want skip with errDuplicate", action, changed, err)
+	}
+	if r, _ := database.GetCommandByID(1); r.Data != "list files" {
+		t.Errorf("stored description = %q, want it unchanged", r.Data)
+	}
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gcclinux/scmd/internal/sysenv"
+)
+
+func handleEnvCommand() {
+	printEnv(os.Stdout, sysenv.Current())
+}
+
+// printEnv shows the detected system that AI prompts and /run use.
+func printEnv(w io.Writer, env sysenv.Env) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "🖥  System Environment")
+	fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+	fmt.Fprintf(w, "  OS:              %s/%s\n", env.OS, env.Arch)
+	fmt.Fprintf(w, "  Shell:           %s\n", orNotSet(env.Shell))
+	if !env.Detected {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "  System detection is off (\"system_context\": \"false\"); AI prompts")
+		fmt.Fprintln(w, "  and /run do not use the distribution or installed tools.")
+		fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+		fmt.Fprintln(w)
+		return
+	}
+	fmt.Fprintf(w, "  Distribution:    %s\n", orNotSet(env.Distro))
+	fmt.Fprintf(w, "  Package manager: %s\n", orNotSet(env.PackageManager))
+	fmt.Fprintf(w, "  Tools:           %s\n", orNotSet(strings.Join(env.Tools, ", ")))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  Sent to the AI with every question; set \"system_context\": \"false\" to stop.")
+	fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+	fmt.Fprintln(w)
+}
+
+func orNotSet(s string) string {
+	if s == "" {
+		return "(not detected)"
+	}
+	return s
+}
+
+// confirmForEnvironment warns about tools in command that are not
+// installed, such as apt on Fedora, and asks whether to run it anyway.
+// It reports true when there is nothing to warn about.
+func confirmForEnvironment(r *bufio.Reader, w io.Writer, env sysenv.Env, command string) bool {
+	warnings := env.CheckCommand(command)
+	if len(warnings) == 0 {
+		return true
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "⚠️  %s\n", warning)
+	}
+	fmt.Fprint(w, "Run anyway? (y/n): ")
+	response, _ := r.ReadString('\n')
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
+}
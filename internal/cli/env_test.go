@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/sysenv"
+)
+
+func TestConfirmForEnvironment(t *testing.T) {
+	env := sysenv.Env{Distro: "Fedora Linux 40", PackageManager: "dnf", Tools: []string{"dnf"}, Detected: true}
+
+	var out strings.Builder
+	if !confirmForEnvironment(bufio.NewReader(strings.NewReader("")), &out, env, "sudo dnf upgrade") {
+		t.Error("a command using installed tools should run without asking")
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	if confirmForEnvironment(bufio.NewReader(strings.NewReader("n\n")), &out, env, "sudo apt update") {
+		t.Error("answering n should cancel")
+	}
+	if got := out.String(); !strings.Contains(got, "'apt' is not available; Fedora Linux 40 uses dnf") || !strings.Contains(got, "Run anyway?") {
+		t.Errorf("output = %q", got)
+	}
+	if !confirmForEnvironment(bufio.NewReader(strings.NewReader("y\n")), &out, env, "sudo apt update") {
+		t.Error("answering y should run the command")
+	}
+}
+
+func TestPrintEnv(t *testing.T) {
+	var out strings.Builder
+	printEnv(&out, sysenv.Env{OS: "linux", Arch: "amd64", Shell: "bash", Distro: "Ubuntu 24.04 LTS", PackageManager: "apt", Tools: []string{"apt", "git"}, Detected: true})
+	got := out.String()
+	for _, want := range []string{"linux/amd64", "Ubuntu 24.04 LTS", "Package manager: apt", "apt, git"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+
+	out.Reset()
+	printEnv(&out, sysenv.Env{OS: "linux", Arch: "amd64"})
+	if got := out.String(); !strings.Contains(got, "System detection is off") || strings.Contains(got, "Distribution") {
+		t.Errorf("disabled output:\n%s", got)
+	}
+}
@@ -2,8 +2,11 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
+	"github.com/gcclinux/scmd/internal/ai"
 	"github.com/gcclinux/scmd/internal/updater"
 )
 
@@ -31,13 +34,22 @@ func printInteractiveHelp() {
 	fmt.Println("  /delete <id>          - Delete a command by ID                │  /embeddings           - Check embedding statistics")
 	fmt.Println("  /show <id>            - Show command and description by ID    │  /generate             - Generate embeddings for all commands")
 	fmt.Println("  /list                 - List recent commands                  │  /clear or /cls        - Clear the screen")
+	fmt.Println("  /trash                - List deleted commands                 │  /restore <id>         - Restore a deleted command")
+	fmt.Println("  /purge                - Permanently empty the trash           │  /history <id>         - Show revisions and the latest diff")
+	fmt.Println("  /revert <id> <rev>    - Restore an earlier revision           │  /pins                 - List your pinned commands")
+	fmt.Println("  /pin <id>             - Pin a command to rank it first        │  /unpin <id>           - Remove a pin")
+	fmt.Println("  /prompt show [name]   - Show the AI prompt templates          │  /prompt edit <name>   - Customise a prompt template")
+	fmt.Println("  /env                  - Show the detected system and tools    │  /run <command>        - Run a system command")
 	fmt.Println("  /help or /?           - Show this help message                │  /exit, /quit, or /q   - Exit interactive mode")
 	fmt.Println("──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────")
 	fmt.Println("AI Personas (Focused Context):")
 	fmt.Println("──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────")
-	fmt.Println("  /ubuntu <query>       - Ubuntu expert persona                 │  /powershell <query>   - PowerShell guru persona")
-	fmt.Println("  /debian <query>       - Debian expert persona                 │  /archlinux <query>    - Arch Linux master persona")
-	fmt.Println("  /fedora <query>       - Fedora expert persona                 │  /windows <query>      - Windows admin persona")
+	printPersonaHelp(os.Stdout, ai.PersonaList())
+	fmt.Println("──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────")
+	fmt.Println("Conversation (after an AI answer, plain input is a follow-up question):")
+	fmt.Println("──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────")
+	fmt.Println("  /context              - Show the turns sent with a follow-up  │  /new                  - Start a new conversation")
+	fmt.Println("  /save [description]   - Save the conversation as a record")
 	fmt.Println("──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────")
 	fmt.Println("AI Response Feedback:")
 	fmt.Println("──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────")
@@ -48,6 +60,24 @@ func printInteractiveHelp() {
 	fmt.Println()
 }
 
+// printPersonaHelp lists the persona slash commands. Personas cannot take
+// the key of a built-in command; see ai.ReservedPersonaKeys.
+func printPersonaHelp(w io.Writer, personas []ai.AIPersona) {
+	for _, p := range personas {
+		usage := fmt.Sprintf("/%s <query>", p.Key)
+		fmt.Fprintf(w, "  %-21s - %s\n", usage, truncate(p.Name+": "+p.Description, 96))
+	}
+	fmt.Fprintf(w, "  Define your own personas in %s\n", personasFileHint())
+}
+
+// personasFileHint names the personas file in use, or the default one.
+func personasFileHint() string {
+	if path := ai.PersonasPath(); path != "" {
+		return path
+	}
+	return "~/.scmd/personas.yaml"
+}
+
 // detectCommandLanguage detects the appropriate language tag for code blocks.
 func detectCommandLanguage(command, description string) string {
 	combined := strings.ToLower(command + " " + description)
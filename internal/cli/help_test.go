@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/ai"
+)
+
+func TestPrintPersonaHelp(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var out strings.Builder
+	printPersonaHelp(&out, []ai.AIPersona{
+		{Key: "k8s", Name: "Kubernetes Operator", Description: "kubectl and helm"},
+	})
+	got := out.String()
+	if !strings.Contains(got, "/k8s <query>") || !strings.Contains(got, "Kubernetes Operator: kubectl and helm") {
+		t.Errorf("persona missing from help:\n%s", got)
+	}
+	if !strings.Contains(got, "~/.scmd/personas.yaml") {
+		t.Errorf("personas file hint missing:\n%s", got)
+	}
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -98,6 +99,15 @@ func PrintHelp(name string) {
 	fmt.Printf(NoticeColor, "*** Save new command with description in the local database\n\r")
 	fmt.Println("Usage: \t", name, "--save [command] [description]")
 	fmt.Println()
+	fmt.Printf(NoticeColor, "*** Delete, retag, re-embed or export commands selected by query, tag or ID range\n\r")
+	fmt.Println("Usage: \t", name, "bulk [delete|retag|reembed|export] --query [words] --dry-run")
+	fmt.Println()
+	fmt.Printf(NoticeColor, "*** List the most viewed, copied and executed commands\n\r")
+	fmt.Println("Usage: \t", name, "stats --top [number] --since [30d]")
+	fmt.Println()
+	fmt.Printf(NoticeColor, "*** Report AI token usage, latency and estimated cost per provider and model\n\r")
+	fmt.Println("Usage: \t", name, "usage --since [7d]")
+	fmt.Println()
 	fmt.Printf(NoticeColor, "*** Generate embeddings for all commands (enables vector search)\n\r")
 	fmt.Println("Usage: \t", name, "--generate-embeddings")
 	fmt.Println()
@@ -111,6 +121,12 @@ func PrintHelp(name string) {
 	fmt.Printf(NoticeColor, "*** Interactive Gemini AI server setup (prompts for API key, model, embedding config)\n\r")
 	fmt.Println("Usage: \t", name, "--server-gemini")
 	fmt.Println()
+	fmt.Printf(NoticeColor, "*** Set the log level (debug, info, warn, error) for any command\n\r")
+	fmt.Println("Usage: \t", name, "--log-level debug", "[command]")
+	fmt.Println()
+	fmt.Printf(NoticeColor, "*** Ask the AI providers directly instead of using cached answers and embeddings\n\r")
+	fmt.Println("Usage: \t", name, "--no-cache", "[command]")
+	fmt.Println()
 }
 
 // PrintWrongSyntax shows usage error.
@@ -125,8 +141,13 @@ func GetName() string {
 	return filepath.Base(os.Args[0])
 }
 
-// SaveCmd saves a command with description to the database.
-func SaveCmd(cmd, details string) {
+// ExitDuplicate is the exit status of `scmd --save` when the command
+// duplicates stored ones and stdin is not a terminal to ask how to proceed.
+const ExitDuplicate = 3
+
+// SaveCmd saves a command with description to the database and returns the
+// process exit code.
+func SaveCmd(cmd, details string) int {
 	details = strings.TrimSpace(details)
 	if strings.HasPrefix(details, "```") {
 		if idx := strings.Index(details, "\n"); idx != -1 {
@@ -142,16 +163,24 @@ func SaveCmd(cmd, details string) {
 
 	if err := database.InitDB(); err != nil {
 		fmt.Printf("Failed to connect to database: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 	defer database.CloseDB()
 
-	status, err := database.AddCommand(cmd, details, ai.GetBestEmbedding)
-	if err != nil {
+	_, status, err := saveCommand(cmd, details, database.LocalOrigin(database.SourceCLI))
+	switch {
+	case errors.Is(err, errDuplicate):
+		fmt.Println("Not saved: similar commands exist and there is no terminal to choose merge, skip or save anyway.")
+		fmt.Println("returned: ( false )")
+		fmt.Println()
+		return ExitDuplicate
+	case err != nil:
 		fmt.Println("Error saving command:", err)
 		fmt.Println("returned: ( false )")
-	} else {
-		fmt.Println("returned: (", status, ")")
+		fmt.Println()
+		return 1
 	}
+	fmt.Println("returned: (", status, ")")
 	fmt.Println()
+	return 0
 }
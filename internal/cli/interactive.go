@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,13 +11,11 @@ import (
 	"strings"
 
 	"github.com/gcclinux/scmd/internal/ai"
-	"github.com/gcclinux/scmd/internal/ai/gemini"
-	"github.com/gcclinux/scmd/internal/ai/ollama"
 	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/dedupe"
 	"github.com/gcclinux/scmd/internal/markdown"
 	"github.com/gcclinux/scmd/internal/search"
 	"github.com/gcclinux/scmd/internal/updater"
-	"github.com/gcclinux/scmd/internal/util"
 )
 
 // StartInteractiveMode starts the interactive CLI prompt.
@@ -35,6 +34,7 @@ func StartInteractiveMode() {
 	var lastQuery string
 	var lastCodeBlocks []string
 	var lastFromShow bool
+	var lastShowID int // record shown by /show, 0 for AI responses
 
 	for {
 		fmt.Print("scmd> ")
@@ -67,11 +67,11 @@ func StartInteractiveMode() {
 		// Feedback on last AI response
 		if lastAIResponse != "" && isFeedbackInput(input, len(lastCodeBlocks)) {
 			if input == "s" && !lastFromShow {
-				if _, err := database.AddCommand(lastAIResponse,
+				if action, _, err := saveCommand(lastAIResponse,
 					fmt.Sprintf("AI-generated response for: %s", lastQuery),
-					ai.GetBestEmbedding); err != nil {
+					database.LocalOrigin(database.SourceAI)); err != nil {
 					fmt.Printf("Error saving response: %v\n", err)
-				} else {
+				} else if action == dedupe.ActionSave {
 					fmt.Println("✓ Response saved to database!")
 					fmt.Println()
 				}
@@ -79,14 +79,16 @@ func StartInteractiveMode() {
 				lastQuery = ""
 				lastCodeBlocks = nil
 				lastFromShow = false
+				lastShowID = 0
 			} else if input == "n" {
 				fmt.Println("Regenerating response...")
 				fmt.Println()
-				aiResp := regenerateAIResponse(lastQuery)
+				aiResp := regenerateAIResponse(lastQuery, lastAIResponse)
 				if aiResp != "" {
 					lastAIResponse = aiResp
 					lastCodeBlocks = ExtractCodeBlocks(aiResp)
 					lastFromShow = false
+					lastShowID = 0
 					fmt.Println(buildFeedbackPrompt(len(lastCodeBlocks), false))
 				} else {
 					fmt.Println("Failed to regenerate response.")
@@ -95,6 +97,7 @@ func StartInteractiveMode() {
 					lastQuery = ""
 					lastCodeBlocks = nil
 					lastFromShow = false
+					lastShowID = 0
 				}
 			} else if strings.HasPrefix(input, "x") {
 				arg := strings.TrimSpace(strings.TrimPrefix(input, "x"))
@@ -105,7 +108,7 @@ func StartInteractiveMode() {
 						// no code blocks — re-display prompt
 						fmt.Println(buildFeedbackPrompt(len(lastCodeBlocks), lastFromShow))
 					case 1:
-						handleRunCommand(lastCodeBlocks[0])
+						runCodeBlock(lastCodeBlocks[0], lastShowID)
 						fmt.Println(buildFeedbackPrompt(len(lastCodeBlocks), lastFromShow))
 					default:
 						fmt.Printf("Multiple code blocks found. Type 1 to %d to execute, or x <number>.\n", len(lastCodeBlocks))
@@ -120,7 +123,7 @@ func StartInteractiveMode() {
 						fmt.Println(errMsg)
 						fmt.Println(buildFeedbackPrompt(len(lastCodeBlocks), lastFromShow))
 					} else {
-						handleRunCommand(lastCodeBlocks[n-1])
+						runCodeBlock(lastCodeBlocks[n-1], lastShowID)
 						fmt.Println(buildFeedbackPrompt(len(lastCodeBlocks), lastFromShow))
 					}
 				}
@@ -129,7 +132,7 @@ func StartInteractiveMode() {
 				if errMsg := validateExecuteIndex(n, len(lastCodeBlocks)); errMsg != "" {
 					fmt.Println(errMsg)
 				} else {
-					handleRunCommand(lastCodeBlocks[n-1])
+					runCodeBlock(lastCodeBlocks[n-1], lastShowID)
 				}
 				fmt.Println(buildFeedbackPrompt(len(lastCodeBlocks), lastFromShow))
 			}
@@ -155,6 +158,10 @@ func StartInteractiveMode() {
 		if aiResp != "" {
 			lastAIResponse = aiResp
 			lastFromShow = strings.HasPrefix(input, "/show")
+			lastShowID = 0
+			if lastFromShow {
+				lastShowID = showID
+			}
 			if lastFromShow && showOriginalQuery != "" {
 				lastQuery = showOriginalQuery
 				showOriginalQuery = ""
@@ -168,10 +175,19 @@ func StartInteractiveMode() {
 			lastQuery = ""
 			lastCodeBlocks = nil
 			lastFromShow = false
+			lastShowID = 0
 		}
 	}
 }
 
+// runCodeBlock runs a code block from the last response. Blocks of a stored
+// record (recordID > 0) count as executing that record.
+func runCodeBlock(block string, recordID int) {
+	if handleRunCommand(block) && recordID > 0 {
+		recordUsage(recordID, database.UsageExec)
+	}
+}
+
 func printWelcome() {
 	fmt.Println()
 	green := "\033[32m"
@@ -190,28 +206,12 @@ func printWelcome() {
 	fmt.Println("  ╚════════════════════════════════════════════════════════════╝")
 	fmt.Println()
 
-	preferred := strings.ToLower(os.Getenv("AGENT"))
-	switch preferred {
-	case "ollama":
-		if ollama.IsAvailable() {
-			fmt.Println("🤖 Ollama: Active (vector search enabled)")
-		} else {
-			fmt.Println("⚠️  Preferred agent 'ollama' is not available")
-		}
-	case "gemini":
-		if gemini.IsAvailable() {
-			fmt.Println("🚀 Gemini API: Active (vector search enabled)")
-		} else {
-			fmt.Println("⚠️  Preferred agent 'gemini' is not available")
-		}
-	default:
-		if ollama.IsAvailable() {
-			fmt.Println("🤖 Ollama: Active (vector search enabled)")
-		} else if gemini.IsAvailable() {
-			fmt.Println("🚀 Gemini API: Active (vector search enabled)")
-		} else {
-			fmt.Println("⚠️  No embedding provider (traditional search only)")
-		}
+	if p := ai.Active(); p != nil {
+		fmt.Printf("🤖 %s: Active (vector search enabled)\n", p.Name())
+	} else if name := ai.Preferred(); name != "" {
+		fmt.Printf("⚠️  Preferred agent '%s' is not available\n", name)
+	} else {
+		fmt.Println("⚠️  No embedding provider (traditional search only)")
 	}
 	fmt.Println()
 	fmt.Println("Type '/help' or 'help' for available commands")
@@ -231,6 +231,10 @@ func processInteractiveCommand(input string) string {
 		return handleSlashCommand(input)
 	}
 
+	if conversation.Len() > 0 {
+		return performFollowUp(input, false)
+	}
+
 	keywords := extractKeywords(input)
 	if keywords == "" {
 		fmt.Println("Could not extract search terms. Try using /search <pattern>")
@@ -259,23 +263,22 @@ func extractKeywords(input string) string {
 }
 
 func performInteractiveSearch(pattern string) string {
-	results, aiResponse, _, err := ai.SmartSearch(pattern, true)
+	var results []database.CommandRecord
+	aiResponse, err := streamAnswer(os.Stdout, "🤖 AI Assistant:", func(ctx context.Context, onToken func(string)) (string, error) {
+		var response string
+		var err error
+		results, response, _, err = ai.SmartSearchStream(ctx, pattern, localPins(), nil, onToken)
+		return response, err
+	})
+	if isCancelled(err) {
+		return ""
+	}
 	if err != nil {
 		fmt.Printf("Error searching: %v\n", err)
 		return ""
 	}
-
-	fmt.Println()
-
 	if aiResponse != "" {
-		fmt.Println("🤖 AI Assistant:")
-		fmt.Println("══════════════════════════════════════════════════════════════")
-		fmt.Print(markdown.Render(aiResponse))
-		if !strings.HasSuffix(aiResponse, "\n") {
-			fmt.Println()
-		}
-		fmt.Println("══════════════════════════════════════════════════════════════")
-		fmt.Println()
+		conversation.Add(pattern, aiResponse)
 		return aiResponse
 	}
 
@@ -283,17 +286,19 @@ func performInteractiveSearch(pattern string) string {
 		return ""
 	}
 
+	fmt.Println("✓ Found high-quality matches in database")
+	fmt.Println()
 	fmt.Printf("Found %d result(s) for: %s\n", len(results), pattern)
 
-	scored := search.ScoreCommands(results, pattern)
+	scored := search.ScoreCommandsPinned(results, pattern, localPins())
 
 	var filteredResults []database.CommandRecord
 	var filteredScored []search.CommandScore
 	minMatchThreshold := 60
 
-	for i, s := range scored {
+	for _, s := range scored {
 		if s.Score >= minMatchThreshold {
-			filteredResults = append(filteredResults, results[i])
+			filteredResults = append(filteredResults, s.Record)
 			filteredScored = append(filteredScored, s)
 		}
 	}
@@ -337,7 +342,16 @@ func performInteractiveSearch(pattern string) string {
 	return ""
 }
 
-func regenerateAIResponse(query string) string {
+// regenerateAIResponse asks again for a new answer to query, bypassing the
+// AI cache. When previous is the latest turn of the conversation, that turn
+// is replaced.
+func regenerateAIResponse(query, previous string) string {
+	if n := conversation.Len(); n > 0 && conversation.Turns[n-1].Answer == previous {
+		question := conversation.Turns[n-1].Question
+		conversation.DropLast()
+		return performFollowUp(question, true)
+	}
+
 	cleanedQuery := extractKeywords(query)
 	if cleanedQuery == "" {
 		cleanedQuery = query
@@ -352,7 +366,7 @@ func regenerateAIResponse(query string) string {
 	var results []database.CommandRecord
 	json.Unmarshal(jsonData, &results)
 
-	scored := search.ScoreCommands(results, cleanedQuery)
+	scored := search.ScoreCommandsPinned(results, cleanedQuery, localPins())
 	var contextResults []database.CommandRecord
 	for _, s := range search.GetBestMatches(scored, 5) {
 		if s.Score > 0 {
@@ -360,73 +374,14 @@ func regenerateAIResponse(query string) string {
 		}
 	}
 
-	var aiResponse string
-	preferredAgent := strings.ToLower(os.Getenv("AGENT"))
-
-	tryOllama := func() bool {
-		if !ollama.IsAvailable() {
-			return false
-		}
-		util.StartSpinner()
-		defer util.StopSpinner()
-		resp, _, err := ollama.Ask(query, contextResults)
-		if err == nil && resp != "" {
-			aiResponse = resp
-			fmt.Println("🤖 AI Assistant:")
-			fmt.Println("══════════════════════════════════════════════════════════════")
-			fmt.Print(markdown.Render(aiResponse))
-			if !strings.HasSuffix(aiResponse, "\n") {
-				fmt.Println()
-			}
-			fmt.Println("══════════════════════════════════════════════════════════════")
-			fmt.Println()
-			return true
-		}
-		return false
-	}
-
-	tryGemini := func() bool {
-		if !gemini.IsAvailable() {
-			return false
-		}
-		util.StartSpinner()
-		defer util.StopSpinner()
-		resp, _, err := gemini.Ask(query, contextResults)
-		if err == nil && resp != "" {
-			aiResponse = resp
-			fmt.Println("🤖 AI Assistant:")
-			fmt.Println("══════════════════════════════════════════════════════════════")
-			fmt.Print(markdown.Render(aiResponse))
-			if !strings.HasSuffix(aiResponse, "\n") {
-				fmt.Println()
-			}
-			fmt.Println("══════════════════════════════════════════════════════════════")
-			fmt.Println()
-			return true
-		}
-		return false
-	}
-
-	if preferredAgent == "ollama" {
-		if tryOllama() {
-			return aiResponse
-		}
-	} else if preferredAgent == "gemini" {
-		if tryGemini() {
-			return aiResponse
-		}
-	}
-
-	if preferredAgent == "" {
-		if tryOllama() {
-			return aiResponse
-		}
-		if tryGemini() {
-			return aiResponse
-		}
+	aiResponse, err := streamAnswer(os.Stdout, "🤖 AI Assistant:", func(ctx context.Context, onToken func(string)) (string, error) {
+		response, _, err := ai.AskAIStream(ai.BypassCache(ctx), query, contextResults, onToken)
+		return response, err
+	})
+	if err != nil {
+		return ""
 	}
-
-	return ""
+	return aiResponse
 }
 
 // buildFeedbackPrompt returns the feedback prompt string based on the
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/gcclinux/scmd/internal/prompts"
+)
+
+// handlePromptCommand implements /prompt show [name] and /prompt edit <name>.
+func handlePromptCommand(args string) {
+	fields := strings.Fields(args)
+	sub := "show"
+	if len(fields) > 0 {
+		sub = fields[0]
+	}
+
+	switch {
+	case sub == "show" && len(fields) <= 2:
+		names := prompts.Names
+		if len(fields) == 2 {
+			names = fields[1:]
+		}
+		for _, name := range names {
+			if err := printPrompt(os.Stdout, name); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+		}
+	case sub == "edit" && len(fields) == 2:
+		editPrompt(fields[1])
+	default:
+		fmt.Println("Usage: /prompt show [name] | /prompt edit <name>")
+		fmt.Printf("Templates: %s\n", strings.Join(prompts.Names, ", "))
+	}
+}
+
+// printPrompt shows the source of a prompt template, where it comes from
+// and whether it needs attention.
+func printPrompt(w io.Writer, name string) error {
+	t, err := prompts.Load(name)
+	if err != nil {
+		return err
+	}
+
+	origin := fmt.Sprintf("built-in default, v%d", t.DefaultVersion)
+	if t.Custom {
+		origin = fmt.Sprintf("custom %s, v%d", t.Path, t.Version)
+	}
+	fmt.Fprintf(w, "── %s (%s) ──\n", name, origin)
+	if t.Outdated() {
+		fmt.Fprintf(w, "⚠️  Based on an older default; the built-in template is now v%d. Delete %s to use it.\n", t.DefaultVersion, t.Path)
+	}
+	if t.Custom {
+		if err := prompts.Check(name); err != nil {
+			fmt.Fprintf(w, "⚠️  %v\n   The built-in default is used until this is fixed.\n", err)
+		}
+	}
+	fmt.Fprintln(w, strings.TrimRight(t.Source, "\n"))
+	fmt.Fprintln(w)
+	return nil
+}
+
+// editPrompt copies the default of a template to ~/.scmd/prompts if it has
+// not been customised yet, opens it in $VISUAL or $EDITOR and checks the
+// result.
+func editPrompt(name string) {
+	path, err := prompts.Install(name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	args := append(strings.Fields(editor), path)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("Error running editor: %v\n", err)
+		fmt.Printf("Edit %s by hand; changes apply to the next question.\n", path)
+		return
+	}
+
+	if err := prompts.Check(name); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+		fmt.Println("The built-in default is used until the template is fixed.")
+		return
+	}
+	fmt.Printf("✓ Prompt template '%s' saved to %s\n", name, path)
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/prompts"
+)
+
+func TestPrintPrompt(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var out strings.Builder
+	if err := printPrompt(&out, prompts.SystemTemplate); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "── system (built-in default, v2) ──") || !strings.Contains(got, "You are a helpful assistant") {
+		t.Errorf("default output:\n%s", got)
+	}
+
+	path := filepath.Join(prompts.Dir(), "user.tmpl")
+	os.MkdirAll(prompts.Dir(), 0755)
+	if err := os.WriteFile(path, []byte("{{.Missing}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	printPrompt(&out, prompts.UserTemplate)
+	got := out.String()
+	if !strings.Contains(got, "custom "+path+", v0") || !strings.Contains(got, "older default") || !strings.Contains(got, "Missing") {
+		t.Errorf("custom output:\n%s", got)
+	}
+
+	if err := printPrompt(&out, "nope"); err == nil {
+		t.Error("unknown template should fail")
+	}
+}
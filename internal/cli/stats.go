@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/config"
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+// RunStats implements `scmd stats [--top N] [--since 30d]` and returns the
+// process exit code.
+func RunStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	top := fs.Int("top", 10, "number of most used commands to list")
+	since := fs.String("since", "", "only count usage within this period, e.g. 7d or 12h")
+	fs.Usage = func() { printStatsUsage(os.Stderr); fs.PrintDefaults() }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *top <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --top must be a positive number")
+		return 2
+	}
+
+	var from time.Time
+	if *since != "" {
+		period := config.ParseDuration(*since, -1)
+		if period <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid --since %q\n", *since)
+			return 2
+		}
+		from = time.Now().Add(-period)
+	}
+
+	if err := database.InitDB(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer database.CloseDB()
+
+	stats, err := database.TopCommands(*top, from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	printTopCommands(os.Stdout, stats, *since)
+	return 0
+}
+
+func printTopCommands(w io.Writer, stats []database.UsageStat, since string) {
+	period := "all time"
+	if since != "" {
+		period = "last " + since
+	}
+	if len(stats) == 0 {
+		fmt.Fprintf(w, "No command usage recorded (%s).\n", period)
+		return
+	}
+	fmt.Fprintf(w, "Most used commands (%s):\n", period)
+	fmt.Fprintf(w, "  %5s  %5s  %5s  %5s  %-16s  %s\n", "ID", "VIEWS", "COPY", "EXEC", "LAST USED", "COMMAND")
+	for _, s := range stats {
+		fmt.Fprintf(w, "  %5d  %5d  %5d  %5d  %-16s  %s\n", s.CommandID, s.Views, s.Copies, s.Execs,
+			s.LastUsed.Format("2006-01-02 15:04"), truncate(s.Key, 50))
+	}
+}
+
+func printStatsUsage(w io.Writer) {
+	name := GetName()
+	fmt.Fprintf(w, "Usage: %s stats [--top N] [--since 30d]\n", name)
+	fmt.Fprintf(w, "       Lists the most viewed, copied and executed commands.\n")
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/gcclinux/scmd/internal/ai"
+	"github.com/gcclinux/scmd/internal/markdown"
+	"github.com/gcclinux/scmd/internal/util"
+)
+
+// askFunc runs an AI request, passing each chunk of the answer to onToken.
+type askFunc func(ctx context.Context, onToken func(string)) (string, error)
+
+// streamAnswer runs ask and prints the answer to w under title as it is
+// generated, rendering markdown line by line. The spinner shows until the
+// first chunk arrives. Ctrl-C cancels the request and returns to the
+// prompt instead of exiting; the error is then context.Canceled.
+func streamAnswer(w io.Writer, title string, ask askFunc) (string, error) {
+	// Answers are for this machine, so the prompt describes it.
+	ctx, cancel := context.WithCancel(ai.WithLocalSystem(context.Background()))
+	defer cancel()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var out *markdown.Renderer
+	write := func(token string) {
+		if out == nil {
+			util.StopSpinner()
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, title)
+			fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+			out = markdown.NewRenderer(w)
+		}
+		out.WriteString(token)
+	}
+	util.StartSpinner()
+	answer, err := ask(ctx, write)
+	util.StopSpinner()
+
+	// An answer that was not streamed is printed whole.
+	if out == nil && err == nil && answer != "" {
+		write(answer)
+	}
+	if out != nil {
+		out.Close()
+		fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+		fmt.Fprintln(w)
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(w, "⏹  Cancelled.")
+		fmt.Fprintln(w)
+		return "", context.Canceled
+	}
+	if err != nil {
+		return "", err
+	}
+	return answer, nil
+}
+
+// isCancelled reports whether err is a request cancelled with Ctrl-C.
+func isCancelled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamAnswer(t *testing.T) {
+	var out strings.Builder
+	answer, err := streamAnswer(&out, "Title:", func(ctx context.Context, onToken func(string)) (string, error) {
+		for _, tok := range []string{"Run ", "it:\n```bash\n", "ls\n```\n"} {
+			onToken(tok)
+		}
+		return "Run it:\n```bash\nls\n```\n", nil
+	})
+	if err != nil || !strings.HasPrefix(answer, "Run it:") {
+		t.Fatalf("streamAnswer = %q, %v", answer, err)
+	}
+	if got := out.String(); !strings.Contains(got, "Title:\n") || !strings.Contains(got, "Run it:\n") || !strings.Contains(got, "┌─ bash") {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+
+	out.Reset()
+	if _, err := streamAnswer(&out, "Title:", func(ctx context.Context, onToken func(string)) (string, error) {
+		return "", errors.New("provider down")
+	}); err == nil || out.Len() != 0 {
+		t.Errorf("failed request: err %v, output %q", err, out.String())
+	}
+}
+
+func TestStreamAnswerInterrupt(t *testing.T) {
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Skip(err)
+	}
+
+	var out strings.Builder
+	answer, err := streamAnswer(&out, "Title:", func(ctx context.Context, onToken func(string)) (string, error) {
+		onToken("partial ")
+		if err := self.Signal(os.Interrupt); err != nil {
+			t.Skipf("cannot send interrupt: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return "partial ", ctx.Err()
+		case <-time.After(5 * time.Second):
+			return "", errors.New("request was not cancelled")
+		}
+	})
+	if !isCancelled(err) || answer != "" {
+		t.Fatalf("streamAnswer = %q, %v; want cancellation", answer, err)
+	}
+	if !strings.Contains(out.String(), "partial \n") || !strings.Contains(out.String(), "Cancelled") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
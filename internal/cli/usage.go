@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/ai"
+	"github.com/gcclinux/scmd/internal/config"
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+// RunUsage implements `scmd usage [--since 7d]` and returns the process
+// exit code.
+func RunUsage(args []string) int {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	since := fs.String("since", "", "only count AI requests within this period, e.g. 7d or 12h")
+	fs.Usage = func() { printUsageUsage(os.Stderr); fs.PrintDefaults() }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var from time.Time
+	if *since != "" {
+		period := config.ParseDuration(*since, -1)
+		if period <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid --since %q\n", *since)
+			return 2
+		}
+		from = time.Now().Add(-period)
+	}
+
+	if err := database.InitDB(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer database.CloseDB()
+
+	report, err := database.AIUsageReport(from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	printUsageReport(os.Stdout, report, *since)
+	printBudgetStatus(os.Stdout)
+	return 0
+}
+
+func printUsageReport(w io.Writer, report []database.AIUsageSummary, since string) {
+	period := "all time"
+	if since != "" {
+		period = "last " + since
+	}
+	if len(report) == 0 {
+		fmt.Fprintf(w, "No AI usage recorded (%s).\n", period)
+		return
+	}
+
+	var total database.AIUsageSummary
+	fmt.Fprintf(w, "AI usage (%s):\n", period)
+	fmt.Fprintf(w, "  %-8s  %-28s  %8s  %10s  %10s  %9s  %10s\n", "PROVIDER", "MODEL", "REQUESTS", "PROMPT", "COMPLETION", "AVG TIME", "COST")
+	for _, s := range report {
+		fmt.Fprintf(w, "  %-8s  %-28s  %8d  %10d  %10d  %9s  %10s\n", s.Provider, truncate(s.Model, 28), s.Requests,
+			s.PromptTokens, s.CompletionTokens, s.AvgLatency.Round(time.Millisecond), formatCost(s.Cost))
+		total.Requests += s.Requests
+		total.PromptTokens += s.PromptTokens
+		total.CompletionTokens += s.CompletionTokens
+		total.Cost += s.Cost
+	}
+	fmt.Fprintf(w, "  %-8s  %-28s  %8d  %10d  %10d  %9s  %10s\n", "TOTAL", "", total.Requests,
+		total.PromptTokens, total.CompletionTokens, "", formatCost(total.Cost))
+}
+
+// printBudgetStatus shows today's estimated AI spending against the daily
+// budget, if one is set.
+func printBudgetStatus(w io.Writer) {
+	budget := ai.DailyBudget()
+	if budget <= 0 {
+		return
+	}
+	spent, err := ai.SpentToday()
+	if errors.Is(err, database.ErrNoAIUsage) {
+		fmt.Fprintf(w, "\n💰 Daily budget %s: not enforced, the MCP backend does not record AI usage\n", formatCost(budget))
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(w, "\n💰 Daily budget %s: error reading today's usage: %v\n", formatCost(budget), err)
+		return
+	}
+	printBudget(w, budget, spent, ai.BudgetAction())
+}
+
+func printBudget(w io.Writer, budget, spent float64, action string) {
+	fmt.Fprintf(w, "\n💰 Spent today: %s of the %s daily budget\n", formatCost(spent), formatCost(budget))
+	if spent < budget {
+		return
+	}
+	if action == ai.BudgetRefuse {
+		fmt.Fprintln(w, "  Budget reached: AI requests are refused until midnight.")
+	} else {
+		fmt.Fprintln(w, "  Budget reached: only providers without a price in ai_prices are used until midnight.")
+	}
+}
+
+// formatCost renders an estimated cost in US dollars, keeping fractions of
+// a cent visible.
+func formatCost(cost float64) string {
+	if cost > 0 && cost < 0.01 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+func printUsageUsage(w io.Writer) {
+	name := GetName()
+	fmt.Fprintf(w, "Usage: %s usage [--since 7d]\n", name)
+	fmt.Fprintf(w, "       Reports AI requests, tokens, latency and estimated cost per provider and model.\n")
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/ai"
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func TestPrintUsageReport(t *testing.T) {
+	var out strings.Builder
+	printUsageReport(&out, nil, "7d")
+	if got := out.String(); got != "No AI usage recorded (last 7d).\n" {
+		t.Errorf("empty report = %q", got)
+	}
+
+	out.Reset()
+	printUsageReport(&out, []database.AIUsageSummary{
+		{Provider: "gemini", Model: "gemini-2.5-flash-lite", Requests: 3, PromptTokens: 1200, CompletionTokens: 300, AvgLatency: 850 * time.Millisecond, Cost: 0.25},
+		{Provider: "ollama", Model: "llama3", Requests: 2, PromptTokens: 100, CompletionTokens: 50, AvgLatency: 2 * time.Second},
+	}, "")
+	got := out.String()
+	for _, want := range []string{"AI usage (all time)", "gemini-2.5-flash-lite", "850ms", "$0.25", "TOTAL", "1300", "$0.00"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestPrintBudget(t *testing.T) {
+	var out strings.Builder
+	printBudget(&out, 1, 0.004, ai.BudgetFallback)
+	if got := out.String(); !strings.Contains(got, "$0.0040 of the $1.00 daily budget") || strings.Contains(got, "reached") {
+		t.Errorf("under budget = %q", got)
+	}
+
+	out.Reset()
+	printBudget(&out, 1, 1.2, ai.BudgetRefuse)
+	if got := out.String(); !strings.Contains(got, "refused until midnight") {
+		t.Errorf("refuse = %q", got)
+	}
+}
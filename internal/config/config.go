@@ -5,21 +5,58 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ConfigData holds all configuration fields from config.json.
 type ConfigData struct {
-	Agent                string `json:"agent"`
-	DBType               string `json:"db_type"`
-	GeminiAPI            string `json:"gemini_api"`
-	GeminiModel          string `json:"gemini_model"`
-	GeminiEmbeddingModel string `json:"gemini_embedding_model"`
-	Ollama               string `json:"ollama"`
-	Model                string `json:"model"`
-	EmbeddingModel       string `json:"embedding_model"`
-	EmbeddingDim         string `json:"embedding_dim"`
-	MCPServer            string `json:"mcp_server"`
+	Agent                 string `json:"agent"`
+	AIPriority            string `json:"ai_priority,omitempty"`
+	OpenAIBaseURL         string `json:"openai_base_url,omitempty"`
+	OpenAIAPIKey          string `json:"openai_api_key,omitempty"`
+	OpenAIModel           string `json:"openai_model,omitempty"`
+	OpenAIEmbeddingModel  string `json:"openai_embedding_model,omitempty"`
+	ConversationMaxTokens string `json:"conversation_max_tokens,omitempty"`
+	SystemContext         string `json:"system_context,omitempty"`
+	AICache               string `json:"ai_cache,omitempty"`
+	AICacheTTL            string `json:"ai_cache_ttl,omitempty"`
+	AICacheMaxSize        string `json:"ai_cache_max_size,omitempty"`
+	AIDailyBudget         string `json:"ai_daily_budget,omitempty"`
+	AIBudgetAction        string `json:"ai_budget_action,omitempty"`
+	DBType                string `json:"db_type"`
+	GeminiAPI             string `json:"gemini_api"`
+	GeminiModel           string `json:"gemini_model"`
+	GeminiEmbeddingModel  string `json:"gemini_embedding_model"`
+	Ollama                string `json:"ollama"`
+	Model                 string `json:"model"`
+	EmbeddingModel        string `json:"embedding_model"`
+	EmbeddingDim          string `json:"embedding_dim"`
+	MCPServer             string `json:"mcp_server"`
+	SessionStore          string `json:"session_store,omitempty"`
+	SessionTTL            string `json:"session_ttl,omitempty"`
+	SessionSliding        string `json:"session_sliding,omitempty"`
+	WebBind               string `json:"web_bind,omitempty"`
+	WebReadTimeout        string `json:"web_read_timeout,omitempty"`
+	WebWriteTimeout       string `json:"web_write_timeout,omitempty"`
+	WebIdleTimeout        string `json:"web_idle_timeout,omitempty"`
+	WebShutdownTimeout    string `json:"web_shutdown_timeout,omitempty"`
+	TLSMode               string `json:"tls_mode,omitempty"`
+	MetricsEnabled        string `json:"metrics_enabled,omitempty"`
+	LogLevel              string `json:"log_level,omitempty"`
+	LogFormat             string `json:"log_format,omitempty"`
+	LogFile               string `json:"log_file,omitempty"`
+	WebLog                string `json:"web_log,omitempty"`
+	WebLogMaxSize         string `json:"web_log_max_size,omitempty"`
+	WebLogMaxAge          string `json:"web_log_max_age,omitempty"`
+	WebLogMaxBackups      string `json:"web_log_max_backups,omitempty"`
+	TrashRetention        string `json:"trash_retention,omitempty"`
+	UsageBoost            string `json:"usage_boost,omitempty"`
+	DuplicateSimilarity   string `json:"duplicate_similarity,omitempty"`
+
+	// AIPrices is a JSON object, exported to AI_PRICES as is.
+	AIPrices json.RawMessage `json:"ai_prices,omitempty"`
 }
 
 // configPath returns the path to $HOME/.scmd/config.json.
@@ -56,6 +93,19 @@ func LoadConfig() {
 	}
 
 	setIfNotEmpty("AGENT", cfg.Agent)
+	setIfNotEmpty("AI_PRIORITY", cfg.AIPriority)
+	setIfNotEmpty("OPENAI_BASE_URL", cfg.OpenAIBaseURL)
+	setIfNotEmpty("OPENAI_API_KEY", cfg.OpenAIAPIKey)
+	setIfNotEmpty("OPENAI_MODEL", cfg.OpenAIModel)
+	setIfNotEmpty("OPENAI_EMBEDDING_MODEL", cfg.OpenAIEmbeddingModel)
+	setIfNotEmpty("CONVERSATION_MAX_TOKENS", cfg.ConversationMaxTokens)
+	setIfNotEmpty("SYSTEM_CONTEXT", cfg.SystemContext)
+	setIfNotEmpty("AI_CACHE", cfg.AICache)
+	setIfNotEmpty("AI_CACHE_TTL", cfg.AICacheTTL)
+	setIfNotEmpty("AI_CACHE_MAX_SIZE", cfg.AICacheMaxSize)
+	setIfNotEmpty("AI_PRICES", string(cfg.AIPrices))
+	setIfNotEmpty("AI_DAILY_BUDGET", cfg.AIDailyBudget)
+	setIfNotEmpty("AI_BUDGET_ACTION", cfg.AIBudgetAction)
 	setIfNotEmpty("DB_TYPE", cfg.DBType)
 	setIfNotEmpty("GEMINIAPI", cfg.GeminiAPI)
 	setIfNotEmpty("GEMINIMODEL", cfg.GeminiModel)
@@ -65,6 +115,26 @@ func LoadConfig() {
 	setIfNotEmpty("EMBEDDING_MODEL", cfg.EmbeddingModel)
 	setIfNotEmpty("EMBEDDING_DIM", cfg.EmbeddingDim)
 	setIfNotEmpty("MCP_SERVER", cfg.MCPServer)
+	setIfNotEmpty("SESSION_STORE", cfg.SessionStore)
+	setIfNotEmpty("SESSION_TTL", cfg.SessionTTL)
+	setIfNotEmpty("SESSION_SLIDING", cfg.SessionSliding)
+	setIfNotEmpty("WEB_BIND", cfg.WebBind)
+	setIfNotEmpty("WEB_READ_TIMEOUT", cfg.WebReadTimeout)
+	setIfNotEmpty("WEB_WRITE_TIMEOUT", cfg.WebWriteTimeout)
+	setIfNotEmpty("WEB_IDLE_TIMEOUT", cfg.WebIdleTimeout)
+	setIfNotEmpty("WEB_SHUTDOWN_TIMEOUT", cfg.WebShutdownTimeout)
+	setIfNotEmpty("TLS_MODE", cfg.TLSMode)
+	setIfNotEmpty("METRICS_ENABLED", cfg.MetricsEnabled)
+	setIfNotEmpty("LOG_LEVEL", cfg.LogLevel)
+	setIfNotEmpty("LOG_FORMAT", cfg.LogFormat)
+	setIfNotEmpty("LOG_FILE", cfg.LogFile)
+	setIfNotEmpty("WEB_LOG", cfg.WebLog)
+	setIfNotEmpty("WEB_LOG_MAX_SIZE", cfg.WebLogMaxSize)
+	setIfNotEmpty("WEB_LOG_MAX_AGE", cfg.WebLogMaxAge)
+	setIfNotEmpty("WEB_LOG_MAX_BACKUPS", cfg.WebLogMaxBackups)
+	setIfNotEmpty("TRASH_RETENTION", cfg.TrashRetention)
+	setIfNotEmpty("USAGE_BOOST", cfg.UsageBoost)
+	setIfNotEmpty("DUPLICATE_SIMILARITY", cfg.DuplicateSimilarity)
 
 	// When db_type is "mcp", resolve the MCP server config path to an absolute path.
 	// This overrides the raw value set above with the fully resolved path.
@@ -96,6 +166,40 @@ func GetEnv(key, fallback string) string {
 	return fallback
 }
 
+// ParseDuration parses a duration setting such as "90m", "24h" or "30d".
+// In addition to the units understood by time.ParseDuration it accepts a
+// "d" suffix for whole days. Empty or invalid values return fallback.
+func ParseDuration(value string, fallback time.Duration) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback
+	}
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || days < 0 {
+			return fallback
+		}
+		return time.Duration(days) * 24 * time.Hour
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fallback
+	}
+	return d
+}
+
+// GetBool returns an environment variable interpreted as a boolean,
+// accepting the usual true/false, yes/no, on/off and 1/0 spellings.
+func GetBool(key string, fallback bool) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	return fallback
+}
+
 // TableName returns the configured table name.
 func TableName() string {
 	return "data"
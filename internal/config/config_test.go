@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 // helper to create a temp config dir with a config.json and override configPath.
@@ -176,3 +177,54 @@ func TestLoadConfig_NonMCPMode_EmptyMCPServer(t *testing.T) {
 		t.Errorf("MCP_SERVER = %q, want empty", mcpServer)
 	}
 }
+
+func TestParseDuration(t *testing.T) {
+	fallback := 24 * time.Hour
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", fallback},
+		{"90m", 90 * time.Minute},
+		{"12h", 12 * time.Hour},
+		{"7d", 7 * 24 * time.Hour},
+		{"0d", 0},
+		{"-1h", fallback},
+		{"xd", fallback},
+		{"soon", fallback},
+	}
+	for _, tt := range tests {
+		if got := ParseDuration(tt.in, fallback); got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGetBool(t *testing.T) {
+	t.Setenv("SCMD_TEST_BOOL", "yes")
+	if !GetBool("SCMD_TEST_BOOL", false) {
+		t.Error("GetBool(yes) = false, want true")
+	}
+	t.Setenv("SCMD_TEST_BOOL", "off")
+	if GetBool("SCMD_TEST_BOOL", true) {
+		t.Error("GetBool(off) = true, want false")
+	}
+	t.Setenv("SCMD_TEST_BOOL", "maybe")
+	if !GetBool("SCMD_TEST_BOOL", true) {
+		t.Error("GetBool(maybe) should return the fallback")
+	}
+}
+
+func TestConfigData_AIPricesObject(t *testing.T) {
+	var cfg ConfigData
+	data := `{"ai_daily_budget": "1.50", "ai_prices": {"gemini": {"input": 0.1, "output": 0.4}}}`
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if cfg.AIDailyBudget != "1.50" {
+		t.Errorf("AIDailyBudget = %q", cfg.AIDailyBudget)
+	}
+	if got := string(cfg.AIPrices); got != `{"gemini": {"input": 0.1, "output": 0.4}}` {
+		t.Errorf("AIPrices = %s, want the raw JSON object", got)
+	}
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAICache_GetPut(t *testing.T) {
+	setupTestSQLite(t)
+
+	if e, err := GetAICache("missing", time.Hour); err != nil || e != nil {
+		t.Fatalf("GetAICache(missing) = %+v, %v", e, err)
+	}
+	entry := AICacheEntry{Key: "k1", Kind: CacheChat, Provider: "ollama", Model: "llama3", Value: "docker ps", Tokens: 12}
+	if err := PutAICache(entry, time.Hour, 0); err != nil {
+		t.Fatalf("PutAICache: %v", err)
+	}
+	e, err := GetAICache("k1", time.Hour)
+	if err != nil || e == nil || e.Value != "docker ps" || e.Tokens != 12 || e.Provider != "ollama" {
+		t.Fatalf("GetAICache(k1) = %+v, %v", e, err)
+	}
+
+	stats, err := AICacheStatus()
+	if err != nil || stats.Entries != 1 || stats.Bytes != int64(len("docker ps")) || stats.Hits != 1 {
+		t.Errorf("AICacheStatus = %+v, %v", stats, err)
+	}
+
+	if n, err := ClearAICache(); err != nil || n != 1 {
+		t.Errorf("ClearAICache = %d, %v", n, err)
+	}
+	if e, _ := GetAICache("k1", time.Hour); e != nil {
+		t.Error("entry survived ClearAICache")
+	}
+}
+
+func TestAICache_Expiry(t *testing.T) {
+	setupTestSQLite(t)
+
+	old := AICacheEntry{Key: "old", Kind: CacheChat, Provider: "p", Model: "m", Value: "stale", CreatedAt: time.Now().Add(-2 * time.Hour)}
+	if err := PutAICache(old, 3*time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := GetAICache("old", time.Hour); err != nil || e != nil {
+		t.Errorf("expired entry returned: %+v, %v", e, err)
+	}
+	if stats, _ := AICacheStatus(); stats.Entries != 0 {
+		t.Errorf("expired entry was not deleted on lookup, %d entries left", stats.Entries)
+	}
+
+	if err := PutAICache(old, 3*time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+	fresh := AICacheEntry{Key: "fresh", Kind: CacheEmbed, Provider: "p", Model: "m", Value: "[1,2]"}
+	if err := PutAICache(fresh, time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := AICacheStatus(); stats.Entries != 1 {
+		t.Errorf("storing should drop expired entries, %d entries left", stats.Entries)
+	}
+}
+
+func TestAICache_EvictsLeastRecentlyUsed(t *testing.T) {
+	setupTestSQLite(t)
+
+	value := strings.Repeat("x", 100)
+	start := time.Now().Add(-time.Minute)
+	for i, key := range []string{"a", "b", "c"} {
+		e := AICacheEntry{Key: key, Kind: CacheChat, Provider: "p", Model: "m", Value: value, CreatedAt: start.Add(time.Duration(i) * time.Second)}
+		if err := PutAICache(e, time.Hour, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Using "a" makes "b" the least recently used entry.
+	if e, _ := GetAICache("a", time.Hour); e == nil {
+		t.Fatal("a missing")
+	}
+
+	if err := PutAICache(AICacheEntry{Key: "d", Kind: CacheChat, Provider: "p", Model: "m", Value: value}, time.Hour, 300); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if e, _ := GetAICache(key, time.Hour); (e != nil) != want {
+			t.Errorf("entry %s present = %v, want %v", key, e != nil, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAIUsage_ReportAndCost(t *testing.T) {
+	setupTestSQLite(t)
+
+	now := time.Now()
+	for _, u := range []AIUsage{
+		{Provider: "gemini", Model: "flash", PromptTokens: 100, CompletionTokens: 50, Latency: 200 * time.Millisecond, Cost: 0.02, CreatedAt: now.Add(-time.Hour)},
+		{Provider: "gemini", Model: "flash", PromptTokens: 300, CompletionTokens: 10, Latency: 400 * time.Millisecond, Cost: 0.03},
+		{Provider: "ollama", Model: "llama3", PromptTokens: 80, CompletionTokens: 20, Latency: time.Second},
+		{Provider: "gemini", Model: "flash", PromptTokens: 1, CompletionTokens: 1, Cost: 5, CreatedAt: now.Add(-10 * 24 * time.Hour)},
+	} {
+		if err := RecordAIUsage(u); err != nil {
+			t.Fatalf("RecordAIUsage: %v", err)
+		}
+	}
+
+	report, err := AIUsageReport(now.Add(-7 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("AIUsageReport: %v", err)
+	}
+	if len(report) != 2 {
+		t.Fatalf("got %d rows, want 2: %+v", len(report), report)
+	}
+	g := report[0]
+	if g.Provider != "gemini" || g.Requests != 2 || g.PromptTokens != 400 || g.CompletionTokens != 60 ||
+		g.AvgLatency != 300*time.Millisecond || math.Abs(g.Cost-0.05) > 1e-9 {
+		t.Errorf("gemini row = %+v", g)
+	}
+	if o := report[1]; o.Provider != "ollama" || o.Requests != 1 || o.Cost != 0 {
+		t.Errorf("ollama row = %+v", o)
+	}
+
+	if all, _ := AIUsageReport(time.Time{}); len(all) != 2 || all[0].Requests != 3 {
+		t.Errorf("all-time report = %+v", all)
+	}
+	if cost, err := AICostSince(now.Add(-2 * time.Hour)); err != nil || math.Abs(cost-0.05) > 1e-9 {
+		t.Errorf("AICostSince = %v, %v", cost, err)
+	}
+}
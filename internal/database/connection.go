@@ -3,17 +3,19 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 
 	_ "modernc.org/sqlite"
 
 	"github.com/gcclinux/scmd/internal/config"
+	"github.com/gcclinux/scmd/internal/logging"
 )
 
 var db *sql.DB
 
+var logger = logging.For(logging.DB)
+
 // mcpClient holds the active MCP client as an untyped reference to avoid
 // an import cycle between the database and mcpclient packages. Code in
 // queries_mcp.go accesses the concrete *mcpclient.Client via MCPClient().
@@ -70,7 +72,7 @@ func initMCPDB() error {
 		return fmt.Errorf("error initializing MCP client: %v", err)
 	}
 	mcpClient = client
-	log.Println("Successfully connected to MCP server")
+	logger.Debug("connected to MCP server")
 	return nil
 }
 
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// seedCommands inserts n commands named cmd-1..cmd-n into the test database.
+func seedCommands(t *testing.T, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		if _, err := AddCommand(fmt.Sprintf("cmd-%d", i), fmt.Sprintf("description %d", i), Origin{}, nil); err != nil {
+			t.Fatalf("AddCommand: %v", err)
+		}
+	}
+}
+
+func pageIDs(p *CommandPage) []int {
+	ids := make([]int, len(p.Records))
+	for i, r := range p.Records {
+		ids[i] = r.Id
+	}
+	return ids
+}
+
+func TestListCommands_SQLiteCursorWalk(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, 7)
+
+	var seen []int
+	opts := ListOptions{Limit: 3, Desc: true}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("cursor walk did not terminate")
+		}
+		p, err := ListCommands(opts)
+		if err != nil {
+			t.Fatalf("ListCommands: %v", err)
+		}
+		if p.Total != 7 {
+			t.Errorf("Total = %d, want 7", p.Total)
+		}
+		seen = append(seen, pageIDs(p)...)
+		if p.NextCursor == "" {
+			break
+		}
+		opts.Cursor = p.NextCursor
+	}
+	if fmt.Sprint(seen) != "[7 6 5 4 3 2 1]" {
+		t.Errorf("walked %v", seen)
+	}
+
+	// Equal creation times fall back to ID order across cursor pages.
+	p, err := ListCommands(ListOptions{Sort: SortCreated, Limit: 4})
+	if err != nil {
+		t.Fatalf("ListCommands(created): %v", err)
+	}
+	if p.Records[0].Created == "" {
+		t.Error("created_at not returned")
+	}
+	p, err = ListCommands(ListOptions{Sort: SortCreated, Limit: 4, Cursor: p.NextCursor})
+	if err != nil {
+		t.Fatalf("ListCommands(created, cursor): %v", err)
+	}
+	if fmt.Sprint(pageIDs(p)) != "[5 6 7]" {
+		t.Errorf("second created page = %v", pageIDs(p))
+	}
+}
+
+func TestListCommands_SQLiteFilterAndOffset(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, 12)
+	if err := SetTags(2, []string{"Docker, net", "docker"}, Origin{}); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+	if err := SetTags(11, []string{"docker"}, Origin{}); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+
+	p, err := ListCommands(ListOptions{Query: "cmd-1"})
+	if err != nil {
+		t.Fatalf("ListCommands: %v", err)
+	}
+	if p.Total != 4 { // cmd-1, cmd-10, cmd-11, cmd-12
+		t.Errorf("text filter Total = %d, want 4", p.Total)
+	}
+
+	p, err = ListCommands(ListOptions{Tag: "docker"})
+	if err != nil {
+		t.Fatalf("ListCommands: %v", err)
+	}
+	if fmt.Sprint(pageIDs(p)) != "[2 11]" {
+		t.Errorf("tag filter = %v, want [2 11]", pageIDs(p))
+	}
+	if got := p.Records[0].Tags; fmt.Sprint(got) != "[docker net]" {
+		t.Errorf("tags = %v", got)
+	}
+
+	p, err = ListCommands(ListOptions{Limit: 5, Offset: 10})
+	if err != nil {
+		t.Fatalf("ListCommands: %v", err)
+	}
+	if fmt.Sprint(pageIDs(p)) != "[11 12]" || p.NextCursor != "" {
+		t.Errorf("offset page = %v (next %q)", pageIDs(p), p.NextCursor)
+	}
+
+	// A literal % must not act as a wildcard.
+	if p, _ = ListCommands(ListOptions{Query: "%"}); p.Total != 0 {
+		t.Errorf("%% matched %d commands", p.Total)
+	}
+}
+
+func TestListCommands_SQLiteSortByUsage(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, 3)
+	for _, id := range []int{2, 2, 3} {
+		if _, err := db.Exec("INSERT INTO usage (command_id, event, used_at) VALUES (?, 'view', 0)", id); err != nil {
+			t.Fatalf("insert usage: %v", err)
+		}
+	}
+
+	p, err := ListCommands(ListOptions{Sort: SortUsage, Desc: true, Limit: 2})
+	if err != nil {
+		t.Fatalf("ListCommands: %v", err)
+	}
+	if fmt.Sprint(pageIDs(p)) != "[2 3]" || p.Records[0].Usage != 2 {
+		t.Fatalf("first page = %v", pageIDs(p))
+	}
+	p, err = ListCommands(ListOptions{Sort: SortUsage, Desc: true, Limit: 2, Cursor: p.NextCursor})
+	if err != nil {
+		t.Fatalf("ListCommands: %v", err)
+	}
+	if fmt.Sprint(pageIDs(p)) != "[1]" {
+		t.Errorf("second page = %v", pageIDs(p))
+	}
+}
+
+func TestListCommands_InvalidOptions(t *testing.T) {
+	setupTestSQLite(t)
+	if _, err := ListCommands(ListOptions{Sort: "random"}); err == nil {
+		t.Error("unknown sort should fail")
+	}
+	if _, err := ListCommands(ListOptions{Cursor: "not-a-cursor!"}); err == nil {
+		t.Error("malformed cursor should fail")
+	}
+	cur := encodeCursor(listCursor{Sort: SortID, Value: "1", ID: 1})
+	if _, err := ListCommands(ListOptions{Sort: SortCreated, Cursor: cur}); err == nil {
+		t.Error("cursor from another sort order should fail")
+	}
+}
+
+func TestListCommands_MCPUsesServerPaging(t *testing.T) {
+	defer saveBridgeFns()()
+	setDBType(t, "mcp")
+	installMockBridges(t)
+
+	var gotLimit, gotOffset int
+	MCPListPageFn = func(namespace string, limit, offset int) ([]byte, int, error) {
+		gotLimit, gotOffset = limit, offset
+		data, _ := json.Marshal([]mcpRecord{{ID: "a", Key: "ls"}, {ID: "b", Key: "pwd"}})
+		return data, 10, nil
+	}
+
+	p, err := ListCommands(ListOptions{Limit: 2, Offset: 4})
+	if err != nil {
+		t.Fatalf("ListCommands: %v", err)
+	}
+	if gotLimit != 2 || gotOffset != 4 {
+		t.Errorf("list_data limit/offset = %d/%d, want 2/4", gotLimit, gotOffset)
+	}
+	if p.Total != 10 || len(p.Records) != 2 || p.NextCursor == "" {
+		t.Fatalf("page = %+v", p)
+	}
+
+	if _, err := ListCommands(ListOptions{Limit: 2, Cursor: p.NextCursor}); err != nil {
+		t.Fatalf("ListCommands(cursor): %v", err)
+	}
+	if gotOffset != 6 {
+		t.Errorf("cursor offset = %d, want 6", gotOffset)
+	}
+}
+
+func TestListCommands_MCPFiltersClientSide(t *testing.T) {
+	defer saveBridgeFns()()
+	setDBType(t, "mcp")
+	installMockBridges(t)
+
+	MCPListDataFn = func(namespace string, limit, offset int) ([]byte, error) {
+		return json.Marshal([]mcpRecord{
+			{ID: "a", Key: "docker ps", Metadata: map[string]string{"tags": "docker"}},
+			{ID: "b", Key: "ls -la"},
+			{ID: "c", Key: "docker images", Metadata: map[string]string{"tags": "docker,images"}},
+		})
+	}
+
+	p, err := ListCommands(ListOptions{Query: "docker", Tag: "images"})
+	if err != nil {
+		t.Fatalf("ListCommands: %v", err)
+	}
+	if p.Total != 1 || p.Records[0].Key != "docker images" {
+		t.Errorf("page = %+v", p)
+	}
+}
+
+func TestUpdateCommand_SQLite(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, 1)
+	if err := SetTags(1, []string{"old"}, Origin{}); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+	db.Exec("UPDATE data SET embedding = '[1]' WHERE id = 1")
+
+	calls := 0
+	embed := func(string) ([]float64, error) { calls++; return []float64{0.5, 0.5}, nil }
+
+	// Unchanged text keeps the embedding; nil tags keep the tags.
+	if err := UpdateCommand(1, "cmd-1", "description 1", nil, Origin{}, embed); err != nil {
+		t.Fatalf("UpdateCommand: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("embedding regenerated for unchanged text")
+	}
+
+	if err := UpdateCommand(1, "cmd-one", "new description", []string{"New", "shell"}, Origin{}, embed); err != nil {
+		t.Fatalf("UpdateCommand: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("embedding calls = %d, want 1", calls)
+	}
+	r, err := GetCommandByID(1)
+	if err != nil {
+		t.Fatalf("GetCommandByID: %v", err)
+	}
+	if r.Key != "cmd-one" || r.Data != "new description" || fmt.Sprint(r.Tags) != "[new shell]" {
+		t.Errorf("record = %+v", r)
+	}
+	var emb string
+	db.QueryRow("SELECT embedding FROM data WHERE id = 1").Scan(&emb)
+	if emb != "[0.5,0.5]" {
+		t.Errorf("embedding = %q", emb)
+	}
+
+	// Without a provider the stale embedding is cleared.
+	if err := UpdateCommand(1, "cmd-two", "new description", nil, Origin{}, nil); err != nil {
+		t.Fatalf("UpdateCommand: %v", err)
+	}
+	var cleared sql.NullString
+	db.QueryRow("SELECT embedding FROM data WHERE id = 1").Scan(&cleared)
+	if cleared.Valid {
+		t.Errorf("stale embedding kept: %q", cleared.String)
+	}
+
+	if err := UpdateCommand(99, "x", "y", nil, Origin{}, nil); err == nil {
+		t.Error("updating a missing command should fail")
+	}
+}
+
+func TestBulkCommands_SQLite(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, 5)
+	SetTags(1, []string{"old", "keep"}, Origin{})
+	SetTags(2, []string{"old"}, Origin{})
+
+	bob := Origin{Author: "bob", Source: SourceWeb}
+	changed, err := RetagCommands([]int{1, 2, 3, 99}, []string{"New"}, []string{"old"}, bob)
+	if err != nil {
+		t.Fatalf("RetagCommands: %v", err)
+	}
+	if changed != 3 {
+		t.Errorf("changed = %d, want 3", changed)
+	}
+	for id, want := range map[int]string{1: "[keep new]", 2: "[new]", 3: "[new]"} {
+		r, _ := GetCommandByID(id)
+		if fmt.Sprint(r.Tags) != want {
+			t.Errorf("tags of %d = %v, want %s", id, r.Tags, want)
+		}
+	}
+
+	// Tag edits are revisions: baseline, SetTags, retag.
+	revs, err := ListRevisions(1)
+	if err != nil {
+		t.Fatalf("ListRevisions: %v", err)
+	}
+	if len(revs) != 3 || fmt.Sprint(revs[1].Tags) != "[old keep]" || revs[2].Author != "bob" || fmt.Sprint(revs[2].Tags) != "[keep new]" {
+		t.Fatalf("revisions of 1 = %+v", revs)
+	}
+	if err := RevertCommand(1, 2, Origin{}, nil); err != nil {
+		t.Fatalf("RevertCommand: %v", err)
+	}
+	if r, _ := GetCommandByID(1); fmt.Sprint(r.Tags) != "[old keep]" {
+		t.Errorf("tags after revert = %v", r.Tags)
+	}
+	if err := SetTags(1, []string{"old", "keep"}, Origin{}); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+	if revs, _ := ListRevisions(1); len(revs) != 4 {
+		t.Errorf("setting unchanged tags added a revision: %d revisions", len(revs))
+	}
+	if err := SetTags(99, []string{"x"}, Origin{}); err == nil {
+		t.Error("tagging a missing command should fail")
+	}
+
+	if err := UpdateEmbeddings(map[int][]float64{4: {1, 0}, 5: {0, 1}}); err != nil {
+		t.Fatalf("UpdateEmbeddings: %v", err)
+	}
+	var embedded int
+	db.QueryRow("SELECT COUNT(*) FROM " + sqliteTableName() + " WHERE embedding IS NOT NULL").Scan(&embedded)
+	if embedded != 2 {
+		t.Errorf("embedded = %d, want 2", embedded)
+	}
+
+	deleted, err := DeleteCommands([]int{1, 3, 99})
+	if err != nil {
+		t.Fatalf("DeleteCommands: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted = %d, want 2", deleted)
+	}
+	p, _ := ListCommands(ListOptions{})
+	if fmt.Sprint(pageIDs(p)) != "[2 4 5]" {
+		t.Errorf("remaining = %v", pageIDs(p))
+	}
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPins(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, 3)
+	alice, bob := UserPinOwner("Alice@example.com"), UserPinOwner("bob@example.com")
+
+	if added, err := PinCommand(alice, 3); err != nil || !added {
+		t.Fatalf("PinCommand = %v, %v", added, err)
+	}
+	if added, _ := PinCommand(alice, 3); added {
+		t.Error("pinning twice should report false")
+	}
+	PinCommand(alice, 1)
+	PinCommand(bob, 2)
+	if _, err := PinCommand(alice, 99); !errors.Is(err, ErrCommandNotFound) {
+		t.Errorf("pin unknown command err = %v", err)
+	}
+
+	pins, err := ListPins(UserPinOwner("alice@example.com"))
+	if err != nil || len(pins) != 2 || pins[0].Id != 3 || pins[1].Id != 1 {
+		t.Fatalf("alice pins = %+v, %v", pins, err)
+	}
+	if ids, _ := PinnedIDs(bob); len(ids) != 1 || !ids[2] {
+		t.Errorf("bob pinned IDs = %v", ids)
+	}
+
+	// Trashed commands drop out of the list but keep their pin.
+	DeleteCommand(3)
+	if pins, _ := ListPins(alice); len(pins) != 1 {
+		t.Errorf("pins with trashed command = %d, want 1", len(pins))
+	}
+	RestoreCommand(3)
+	if pins, _ := ListPins(alice); len(pins) != 2 {
+		t.Errorf("pins after restore = %d, want 2", len(pins))
+	}
+
+	if removed, err := UnpinCommand(alice, 1); err != nil || !removed {
+		t.Errorf("UnpinCommand = %v, %v", removed, err)
+	}
+	if removed, _ := UnpinCommand(alice, 1); removed {
+		t.Error("unpinning twice should report false")
+	}
+
+	DeleteCommand(2)
+	if _, err := PurgeTrash(0); err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+	var left int
+	db.QueryRow("SELECT COUNT(*) FROM pins WHERE command_id = 2").Scan(&left)
+	if left != 0 {
+		t.Errorf("purge left %d pins behind", left)
+	}
+}
+
+func TestPins_Limit(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, MaxPins+1)
+	owner := LocalPinOwner()
+	for id := 1; id <= MaxPins; id++ {
+		if _, err := PinCommand(owner, id); err != nil {
+			t.Fatalf("PinCommand(%d): %v", id, err)
+		}
+	}
+	if _, err := PinCommand(owner, MaxPins+1); !errors.Is(err, ErrTooManyPins) {
+		t.Errorf("pin over limit err = %v", err)
+	}
+}
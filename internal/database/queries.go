@@ -1,7 +1,17 @@
 package database
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/config"
 )
 
 // SearchCommands searches for commands matching the pattern.
@@ -12,13 +22,94 @@ func SearchCommands(pattern string, format string) ([]byte, error) {
 	return searchCommandsSQLite(pattern)
 }
 
-// AddCommand adds a new command to the database.
-// embeddingFn is an optional callback to generate embeddings.
-func AddCommand(command, description string, embeddingFn func(string) ([]float64, error)) (bool, error) {
+// AddCommand adds a new command to the database and records origin on its
+// first revision. embeddingFn is an optional callback to generate
+// embeddings.
+func AddCommand(command, description string, origin Origin, embeddingFn func(string) ([]float64, error)) (bool, error) {
 	if IsMCP() {
-		return addCommandMCP(command, description, embeddingFn)
+		return addCommandMCP(command, description, origin, embeddingFn)
+	}
+	return addCommandSQLite(command, description, origin, embeddingFn)
+}
+
+// UpdateCommand replaces the command text and description of an existing
+// command. When the text changes, embeddingFn (if non-nil) is used to
+// re-embed it; if no embedding can be generated the stale one is cleared so
+// --generate-embeddings picks the command up later. A nil tags slice leaves
+// the tags unchanged. Each effective change is recorded as a revision.
+func UpdateCommand(id int, command, description string, tags []string, origin Origin, embeddingFn func(string) ([]float64, error)) error {
+	if IsMCP() {
+		return fmt.Errorf("editing commands not supported with MCP backend")
+	}
+	if tags != nil {
+		tags = NormalizeTags(tags)
+		if tags == nil {
+			tags = []string{}
+		}
 	}
-	return addCommandSQLite(command, description, embeddingFn)
+	return updateCommandSQLite(id, command, description, tags, origin, embeddingFn)
+}
+
+// LocalOrigin returns an Origin for changes made by the user running scmd.
+func LocalOrigin(source string) Origin {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+	return Origin{Author: name, Source: source}
+}
+
+// ListRevisions returns the revisions of a command, oldest first. Commands
+// that have never been edited since revision history was introduced have
+// none.
+func ListRevisions(id int) ([]Revision, error) {
+	if IsMCP() {
+		return nil, fmt.Errorf("revision history not supported with MCP backend")
+	}
+	return listRevisionsSQLite(id)
+}
+
+// GetRevision returns one revision of a command.
+func GetRevision(id, rev int) (*Revision, error) {
+	revs, err := ListRevisions(id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range revs {
+		if revs[i].Rev == rev {
+			return &revs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("command %d has no revision %d", id, rev)
+}
+
+// RevertCommand restores a command's text, description and tags from an
+// earlier revision. The revert is itself recorded as a new revision. It
+// returns ErrCommandExists when another live command already has the
+// revision's text.
+func RevertCommand(id, rev int, origin Origin, embeddingFn func(string) ([]float64, error)) error {
+	target, err := GetRevision(id, rev)
+	if err != nil {
+		return err
+	}
+	current, err := GetCommandByID(id)
+	if err != nil {
+		return err
+	}
+	if target.Key != current.Key {
+		exists, err := CheckCommandExists(target.Key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrCommandExists
+		}
+	}
+	tags := target.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return UpdateCommand(id, target.Key, target.Data, tags, origin, embeddingFn)
 }
 
 // CheckCommandExists checks if a command already exists in the database.
@@ -29,7 +120,9 @@ func CheckCommandExists(command string) (bool, error) {
 	return checkCommandExistsSQLite(command)
 }
 
-// DeleteCommand deletes a command from the database by ID.
+// DeleteCommand deletes a command by ID. On SQLite the command is moved to
+// the trash and can be restored until it is purged; the MCP backend deletes
+// it permanently.
 func DeleteCommand(id int) (bool, error) {
 	if IsMCP() {
 		return deleteCommandMCP(id)
@@ -37,6 +130,263 @@ func DeleteCommand(id int) (bool, error) {
 	return deleteCommandSQLite(id)
 }
 
+// ErrCommandExists is returned when a change would duplicate the text of a
+// live command.
+var ErrCommandExists = errors.New("a command with the same text already exists")
+
+// DefaultTrashRetention is how long deleted commands stay in the trash when
+// trash_retention is not set.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// TrashRetention returns the trash_retention setting. Zero disables
+// automatic purging.
+func TrashRetention() time.Duration {
+	return config.ParseDuration(os.Getenv("TRASH_RETENTION"), DefaultTrashRetention)
+}
+
+// ListTrash returns the deleted commands that have not been purged yet.
+func ListTrash() ([]CommandRecord, error) {
+	if IsMCP() {
+		return nil, fmt.Errorf("trash not supported with MCP backend")
+	}
+	return listTrashSQLite()
+}
+
+// RestoreCommand moves a command out of the trash. It reports false when no
+// trashed command has that ID and returns ErrCommandExists when a live
+// command already has the same text.
+func RestoreCommand(id int) (bool, error) {
+	if IsMCP() {
+		return false, fmt.Errorf("trash not supported with MCP backend")
+	}
+	return restoreCommandSQLite(id)
+}
+
+// PurgeTrash permanently deletes commands that have been in the trash for
+// at least olderThan. Zero empties the trash.
+func PurgeTrash(olderThan time.Duration) (int, error) {
+	if IsMCP() {
+		return 0, fmt.Errorf("trash not supported with MCP backend")
+	}
+	return purgeTrashSQLite(time.Now().Add(-olderThan))
+}
+
+// PurgeExpiredTrash applies the trash_retention setting. It does nothing
+// when retention is disabled or the backend has no trash.
+func PurgeExpiredTrash() (int, error) {
+	retention := TrashRetention()
+	if IsMCP() || retention <= 0 {
+		return 0, nil
+	}
+	return PurgeTrash(retention)
+}
+
+// RecordUsage records that a command was viewed, copied or executed. The
+// MCP backend keeps no usage history, so it is a no-op there.
+func RecordUsage(id int, event string) error {
+	if !validUsageEvent(event) {
+		return fmt.Errorf("unknown usage event %q", event)
+	}
+	if IsMCP() {
+		return nil
+	}
+	return recordUsageSQLite(id, event, time.Now())
+}
+
+// RecordUsageByKey records a usage event for the stored command whose text
+// is exactly command, so running a saved command by hand still counts. It
+// does nothing when no stored command matches.
+func RecordUsageByKey(command, event string) error {
+	if !validUsageEvent(event) {
+		return fmt.Errorf("unknown usage event %q", event)
+	}
+	if IsMCP() {
+		return nil
+	}
+	return recordUsageByKeySQLite(command, event, time.Now())
+}
+
+func validUsageEvent(event string) bool {
+	return event == UsageView || event == UsageCopy || event == UsageExec
+}
+
+// UsageStats returns the usage counts of the live commands in ids that have
+// been used at least once, keyed by command ID. Without ids it returns every
+// used command.
+func UsageStats(ids ...int) (map[int]UsageStat, error) {
+	if IsMCP() {
+		return map[int]UsageStat{}, nil
+	}
+	return usageStatsSQLite(time.Time{}, ids)
+}
+
+// TopCommands returns the most used live commands, counting only events at
+// or after since (the zero time counts everything).
+func TopCommands(limit int, since time.Time) ([]UsageStat, error) {
+	if IsMCP() {
+		return nil, fmt.Errorf("usage statistics not supported with MCP backend")
+	}
+	stats, err := usageStatsSQLite(since, nil)
+	if err != nil {
+		return nil, err
+	}
+	top := make([]UsageStat, 0, len(stats))
+	for _, s := range stats {
+		top = append(top, s)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Total() != top[j].Total() {
+			return top[i].Total() > top[j].Total()
+		}
+		if !top[i].LastUsed.Equal(top[j].LastUsed) {
+			return top[i].LastUsed.After(top[j].LastUsed)
+		}
+		return top[i].CommandID < top[j].CommandID
+	})
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+	return top, nil
+}
+
+// ErrNoAICache is returned by the AI cache functions when the backend has
+// no cache; callers treat it as a miss.
+var ErrNoAICache = errors.New("AI cache not supported with MCP backend")
+
+// GetAICache returns the AI cache entry for key, or nil when there is none
+// younger than maxAge. A hit is counted on the entry.
+func GetAICache(key string, maxAge time.Duration) (*AICacheEntry, error) {
+	if IsMCP() || db == nil {
+		return nil, ErrNoAICache
+	}
+	now := time.Now()
+	return getAICacheSQLite(key, now.Add(-maxAge), now)
+}
+
+// PutAICache stores an AI cache entry, then removes entries older than
+// maxAge and the least recently used ones beyond maxBytes.
+func PutAICache(entry AICacheEntry, maxAge time.Duration, maxBytes int64) error {
+	if IsMCP() || db == nil {
+		return ErrNoAICache
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	return putAICacheSQLite(entry, time.Now().Add(-maxAge), maxBytes)
+}
+
+// AICacheStatus returns the size of the AI cache.
+func AICacheStatus() (AICacheStats, error) {
+	if IsMCP() || db == nil {
+		return AICacheStats{}, ErrNoAICache
+	}
+	return aiCacheStatsSQLite()
+}
+
+// ClearAICache deletes every AI cache entry and returns how many there were.
+func ClearAICache() (int, error) {
+	if IsMCP() || db == nil {
+		return 0, ErrNoAICache
+	}
+	return clearAICacheSQLite()
+}
+
+// ErrNoAIUsage is returned by the AI usage functions when the backend does
+// not record usage.
+var ErrNoAIUsage = errors.New("AI usage not recorded with MCP backend")
+
+// RecordAIUsage stores one AI request for cost accounting.
+func RecordAIUsage(u AIUsage) error {
+	if IsMCP() || db == nil {
+		return ErrNoAIUsage
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	return recordAIUsageSQLite(u)
+}
+
+// AIUsageReport totals the AI requests made since since (the zero time for
+// all of them) per provider and model, most expensive first.
+func AIUsageReport(since time.Time) ([]AIUsageSummary, error) {
+	if IsMCP() || db == nil {
+		return nil, ErrNoAIUsage
+	}
+	return aiUsageReportSQLite(since)
+}
+
+// AICostSince returns the estimated cost in US dollars of the AI requests
+// made since since.
+func AICostSince(since time.Time) (float64, error) {
+	if IsMCP() || db == nil {
+		return 0, ErrNoAIUsage
+	}
+	return aiCostSinceSQLite(since)
+}
+
+// MaxPins is how many commands one owner can pin.
+const MaxPins = 20
+
+// Errors returned by PinCommand.
+var (
+	ErrCommandNotFound = errors.New("command not found")
+	ErrTooManyPins     = fmt.Errorf("at most %d commands can be pinned", MaxPins)
+)
+
+// LocalPinOwner identifies the pins of the local user running the CLI.
+func LocalPinOwner() string {
+	return "local:" + LocalOrigin("").Author
+}
+
+// UserPinOwner identifies the pins of a web user logged in with email.
+func UserPinOwner(email string) string {
+	return "user:" + strings.ToLower(email)
+}
+
+// PinCommand pins a command for owner. It reports false when the command
+// was already pinned, and returns ErrCommandNotFound or ErrTooManyPins when
+// the pin cannot be added.
+func PinCommand(owner string, id int) (bool, error) {
+	if IsMCP() {
+		return false, fmt.Errorf("pins not supported with MCP backend")
+	}
+	return pinCommandSQLite(owner, id)
+}
+
+// UnpinCommand removes a pin, reporting false when the command was not
+// pinned by owner.
+func UnpinCommand(owner string, id int) (bool, error) {
+	if IsMCP() {
+		return false, fmt.Errorf("pins not supported with MCP backend")
+	}
+	return unpinCommandSQLite(owner, id)
+}
+
+// ListPins returns the commands pinned by owner, oldest pin first.
+func ListPins(owner string) ([]CommandRecord, error) {
+	if IsMCP() {
+		return nil, fmt.Errorf("pins not supported with MCP backend")
+	}
+	return listPinsSQLite(owner)
+}
+
+// PinnedIDs returns the set of command IDs pinned by owner. It is empty on
+// the MCP backend, so search can apply pins unconditionally.
+func PinnedIDs(owner string) (map[int]bool, error) {
+	ids := make(map[int]bool)
+	if IsMCP() {
+		return ids, nil
+	}
+	records, err := listPinsSQLite(owner)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		ids[r.Id] = true
+	}
+	return ids, nil
+}
+
 // GetCommandByID retrieves a single command record by its ID.
 func GetCommandByID(id int) (*CommandRecord, error) {
 	if IsMCP() {
@@ -77,6 +427,67 @@ func ListAllCommands() ([]CommandRecord, error) {
 	return listAllCommandsSQLite()
 }
 
+// ErrInvalidListOptions is wrapped by ListCommands errors caused by a bad
+// sort or cursor rather than a storage failure.
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// ListCommands returns one page of commands matching opts. Pages can be
+// walked by offset or, more efficiently for SQLite, by passing the previous
+// page's NextCursor.
+func ListCommands(opts ListOptions) (*CommandPage, error) {
+	opts, cur, err := normalizeListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if IsMCP() {
+		return listCommandsMCP(opts, cur)
+	}
+	return listCommandsSQLite(opts, cur)
+}
+
+// SetTags replaces the tags of a command and records a revision attributed
+// to origin. Tags are normalised with NormalizeTags.
+func SetTags(id int, tags []string, origin Origin) error {
+	if IsMCP() {
+		return fmt.Errorf("editing tags not supported with MCP backend")
+	}
+	return setTagsSQLite(id, NormalizeTags(tags), origin)
+}
+
+// DeleteCommands deletes several commands and returns how many existed. On
+// SQLite they are moved to the trash in one transaction, so either all or
+// none apply.
+func DeleteCommands(ids []int) (int, error) {
+	if IsMCP() {
+		return deleteCommandsMCP(ids)
+	}
+	return deleteCommandsSQLite(ids)
+}
+
+// RetagCommands adds and removes tags on several commands in one
+// transaction and returns how many commands changed. Each changed command
+// gets a revision attributed to origin.
+func RetagCommands(ids []int, add, remove []string, origin Origin) (int, error) {
+	if IsMCP() {
+		return 0, fmt.Errorf("editing tags not supported with MCP backend")
+	}
+	return retagCommandsSQLite(ids, NormalizeTags(add), NormalizeTags(remove), origin)
+}
+
+// UpdateEmbeddings stores several embeddings, keyed by command ID. On
+// SQLite the updates run in one transaction.
+func UpdateEmbeddings(embeddings map[int][]float64) error {
+	if IsMCP() {
+		for id, emb := range embeddings {
+			if err := updateEmbeddingMCP(id, emb); err != nil {
+				return fmt.Errorf("error updating embedding for ID %d: %v", id, err)
+			}
+		}
+		return nil
+	}
+	return updateEmbeddingsSQLite(embeddings)
+}
+
 // SearchByVector performs a vector similarity search.
 func SearchByVector(embedding []float64, limit int) ([]CommandRecord, error) {
 	if IsMCP() {
@@ -85,6 +496,24 @@ func SearchByVector(embedding []float64, limit int) ([]CommandRecord, error) {
 	return searchByVectorSQLite(embedding, limit)
 }
 
+// SimilarCommand is a command ranked by cosine similarity to an embedding.
+type SimilarCommand struct {
+	Record     CommandRecord
+	Similarity float64
+}
+
+// SimilarCommands returns up to limit commands whose embeddings have a cosine
+// similarity of at least minSimilarity to embedding, most similar first.
+func SimilarCommands(embedding []float64, limit int, minSimilarity float64) ([]SimilarCommand, error) {
+	if len(embedding) == 0 || limit <= 0 {
+		return nil, nil
+	}
+	if IsMCP() {
+		return similarCommandsMCP(embedding, limit, minSimilarity)
+	}
+	return similarCommandsSQLite(embedding, limit, minSimilarity)
+}
+
 // AuthenticateUser validates email and API key against the database.
 func AuthenticateUser(email, apiKey string) (bool, error) {
 	if IsMCP() {
@@ -93,6 +522,57 @@ func AuthenticateUser(email, apiKey string) (bool, error) {
 	return authenticateUserSQLite(email, apiKey)
 }
 
+// SaveSession inserts or replaces a persisted web session.
+func SaveSession(session SessionRecord) error {
+	if IsMCP() {
+		return fmt.Errorf("persistent sessions not supported with MCP backend")
+	}
+	return saveSessionSQLite(session)
+}
+
+// GetSession retrieves a persisted web session by ID. The boolean result is
+// false when no session with that ID exists.
+func GetSession(id string) (*SessionRecord, bool, error) {
+	if IsMCP() {
+		return nil, false, fmt.Errorf("persistent sessions not supported with MCP backend")
+	}
+	return getSessionSQLite(id)
+}
+
+// ExtendSession moves the expiry of a persisted web session.
+func ExtendSession(id string, expiresAt time.Time) error {
+	if IsMCP() {
+		return fmt.Errorf("persistent sessions not supported with MCP backend")
+	}
+	return extendSessionSQLite(id, expiresAt)
+}
+
+// DeleteSession removes a persisted web session.
+func DeleteSession(id string) error {
+	if IsMCP() {
+		return fmt.Errorf("persistent sessions not supported with MCP backend")
+	}
+	return deleteSessionSQLite(id)
+}
+
+// DeleteExpiredSessions removes every session that expired before now and
+// returns the number of sessions removed.
+func DeleteExpiredSessions(now time.Time) (int, error) {
+	if IsMCP() {
+		return 0, fmt.Errorf("persistent sessions not supported with MCP backend")
+	}
+	return deleteExpiredSessionsSQLite(now)
+}
+
+// SizeBytes returns the on-disk size of the database, including the SQLite
+// write-ahead log.
+func SizeBytes() (int64, error) {
+	if IsMCP() {
+		return 0, fmt.Errorf("database size not available with MCP backend")
+	}
+	return sizeBytesSQLite()
+}
+
 // FormatEmbedding converts a float64 slice to a string representation.
 // This is used by some backends or for logging.
 func FormatEmbedding(embedding []float64) string {
@@ -106,3 +586,80 @@ func FormatEmbedding(embedding []float64) string {
 	embeddingStr += "]"
 	return embeddingStr
 }
+
+// NormalizeTags lower-cases, trims and de-duplicates tags, dropping empty
+// ones and splitting any that contain commas.
+func NormalizeTags(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		for _, part := range strings.Split(t, ",") {
+			part = strings.ToLower(strings.TrimSpace(part))
+			if part == "" || seen[part] {
+				continue
+			}
+			seen[part] = true
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// listCursor is the decoded form of CommandPage.NextCursor. SQLite pages
+// resume after (Value, ID) in sort order; MCP pages resume at Offset.
+type listCursor struct {
+	Sort   string `json:"s"`
+	Desc   bool   `json:"d,omitempty"`
+	Value  string `json:"v,omitempty"`
+	ID     int    `json:"i,omitempty"`
+	Offset int    `json:"o,omitempty"`
+}
+
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListOptions)
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListOptions)
+	}
+	return &c, nil
+}
+
+// normalizeListOptions applies defaults and validates the sort and cursor.
+func normalizeListOptions(opts ListOptions) (ListOptions, *listCursor, error) {
+	if opts.Limit <= 0 || opts.Limit > MaxPageSize {
+		opts.Limit = DefaultPageSize
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+	opts.Sort = strings.ToLower(strings.TrimSpace(opts.Sort))
+	switch opts.Sort {
+	case "":
+		opts.Sort = SortID
+	case SortID, SortCreated, SortUpdated, SortUsage:
+	default:
+		return opts, nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidListOptions, opts.Sort)
+	}
+	opts.Query = strings.TrimSpace(opts.Query)
+	opts.Tag = strings.ToLower(strings.TrimSpace(opts.Tag))
+
+	if opts.Cursor == "" {
+		return opts, nil, nil
+	}
+	cur, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return opts, nil, err
+	}
+	if cur.Sort != opts.Sort || cur.Desc != opts.Desc {
+		return opts, nil, fmt.Errorf("%w: cursor does not match sort order", ErrInvalidListOptions)
+	}
+	return opts, cur, nil
+}
@@ -3,7 +3,8 @@ package database
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/gcclinux/scmd/internal/config"
@@ -27,6 +28,8 @@ type mcpRecord struct {
 	Content   string            `json:"content"`
 	Metadata  map[string]string `json:"metadata"`
 	Embedding []float64         `json:"embedding,omitempty"`
+	CreatedAt string            `json:"created_at,omitempty"`
+	UpdatedAt string            `json:"updated_at,omitempty"`
 }
 
 // Bridge function variables — set by the main package at startup.
@@ -34,6 +37,10 @@ var (
 	// MCPListDataFn lists records. Returns JSON-encoded []mcpRecord.
 	MCPListDataFn func(namespace string, limit, offset int) ([]byte, error)
 
+	// MCPListPageFn lists one page of records. Returns JSON-encoded
+	// []mcpRecord and the total number of records in the namespace.
+	MCPListPageFn func(namespace string, limit, offset int) ([]byte, int, error)
+
 	// MCPStoreDataFn stores a record on the MCP server.
 	MCPStoreDataFn func(key, content string, embedding []float64, metadata map[string]string) error
 
@@ -65,9 +72,12 @@ var (
 func (r *mcpRecord) toCommandRecord() CommandRecord {
 	ids := MCPIDMapAssignFn([]string{r.ID})
 	return CommandRecord{
-		Id:   ids[0],
-		Key:  r.Key,
-		Data: r.Content,
+		Id:      ids[0],
+		Key:     r.Key,
+		Data:    r.Content,
+		Tags:    NormalizeTags([]string{r.Metadata["tags"]}),
+		Created: r.CreatedAt,
+		Updated: r.UpdatedAt,
 	}
 }
 
@@ -175,24 +185,30 @@ func searchCommandsMCP(pattern string) ([]byte, error) {
 
 // addCommandMCP adds a new command via the MCP backend. If embeddingFn is
 // provided, an embedding is generated and included in the store call.
-func addCommandMCP(command, description string, embeddingFn func(string) ([]float64, error)) (bool, error) {
+func addCommandMCP(command, description string, origin Origin, embeddingFn func(string) ([]float64, error)) (bool, error) {
 	var embedding []float64
 	if embeddingFn != nil {
 		text := command + " " + description
 		emb, err := embeddingFn(text)
 		if err != nil {
-			log.Printf("Warning: embedding generation failed: %v\n", err)
+			logger.Warn("embedding generation failed", "err", err)
 		} else {
 			embedding = emb
-			log.Println("✓ Generated embedding for new command")
+			logger.Debug("generated embedding for new command")
 		}
 	}
 
 	if len(embedding) == 0 {
-		log.Println("⚠ No embedding provider available, saving without vector")
+		logger.Warn("no embedding provider available, saving without vector")
 	}
 
 	metadata := map[string]string{"source": "scmd"}
+	if origin.Source != "" {
+		metadata["origin"] = origin.Source
+	}
+	if origin.Author != "" {
+		metadata["author"] = origin.Author
+	}
 	err := MCPStoreDataFn(command, description, embedding, metadata)
 	if err != nil {
 		return false, fmt.Errorf("error storing command via MCP: %v", err)
@@ -225,6 +241,22 @@ func deleteCommandMCP(id int) (bool, error) {
 	return MCPDeleteDataFn(uuid)
 }
 
+// deleteCommandsMCP deletes commands one at a time. The MCP server has no
+// transactions, so a failure part-way leaves earlier deletes applied.
+func deleteCommandsMCP(ids []int) (int, error) {
+	deleted := 0
+	for _, id := range ids {
+		ok, err := deleteCommandMCP(id)
+		if err != nil {
+			return deleted, fmt.Errorf("error deleting command %d: %v", id, err)
+		}
+		if ok {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
 // getCommandByIDMCP retrieves a single command by resolving the integer ID
 // to a UUID and calling GetData on the MCP server.
 func getCommandByIDMCP(id int) (*CommandRecord, error) {
@@ -334,6 +366,31 @@ func searchByVectorMCP(embedding []float64, limit int) ([]CommandRecord, error)
 	return results, nil
 }
 
+// similarCommandsMCP scores the records returned by QuerySimilar against
+// embedding. Records the server returns without an embedding are skipped.
+func similarCommandsMCP(embedding []float64, limit int, minSimilarity float64) ([]SimilarCommand, error) {
+	data, err := MCPQuerySimilarFn(embedding, config.TableName(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("vector search error: %v", err)
+	}
+	records, err := parseMCPRecords(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SimilarCommand
+	for i := range records {
+		if len(records[i].Embedding) == 0 {
+			continue
+		}
+		if sim := cosineSimilarity(embedding, records[i].Embedding); sim >= minSimilarity {
+			results = append(results, SimilarCommand{Record: records[i].toCommandRecord(), Similarity: sim})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Similarity > results[j].Similarity })
+	return results, nil
+}
+
 // listAllCommandsMCP returns all commands from the MCP backend ordered by assigned ID.
 func listAllCommandsMCP() ([]CommandRecord, error) {
 	namespace := config.TableName()
@@ -354,3 +411,86 @@ func listAllCommandsMCP() ([]CommandRecord, error) {
 	}
 	return results, nil
 }
+
+// listCommandsMCP returns one page of commands from the MCP backend. Plain
+// listings in ID order are paged by the server; text and tag filters and the
+// other sort orders need the whole namespace and are applied client-side.
+func listCommandsMCP(opts ListOptions, cur *listCursor) (*CommandPage, error) {
+	namespace := config.TableName()
+	offset := opts.Offset
+	if cur != nil {
+		offset = cur.Offset
+	}
+	page := &CommandPage{}
+
+	if opts.Query == "" && opts.Tag == "" && opts.Sort == SortID && !opts.Desc && MCPListPageFn != nil {
+		data, total, err := MCPListPageFn(namespace, opts.Limit, offset)
+		if err != nil {
+			return nil, fmt.Errorf("error listing MCP data: %v", err)
+		}
+		records, err := parseMCPRecords(data)
+		if err != nil {
+			return nil, err
+		}
+		for i := range records {
+			page.Records = append(page.Records, records[i].toCommandRecord())
+		}
+		page.Total = total
+		if next := offset + len(records); len(records) > 0 && next < total {
+			page.NextCursor = encodeCursor(listCursor{Sort: opts.Sort, Desc: opts.Desc, Offset: next})
+		}
+		return page, nil
+	}
+
+	all, err := listAllCommandsMCP()
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(strings.ToLower(opts.Query))
+	var matched []CommandRecord
+	for _, r := range all {
+		if matchesAllWords(r, words) && (opts.Tag == "" || slices.Contains(r.Tags, opts.Tag)) {
+			matched = append(matched, r)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if opts.Desc {
+			a, b = b, a
+		}
+		switch opts.Sort {
+		case SortCreated:
+			if a.Created != b.Created {
+				return a.Created < b.Created
+			}
+		case SortUpdated:
+			if a.Updated != b.Updated {
+				return a.Updated < b.Updated
+			}
+		}
+		return a.Id < b.Id
+	})
+
+	page.Total = len(matched)
+	if offset < len(matched) {
+		end := min(offset+opts.Limit, len(matched))
+		page.Records = matched[offset:end]
+		if end < len(matched) {
+			page.NextCursor = encodeCursor(listCursor{Sort: opts.Sort, Desc: opts.Desc, Offset: end})
+		}
+	}
+	return page, nil
+}
+
+// matchesAllWords reports whether every lower-case word appears in the
+// record's key or data.
+func matchesAllWords(r CommandRecord, words []string) bool {
+	key, data := strings.ToLower(r.Key), strings.ToLower(r.Data)
+	for _, w := range words {
+		if !strings.Contains(key, w) && !strings.Contains(data, w) {
+			return false
+		}
+	}
+	return true
+}
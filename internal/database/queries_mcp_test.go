@@ -11,6 +11,7 @@ import (
 // state to other tests.
 func saveBridgeFns() func() {
 	origList := MCPListDataFn
+	origListPage := MCPListPageFn
 	origStore := MCPStoreDataFn
 	origGet := MCPGetDataFn
 	origUpdate := MCPUpdateDataFn
@@ -21,6 +22,7 @@ func saveBridgeFns() func() {
 	origCheck := MCPCheckCommandExistsFn
 	return func() {
 		MCPListDataFn = origList
+		MCPListPageFn = origListPage
 		MCPStoreDataFn = origStore
 		MCPGetDataFn = origGet
 		MCPUpdateDataFn = origUpdate
@@ -186,7 +188,7 @@ func TestAddCommand_DispatchesToMCP(t *testing.T) {
 	defer restore()
 	called := installMockBridges(t)
 
-	_, err := AddCommand("docker ps", "list containers", nil)
+	_, err := AddCommand("docker ps", "list containers", Origin{}, nil)
 	if err != nil {
 		t.Fatalf("AddCommand error: %v", err)
 	}
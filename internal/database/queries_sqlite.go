@@ -4,9 +4,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"math"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gcclinux/scmd/internal/config"
 )
@@ -19,7 +22,7 @@ func searchCommandsSQLite(pattern string) ([]byte, error) {
 	var args []interface{}
 
 	if pattern == "" {
-		query = fmt.Sprintf("SELECT id, key, data FROM %s ORDER BY id", tableName)
+		query = fmt.Sprintf("SELECT id, key, data FROM %s WHERE %s ORDER BY id", tableName, sqliteLive)
 	} else {
 		if strings.Contains(pattern, ",") {
 			patterns := strings.Split(pattern, ",")
@@ -45,10 +48,10 @@ func searchCommandsSQLite(pattern string) ([]byte, error) {
 				}
 			}
 			if len(conditions) == 0 {
-				query = fmt.Sprintf("SELECT id, key, data FROM %s ORDER BY id", tableName)
+				query = fmt.Sprintf("SELECT id, key, data FROM %s WHERE %s ORDER BY id", tableName, sqliteLive)
 			} else {
-				query = fmt.Sprintf("SELECT id, key, data FROM %s WHERE %s ORDER BY id",
-					tableName, strings.Join(conditions, " OR "))
+				query = fmt.Sprintf("SELECT id, key, data FROM %s WHERE %s AND (%s) ORDER BY id",
+					tableName, sqliteLive, strings.Join(conditions, " OR "))
 			}
 		} else {
 			words := strings.Fields(pattern)
@@ -62,10 +65,10 @@ func searchCommandsSQLite(pattern string) ([]byte, error) {
 				}
 			}
 			if len(conditions) == 0 {
-				query = fmt.Sprintf("SELECT id, key, data FROM %s ORDER BY id", tableName)
+				query = fmt.Sprintf("SELECT id, key, data FROM %s WHERE %s ORDER BY id", tableName, sqliteLive)
 			} else {
-				query = fmt.Sprintf("SELECT id, key, data FROM %s WHERE %s ORDER BY id",
-					tableName, strings.Join(conditions, " AND "))
+				query = fmt.Sprintf("SELECT id, key, data FROM %s WHERE %s AND %s ORDER BY id",
+					tableName, sqliteLive, strings.Join(conditions, " AND "))
 			}
 		}
 	}
@@ -91,51 +94,59 @@ func searchCommandsSQLite(pattern string) ([]byte, error) {
 	return json.Marshal(results)
 }
 
-// addCommandSQLite adds a new command to the SQLite database.
-func addCommandSQLite(command, description string, embeddingFn func(string) ([]float64, error)) (bool, error) {
+// addCommandSQLite adds a new command to the SQLite database together with
+// its first revision.
+func addCommandSQLite(command, description string, origin Origin, embeddingFn func(string) ([]float64, error)) (bool, error) {
 	tableName := sqliteTableName()
 
-	var embedding []float64
-	hasEmbedding := false
-
+	var embedding any // NULL unless an embedding is generated
 	if embeddingFn != nil {
 		text := command + " " + description
 		emb, err := embeddingFn(text)
 		if err != nil {
-			log.Printf("Warning: embedding generation failed: %v\n", err)
+			logger.Warn("embedding generation failed", "err", err)
 		} else {
-			embedding = emb
-			hasEmbedding = true
+			embeddingJSON, err := json.Marshal(emb)
+			if err != nil {
+				return false, fmt.Errorf("error marshaling embedding: %v", err)
+			}
+			embedding = string(embeddingJSON)
+			logger.Debug("generated embedding for new command")
 		}
 	}
+	if embedding == nil {
+		logger.Warn("no embedding provider available, saving without vector")
+	}
 
-	if hasEmbedding {
-		embeddingJSON, err := json.Marshal(embedding)
-		if err != nil {
-			return false, fmt.Errorf("error marshaling embedding: %v", err)
-		}
-		query := fmt.Sprintf("INSERT INTO %s (key, data, embedding) VALUES (?, ?, ?)", tableName)
-		_, err = db.Exec(query, command, description, string(embeddingJSON))
-		if err != nil {
-			return false, fmt.Errorf("error inserting command with embedding: %v", err)
-		}
-		log.Println("✓ Generated embedding for new command")
-	} else {
-		log.Println("⚠ No embedding provider available, saving without vector")
-		query := fmt.Sprintf("INSERT INTO %s (key, data) VALUES (?, ?)", tableName)
-		_, err := db.Exec(query, command, description)
-		if err != nil {
-			return false, fmt.Errorf("error inserting command: %v", err)
-		}
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %v", err)
 	}
+	defer tx.Rollback()
 
+	query := fmt.Sprintf("INSERT INTO %s (key, data, embedding) VALUES (?, ?, ?)", tableName)
+	result, err := tx.Exec(query, command, description, embedding)
+	if err != nil {
+		return false, fmt.Errorf("error inserting command: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, fmt.Errorf("error reading new command ID: %v", err)
+	}
+	if err := insertRevisionSQLite(tx, int(id), origin); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing command: %v", err)
+	}
 	return true, nil
 }
 
-// checkCommandExistsSQLite checks if a command exists in SQLite.
+// checkCommandExistsSQLite checks if a command exists in SQLite. Commands
+// in the trash do not count.
 func checkCommandExistsSQLite(command string) (bool, error) {
 	tableName := sqliteTableName()
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE key = ?", tableName)
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE key = ? AND %s", tableName, sqliteLive)
 	var count int
 	err := db.QueryRow(query, command).Scan(&count)
 	if err != nil {
@@ -144,10 +155,291 @@ func checkCommandExistsSQLite(command string) (bool, error) {
 	return count > 0, nil
 }
 
-// deleteCommandSQLite deletes a command from SQLite by ID.
+// updateCommandSQLite updates a command in SQLite, re-embedding it when its
+// text changed, and records a revision. Updates that change nothing are
+// skipped.
+func updateCommandSQLite(id int, command, description string, tags []string, origin Origin, embeddingFn func(string) ([]float64, error)) error {
+	current, err := getCommandByIDSQLite(id)
+	if err != nil {
+		return err
+	}
+	if tags == nil {
+		tags = current.Tags
+	}
+	if command == current.Key && description == current.Data && slices.Equal(tags, current.Tags) {
+		return nil
+	}
+
+	sets := []string{"key = ?", "data = ?", "tags = ?", "updated_at = CURRENT_TIMESTAMP"}
+	args := []any{command, description, strings.Join(tags, ",")}
+	if command != current.Key || description != current.Data {
+		var embedding any // NULL unless a new embedding is generated
+		if embeddingFn != nil {
+			emb, err := embeddingFn(command + " " + description)
+			if err != nil {
+				logger.Warn("embedding generation failed", "id", id, "err", err)
+			} else if len(emb) > 0 {
+				embeddingJSON, err := json.Marshal(emb)
+				if err != nil {
+					return fmt.Errorf("error marshaling embedding: %v", err)
+				}
+				embedding = string(embeddingJSON)
+			}
+		}
+		sets = append(sets, "embedding = ?")
+		args = append(args, embedding)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := ensureBaselineRevisionSQLite(tx, id); err != nil {
+		return err
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = ? AND %s", sqliteTableName(), strings.Join(sets, ", "), sqliteLive)
+	if _, err := tx.Exec(query, append(args, id)...); err != nil {
+		return fmt.Errorf("error updating command: %v", err)
+	}
+	if err := insertRevisionSQLite(tx, id, origin); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing update: %v", err)
+	}
+	return nil
+}
+
+// insertRevisionSQLite snapshots the current state of a command as its next
+// revision.
+func insertRevisionSQLite(tx *sql.Tx, id int, origin Origin) error {
+	revisions := sqliteRevisionTable()
+	query := fmt.Sprintf(`INSERT INTO %s (command_id, rev, key, data, tags, author, source)
+		SELECT id, (SELECT COALESCE(MAX(rev), 0) + 1 FROM %s WHERE command_id = ?), key, data, tags, ?, ?
+		FROM %s WHERE id = ?`, revisions, revisions, sqliteTableName())
+	if _, err := tx.Exec(query, id, origin.Author, origin.Source, id); err != nil {
+		return fmt.Errorf("error recording revision: %v", err)
+	}
+	return nil
+}
+
+// ensureBaselineRevisionSQLite records the current state of a command that
+// predates revision history, so its first edit does not lose the original
+// text. The baseline carries no author or source.
+func ensureBaselineRevisionSQLite(tx *sql.Tx, id int) error {
+	query := fmt.Sprintf(`INSERT INTO %[1]s (command_id, rev, key, data, tags, created_at)
+		SELECT id, 1, key, data, tags, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP) FROM %[2]s
+		WHERE id = ? AND NOT EXISTS (SELECT 1 FROM %[1]s WHERE command_id = ?)`, sqliteRevisionTable(), sqliteTableName())
+	if _, err := tx.Exec(query, id, id); err != nil {
+		return fmt.Errorf("error recording baseline revision: %v", err)
+	}
+	return nil
+}
+
+// listRevisionsSQLite returns the revisions of a command, oldest first.
+func listRevisionsSQLite(id int) ([]Revision, error) {
+	query := fmt.Sprintf(`SELECT command_id, rev, key, data, tags, author, source, COALESCE(created_at, '')
+		FROM %s WHERE command_id = ? ORDER BY rev`, sqliteRevisionTable())
+	rows, err := db.Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying revisions: %v", err)
+	}
+	defer rows.Close()
+
+	var revs []Revision
+	for rows.Next() {
+		var r Revision
+		var tags string
+		if err := rows.Scan(&r.CommandID, &r.Rev, &r.Key, &r.Data, &tags, &r.Author, &r.Source, &r.Created); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		r.Tags = splitTags(tags)
+		revs = append(revs, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return revs, nil
+}
+
+// recordUsageSQLite inserts one usage event for a live command. Events for
+// unknown or deleted commands are ignored.
+func recordUsageSQLite(id int, event string, at time.Time) error {
+	return insertUsageSQLite("id = ?", id, event, at)
+}
+
+// recordUsageByKeySQLite inserts one usage event for every live command
+// whose text is exactly key.
+func recordUsageByKeySQLite(key, event string, at time.Time) error {
+	return insertUsageSQLite("key = ?", key, event, at)
+}
+
+func insertUsageSQLite(cond string, arg any, event string, at time.Time) error {
+	if db == nil {
+		return fmt.Errorf("database not connected")
+	}
+	query := fmt.Sprintf("INSERT INTO %s (command_id, event, used_at) SELECT id, ?, ? FROM %s WHERE %s AND %s",
+		sqliteUsageTable(), sqliteTableName(), cond, sqliteLive)
+	if _, err := db.Exec(query, event, at.Unix(), arg); err != nil {
+		return fmt.Errorf("error recording usage: %v", err)
+	}
+	return nil
+}
+
+// usageStatsSQLite aggregates the usage events at or after since of the
+// live commands in ids, or of every command when ids is empty. IDs are
+// queried in batches to stay under SQLite's variable limit.
+func usageStatsSQLite(since time.Time, ids []int) (map[int]UsageStat, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+	var cutoff int64
+	if !since.IsZero() {
+		cutoff = since.Unix()
+	}
+
+	stats := make(map[int]UsageStat)
+	if len(ids) == 0 {
+		return stats, usageStatsBatchSQLite(stats, cutoff, nil)
+	}
+	const batch = 500
+	for start := 0; start < len(ids); start += batch {
+		if err := usageStatsBatchSQLite(stats, cutoff, ids[start:min(start+batch, len(ids))]); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// usageStatsBatchSQLite adds the usage of the commands in ids, or of every
+// command when ids is nil, to stats.
+func usageStatsBatchSQLite(stats map[int]UsageStat, cutoff int64, ids []int) error {
+	filter := ""
+	args := []any{cutoff}
+	if ids != nil {
+		filter = " AND u.command_id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	query := fmt.Sprintf(`SELECT d.id, d.key,
+		SUM(u.event = 'view'), SUM(u.event = 'copy'), SUM(u.event = 'exec'), MAX(u.used_at)
+		FROM %s u JOIN %s d ON d.id = u.command_id
+		WHERE d.%s AND u.used_at >= ?%s
+		GROUP BY d.id`, sqliteUsageTable(), sqliteTableName(), sqliteLive, filter)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("error querying usage: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s UsageStat
+		var last int64
+		if err := rows.Scan(&s.CommandID, &s.Key, &s.Views, &s.Copies, &s.Execs, &last); err != nil {
+			return fmt.Errorf("error scanning row: %v", err)
+		}
+		s.LastUsed = time.Unix(last, 0)
+		stats[s.CommandID] = s
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %v", err)
+	}
+	return nil
+}
+
+// pinCommandSQLite pins a live command for owner, keeping at most MaxPins
+// per owner. It reports false when the command was already pinned.
+func pinCommandSQLite(owner string, id int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var live int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE id = ? AND %s", sqliteTableName(), sqliteLive)
+	if err := tx.QueryRow(query, id).Scan(&live); err != nil {
+		return false, fmt.Errorf("error checking command: %v", err)
+	}
+	if live == 0 {
+		return false, ErrCommandNotFound
+	}
+
+	var pinned, count int
+	query = fmt.Sprintf("SELECT COALESCE(SUM(command_id = ?), 0), COUNT(*) FROM %s WHERE owner = ?", sqlitePinTable())
+	if err := tx.QueryRow(query, id, owner).Scan(&pinned, &count); err != nil {
+		return false, fmt.Errorf("error counting pins: %v", err)
+	}
+	if pinned > 0 {
+		return false, nil
+	}
+	if count >= MaxPins {
+		return false, ErrTooManyPins
+	}
+
+	query = fmt.Sprintf("INSERT INTO %s (owner, command_id, pinned_at) VALUES (?, ?, ?)", sqlitePinTable())
+	if _, err := tx.Exec(query, owner, id, time.Now().Unix()); err != nil {
+		return false, fmt.Errorf("error pinning command: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing pin: %v", err)
+	}
+	return true, nil
+}
+
+// unpinCommandSQLite removes a pin. It reports false when there was none.
+func unpinCommandSQLite(owner string, id int) (bool, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE owner = ? AND command_id = ?", sqlitePinTable())
+	result, err := db.Exec(query, owner, id)
+	if err != nil {
+		return false, fmt.Errorf("error unpinning command: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking affected rows: %v", err)
+	}
+	return rows > 0, nil
+}
+
+// listPinsSQLite returns the live commands pinned by owner, in the order
+// they were pinned. Pins of trashed commands are kept, so a restore brings
+// them back, but are not listed.
+func listPinsSQLite(owner string) ([]CommandRecord, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+	query := fmt.Sprintf(`SELECT d.id, d.key, d.data, d.tags, COALESCE(d.created_at, ''), COALESCE(d.updated_at, '')
+		FROM %s p JOIN %s d ON d.id = p.command_id
+		WHERE p.owner = ? AND d.%s ORDER BY p.pinned_at, p.rowid`, sqlitePinTable(), sqliteTableName(), sqliteLive)
+	rows, err := db.Query(query, owner)
+	if err != nil {
+		return nil, fmt.Errorf("error querying pins: %v", err)
+	}
+	defer rows.Close()
+
+	var results []CommandRecord
+	for rows.Next() {
+		var record CommandRecord
+		var tags string
+		if err := rows.Scan(&record.Id, &record.Key, &record.Data, &tags, &record.Created, &record.Updated); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		record.Tags = splitTags(tags)
+		results = append(results, record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return results, nil
+}
+
+// deleteCommandSQLite moves a command to the trash by setting deleted_at.
 func deleteCommandSQLite(id int) (bool, error) {
 	tableName := sqliteTableName()
-	query := fmt.Sprintf("DELETE FROM %s WHERE id = ?", tableName)
+	query := fmt.Sprintf("UPDATE %s SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND %s", tableName, sqliteLive)
 	result, err := db.Exec(query, id)
 	if err != nil {
 		return false, fmt.Errorf("error deleting command: %v", err)
@@ -159,25 +451,229 @@ func deleteCommandSQLite(id int) (bool, error) {
 	return rows > 0, nil
 }
 
+// deleteCommandsSQLite moves several commands to the trash in one
+// transaction.
+func deleteCommandsSQLite(ids []int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("UPDATE %s SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND %s", sqliteTableName(), sqliteLive))
+	if err != nil {
+		return 0, fmt.Errorf("error preparing delete: %v", err)
+	}
+	defer stmt.Close()
+
+	deleted := 0
+	for _, id := range ids {
+		result, err := stmt.Exec(id)
+		if err != nil {
+			return 0, fmt.Errorf("error deleting command %d: %v", id, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			deleted++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing deletes: %v", err)
+	}
+	return deleted, nil
+}
+
+// listTrashSQLite returns the commands in the trash, most recently deleted
+// first.
+func listTrashSQLite() ([]CommandRecord, error) {
+	query := fmt.Sprintf(`SELECT id, key, data, tags, COALESCE(created_at, ''), COALESCE(updated_at, ''), deleted_at
+		FROM %s WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`, sqliteTableName())
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying trash: %v", err)
+	}
+	defer rows.Close()
+
+	var results []CommandRecord
+	for rows.Next() {
+		var record CommandRecord
+		var tags string
+		if err := rows.Scan(&record.Id, &record.Key, &record.Data, &tags,
+			&record.Created, &record.Updated, &record.Deleted); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		record.Tags = splitTags(tags)
+		results = append(results, record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return results, nil
+}
+
+// restoreCommandSQLite takes a command out of the trash. It refuses when a
+// live command with the same text was added in the meantime.
+func restoreCommandSQLite(id int) (bool, error) {
+	table := sqliteTableName()
+	var key string
+	err := db.QueryRow(fmt.Sprintf("SELECT key FROM %s WHERE id = ? AND deleted_at IS NOT NULL", table), id).Scan(&key)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading trashed command: %v", err)
+	}
+	exists, err := checkCommandExistsSQLite(key)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, ErrCommandExists
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", table)
+	result, err := db.Exec(query, id)
+	if err != nil {
+		return false, fmt.Errorf("error restoring command: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking affected rows: %v", err)
+	}
+	return n > 0, nil
+}
+
+// purgeTrashSQLite permanently removes commands deleted before cutoff, along
+// with their usage history, revisions and pins, in one transaction.
+func purgeTrashSQLite(cutoff time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	table := sqliteTableName()
+	stamp := cutoff.UTC().Format("2006-01-02 15:04:05")
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE command_id IN
+		(SELECT id FROM %s WHERE deleted_at IS NOT NULL AND deleted_at <= ?)`, sqliteUsageTable(), table), stamp); err != nil {
+		return 0, fmt.Errorf("error purging usage history: %v", err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE command_id IN
+		(SELECT id FROM %s WHERE deleted_at IS NOT NULL AND deleted_at <= ?)`, sqliteRevisionTable(), table), stamp); err != nil {
+		return 0, fmt.Errorf("error purging revisions: %v", err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE command_id IN
+		(SELECT id FROM %s WHERE deleted_at IS NOT NULL AND deleted_at <= ?)`, sqlitePinTable(), table), stamp); err != nil {
+		return 0, fmt.Errorf("error purging pins: %v", err)
+	}
+	result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE deleted_at IS NOT NULL AND deleted_at <= ?", table), stamp)
+	if err != nil {
+		return 0, fmt.Errorf("error purging trash: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error checking affected rows: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing purge: %v", err)
+	}
+	return int(n), nil
+}
+
+// retagCommandsSQLite adds and removes tags on several commands in one
+// transaction, recording a revision for each command that changed.
+func retagCommandsSQLite(ids []int, add, remove []string, origin Origin) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	table := sqliteTableName()
+	changed := 0
+	for _, id := range ids {
+		var current string
+		err := tx.QueryRow(fmt.Sprintf("SELECT tags FROM %s WHERE id = ? AND %s", table, sqliteLive), id).Scan(&current)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error reading tags of command %d: %v", id, err)
+		}
+
+		tags := NormalizeTags(append(splitTags(current), add...))
+		tags = slices.DeleteFunc(tags, func(t string) bool { return slices.Contains(remove, t) })
+		next := strings.Join(tags, ",")
+		if next == current {
+			continue
+		}
+		if err := ensureBaselineRevisionSQLite(tx, id); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET tags = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", table), next, id); err != nil {
+			return 0, fmt.Errorf("error updating tags of command %d: %v", id, err)
+		}
+		if err := insertRevisionSQLite(tx, id, origin); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing tags: %v", err)
+	}
+	return changed, nil
+}
+
+// updateEmbeddingsSQLite stores several embeddings in one transaction.
+func updateEmbeddingsSQLite(embeddings map[int][]float64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("UPDATE %s SET embedding = ? WHERE id = ?", sqliteTableName()))
+	if err != nil {
+		return fmt.Errorf("error preparing update: %v", err)
+	}
+	defer stmt.Close()
+
+	for id, emb := range embeddings {
+		embeddingJSON, err := json.Marshal(emb)
+		if err != nil {
+			return fmt.Errorf("error marshaling embedding: %v", err)
+		}
+		if _, err := stmt.Exec(string(embeddingJSON), id); err != nil {
+			return fmt.Errorf("error updating embedding for ID %d: %v", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing embeddings: %v", err)
+	}
+	return nil
+}
+
 // getCommandByIDSQLite retrieves a single command record by ID from SQLite.
+// Commands in the trash are not found.
 func getCommandByIDSQLite(id int) (*CommandRecord, error) {
 	tableName := sqliteTableName()
-	query := fmt.Sprintf("SELECT id, key, data FROM %s WHERE id = ?", tableName)
+	query := fmt.Sprintf("SELECT id, key, data, tags, COALESCE(created_at, ''), COALESCE(updated_at, '') FROM %s WHERE id = ? AND %s", tableName, sqliteLive)
 	var record CommandRecord
-	err := db.QueryRow(query, id).Scan(&record.Id, &record.Key, &record.Data)
+	var tags string
+	err := db.QueryRow(query, id).Scan(&record.Id, &record.Key, &record.Data, &tags, &record.Created, &record.Updated)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("no command found with ID %d", id)
 		}
 		return nil, fmt.Errorf("error querying command: %v", err)
 	}
+	record.Tags = splitTags(tags)
 	return &record, nil
 }
 
 // getCommandsWithoutEmbeddingsSQLite returns commands without embeddings from SQLite.
 func getCommandsWithoutEmbeddingsSQLite() ([]CommandRecord, error) {
 	tableName := sqliteTableName()
-	query := fmt.Sprintf("SELECT id, key, data FROM %s WHERE embedding IS NULL OR embedding = ''", tableName)
+	query := fmt.Sprintf("SELECT id, key, data FROM %s WHERE (embedding IS NULL OR embedding = '') AND %s", tableName, sqliteLive)
 	rows, err := db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("error querying database: %v", err)
@@ -211,12 +707,12 @@ func updateEmbeddingSQLite(id int, embedding []float64) error {
 func getEmbeddingStatsSQLite() (total int, withEmbeddings int, err error) {
 	tableName := sqliteTableName()
 
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", tableName, sqliteLive)
 	if err = db.QueryRow(query).Scan(&total); err != nil {
 		return 0, 0, fmt.Errorf("error counting commands: %v", err)
 	}
 
-	query = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE embedding IS NOT NULL AND embedding != ''", tableName)
+	query = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE embedding IS NOT NULL AND embedding != '' AND %s", tableName, sqliteLive)
 	if err = db.QueryRow(query).Scan(&withEmbeddings); err != nil {
 		return 0, 0, fmt.Errorf("error counting embeddings: %v", err)
 	}
@@ -227,7 +723,7 @@ func getEmbeddingStatsSQLite() (total int, withEmbeddings int, err error) {
 // listAllCommandsSQLite returns all commands from SQLite ordered by ID.
 func listAllCommandsSQLite() ([]CommandRecord, error) {
 	tableName := sqliteTableName()
-	query := fmt.Sprintf("SELECT id, key, data FROM %s ORDER BY id", tableName)
+	query := fmt.Sprintf("SELECT id, key, data FROM %s WHERE %s ORDER BY id", tableName, sqliteLive)
 	rows, err := db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("error querying all commands: %v", err)
@@ -248,24 +744,191 @@ func listAllCommandsSQLite() ([]CommandRecord, error) {
 	return results, nil
 }
 
+// sqliteSortExpr returns the ORDER BY expression for a ListOptions sort.
+func sqliteSortExpr(sort string) string {
+	switch sort {
+	case SortCreated:
+		return "COALESCE(d.created_at, '')"
+	case SortUpdated:
+		return "COALESCE(d.updated_at, '')"
+	case SortUsage:
+		return "COALESCE(u.uses, 0)"
+	}
+	return "d.id"
+}
+
+// listCommandsSQLite returns one page of commands from SQLite. Filtering,
+// sorting and paging all happen in SQL; cursors use keyset pagination on
+// (sort value, id) so deep pages stay cheap.
+func listCommandsSQLite(opts ListOptions, cur *listCursor) (*CommandPage, error) {
+	from := fmt.Sprintf(`%s d LEFT JOIN (SELECT command_id, COUNT(*) AS uses FROM %s GROUP BY command_id) u
+		ON u.command_id = d.id`, sqliteTableName(), sqliteUsageTable())
+
+	where := []string{"d." + sqliteLive}
+	var args []any
+	for _, word := range strings.Fields(opts.Query) {
+		p := "%" + escapeLike(word) + "%"
+		where = append(where, `(d.key LIKE ? ESCAPE '\' OR d.data LIKE ? ESCAPE '\')`)
+		args = append(args, p, p)
+	}
+	if opts.Tag != "" {
+		where = append(where, `(',' || d.tags || ',') LIKE ? ESCAPE '\'`)
+		args = append(args, "%,"+escapeLike(opts.Tag)+",%")
+	}
+
+	filter := " WHERE " + strings.Join(where, " AND ")
+	page := &CommandPage{}
+	if err := db.QueryRow("SELECT COUNT(*) FROM "+from+filter, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("error counting commands: %v", err)
+	}
+
+	sortExpr := sqliteSortExpr(opts.Sort)
+	dir, cmp := "ASC", ">"
+	if opts.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if cur != nil {
+		var v any = cur.Value
+		if opts.Sort == SortID || opts.Sort == SortUsage {
+			n, err := strconv.ParseInt(cur.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListOptions)
+			}
+			v = n
+		}
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND d.id %[2]s ?))", sortExpr, cmp))
+		args = append(args, v, v, cur.ID)
+	}
+	filter = " WHERE " + strings.Join(where, " AND ")
+
+	query := fmt.Sprintf(`SELECT d.id, d.key, d.data, d.tags, COALESCE(d.created_at, ''), COALESCE(d.updated_at, ''), COALESCE(u.uses, 0)
+		FROM %s%s ORDER BY %s %s, d.id %s LIMIT ?`, from, filter, sortExpr, dir, dir)
+	args = append(args, opts.Limit+1)
+	if cur == nil && opts.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, opts.Offset)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing commands: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record CommandRecord
+		var tags string
+		if err := rows.Scan(&record.Id, &record.Key, &record.Data, &tags,
+			&record.Created, &record.Updated, &record.Usage); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		record.Tags = splitTags(tags)
+		page.Records = append(page.Records, record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	if len(page.Records) > opts.Limit {
+		page.Records = page.Records[:opts.Limit]
+		last := page.Records[len(page.Records)-1]
+		next := listCursor{Sort: opts.Sort, Desc: opts.Desc, ID: last.Id}
+		switch opts.Sort {
+		case SortCreated:
+			next.Value = last.Created
+		case SortUpdated:
+			next.Value = last.Updated
+		case SortUsage:
+			next.Value = strconv.Itoa(last.Usage)
+		default:
+			next.Value = strconv.Itoa(last.Id)
+		}
+		page.NextCursor = encodeCursor(next)
+	}
+	return page, nil
+}
+
+// setTagsSQLite stores the comma-separated tags of a command and records a
+// revision. Setting the tags a command already has is skipped.
+func setTagsSQLite(id int, tags []string, origin Origin) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	table := sqliteTableName()
+	next := strings.Join(tags, ",")
+	var current string
+	err = tx.QueryRow(fmt.Sprintf("SELECT tags FROM %s WHERE id = ? AND %s", table, sqliteLive), id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no command found with ID %d", id)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading tags: %v", err)
+	}
+	if next == current {
+		return nil
+	}
+
+	if err := ensureBaselineRevisionSQLite(tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET tags = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", table), next, id); err != nil {
+		return fmt.Errorf("error updating tags: %v", err)
+	}
+	if err := insertRevisionSQLite(tx, id, origin); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing tags: %v", err)
+	}
+	return nil
+}
+
+// splitTags parses the comma-separated tags column.
+func splitTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // searchByVectorSQLite performs cosine similarity search in SQLite.
 func searchByVectorSQLite(embedding []float64, limit int) ([]CommandRecord, error) {
+	similar, err := similarCommandsSQLite(embedding, limit, -1)
+	if err != nil {
+		return nil, err
+	}
+	var results []CommandRecord
+	for _, s := range similar {
+		results = append(results, s.Record)
+	}
+	return results, nil
+}
+
+// similarCommandsSQLite ranks live commands with embeddings by cosine
+// similarity to embedding, keeping the best limit at or above minSimilarity.
+func similarCommandsSQLite(embedding []float64, limit int, minSimilarity float64) ([]SimilarCommand, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
 	tableName := sqliteTableName()
 
 	// Fetch all rows with embeddings and compute similarity in Go
-	query := fmt.Sprintf("SELECT id, key, data, embedding FROM %s WHERE embedding IS NOT NULL AND embedding != ''", tableName)
+	query := fmt.Sprintf("SELECT id, key, data, embedding FROM %s WHERE embedding IS NOT NULL AND embedding != '' AND %s", tableName, sqliteLive)
 	rows, err := db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("vector search error: %v", err)
 	}
 	defer rows.Close()
 
-	type scored struct {
-		record CommandRecord
-		score  float64
-	}
-	var scored_results []scored
-
+	var scored []SimilarCommand
 	for rows.Next() {
 		var record CommandRecord
 		var embStr string
@@ -276,27 +939,20 @@ func searchByVectorSQLite(embedding []float64, limit int) ([]CommandRecord, erro
 		if err := json.Unmarshal([]byte(embStr), &storedEmb); err != nil {
 			continue
 		}
-		sim := cosineSimilarity(embedding, storedEmb)
-		scored_results = append(scored_results, scored{record: record, score: sim})
-	}
-
-	// Sort by similarity descending
-	for i := 0; i < len(scored_results); i++ {
-		for j := i + 1; j < len(scored_results); j++ {
-			if scored_results[j].score > scored_results[i].score {
-				scored_results[i], scored_results[j] = scored_results[j], scored_results[i]
-			}
+		if sim := cosineSimilarity(embedding, storedEmb); sim >= minSimilarity {
+			scored = append(scored, SimilarCommand{Record: record, Similarity: sim})
 		}
 	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
 
-	var results []CommandRecord
-	for i, s := range scored_results {
-		if i >= limit {
-			break
-		}
-		results = append(results, s.record)
+	// Sort by similarity descending
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].Similarity > scored[j].Similarity })
+	if len(scored) > limit {
+		scored = scored[:limit]
 	}
-	return results, nil
+	return scored, nil
 }
 
 // authenticateUserSQLite validates email and API key in SQLite.
@@ -321,6 +977,237 @@ func authenticateUserSQLite(email, apiKey string) (bool, error) {
 	return count > 0, nil
 }
 
+// saveSessionSQLite inserts or replaces a web session in SQLite.
+func saveSessionSQLite(session SessionRecord) error {
+	query := fmt.Sprintf("INSERT OR REPLACE INTO %s (id, email, csrf_token, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		sqliteSessionTable())
+	_, err := db.Exec(query, session.ID, session.Email, session.CSRFToken,
+		session.CreatedAt.Unix(), session.ExpiresAt.Unix())
+	if err != nil {
+		return fmt.Errorf("error saving session: %v", err)
+	}
+	return nil
+}
+
+// getSessionSQLite retrieves a web session by ID from SQLite.
+func getSessionSQLite(id string) (*SessionRecord, bool, error) {
+	query := fmt.Sprintf("SELECT id, email, csrf_token, created_at, expires_at FROM %s WHERE id = ?", sqliteSessionTable())
+	var record SessionRecord
+	var created, expires int64
+	err := db.QueryRow(query, id).Scan(&record.ID, &record.Email, &record.CSRFToken, &created, &expires)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("error querying session: %v", err)
+	}
+	record.CreatedAt = time.Unix(created, 0)
+	record.ExpiresAt = time.Unix(expires, 0)
+	return &record, true, nil
+}
+
+// extendSessionSQLite updates the expiry time of a web session in SQLite.
+func extendSessionSQLite(id string, expiresAt time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET expires_at = ? WHERE id = ?", sqliteSessionTable())
+	if _, err := db.Exec(query, expiresAt.Unix(), id); err != nil {
+		return fmt.Errorf("error extending session: %v", err)
+	}
+	return nil
+}
+
+// deleteSessionSQLite removes a web session from SQLite.
+func deleteSessionSQLite(id string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = ?", sqliteSessionTable())
+	if _, err := db.Exec(query, id); err != nil {
+		return fmt.Errorf("error deleting session: %v", err)
+	}
+	return nil
+}
+
+// deleteExpiredSessionsSQLite removes expired web sessions from SQLite.
+func deleteExpiredSessionsSQLite(now time.Time) (int, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE expires_at <= ?", sqliteSessionTable())
+	result, err := db.Exec(query, now.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired sessions: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error checking affected rows: %v", err)
+	}
+	return int(rows), nil
+}
+
+// getAICacheSQLite returns the entry for key stored at or after notBefore
+// and counts the hit. Older entries are deleted.
+func getAICacheSQLite(key string, notBefore, now time.Time) (*AICacheEntry, error) {
+	var e AICacheEntry
+	var created int64
+	query := fmt.Sprintf("SELECT key, kind, provider, model, value, tokens, created_at FROM %s WHERE key = ?", sqliteAICacheTable())
+	err := db.QueryRow(query, key).Scan(&e.Key, &e.Kind, &e.Provider, &e.Model, &e.Value, &e.Tokens, &created)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading AI cache: %v", err)
+	}
+	e.CreatedAt = time.Unix(created, 0)
+
+	if e.CreatedAt.Before(notBefore) {
+		query = fmt.Sprintf("DELETE FROM %s WHERE key = ?", sqliteAICacheTable())
+		if _, err := db.Exec(query, key); err != nil {
+			return nil, fmt.Errorf("error expiring AI cache entry: %v", err)
+		}
+		return nil, nil
+	}
+	query = fmt.Sprintf("UPDATE %s SET hits = hits + 1, used_at = ? WHERE key = ?", sqliteAICacheTable())
+	if _, err := db.Exec(query, now.Unix(), key); err != nil {
+		return nil, fmt.Errorf("error updating AI cache: %v", err)
+	}
+	return &e, nil
+}
+
+// putAICacheSQLite stores e, replacing any entry with the same key, then
+// drops entries created before notBefore and the least recently used ones
+// until the cache fits in maxBytes (no limit when maxBytes <= 0).
+func putAICacheSQLite(e AICacheEntry, notBefore time.Time, maxBytes int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := e.CreatedAt.Unix()
+	query := fmt.Sprintf(`INSERT OR REPLACE INTO %s (key, kind, provider, model, value, tokens, size, hits, created_at, used_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, ?)`, sqliteAICacheTable())
+	if _, err := tx.Exec(query, e.Key, e.Kind, e.Provider, e.Model, e.Value, e.Tokens, len(e.Value), now, now); err != nil {
+		return fmt.Errorf("error writing AI cache: %v", err)
+	}
+	query = fmt.Sprintf("DELETE FROM %s WHERE created_at < ?", sqliteAICacheTable())
+	if _, err := tx.Exec(query, notBefore.Unix()); err != nil {
+		return fmt.Errorf("error expiring AI cache: %v", err)
+	}
+
+	if maxBytes > 0 {
+		var total int64
+		query = fmt.Sprintf("SELECT COALESCE(SUM(size), 0) FROM %s", sqliteAICacheTable())
+		if err := tx.QueryRow(query).Scan(&total); err != nil {
+			return fmt.Errorf("error measuring AI cache: %v", err)
+		}
+		if total > maxBytes {
+			if err := evictAICacheSQLite(tx, total-maxBytes); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing AI cache: %v", err)
+	}
+	return nil
+}
+
+// evictAICacheSQLite deletes the least recently used cache entries until
+// at least excess bytes are freed.
+func evictAICacheSQLite(tx *sql.Tx, excess int64) error {
+	query := fmt.Sprintf("SELECT key, size FROM %s ORDER BY used_at, created_at", sqliteAICacheTable())
+	rows, err := tx.Query(query)
+	if err != nil {
+		return fmt.Errorf("error reading AI cache: %v", err)
+	}
+	var keys []string
+	for rows.Next() && excess > 0 {
+		var key string
+		var size int64
+		if err := rows.Scan(&key, &size); err != nil {
+			rows.Close()
+			return fmt.Errorf("error reading AI cache: %v", err)
+		}
+		keys = append(keys, key)
+		excess -= size
+	}
+	rows.Close()
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE key = ?", sqliteAICacheTable())
+	for _, key := range keys {
+		if _, err := tx.Exec(query, key); err != nil {
+			return fmt.Errorf("error evicting AI cache entry: %v", err)
+		}
+	}
+	return nil
+}
+
+// aiCacheStatsSQLite summarises the AI cache table.
+func aiCacheStatsSQLite() (AICacheStats, error) {
+	var s AICacheStats
+	query := fmt.Sprintf("SELECT COUNT(*), COALESCE(SUM(size), 0), COALESCE(SUM(hits), 0) FROM %s", sqliteAICacheTable())
+	if err := db.QueryRow(query).Scan(&s.Entries, &s.Bytes, &s.Hits); err != nil {
+		return s, fmt.Errorf("error reading AI cache: %v", err)
+	}
+	return s, nil
+}
+
+// clearAICacheSQLite deletes every AI cache entry.
+func clearAICacheSQLite() (int, error) {
+	result, err := db.Exec(fmt.Sprintf("DELETE FROM %s", sqliteAICacheTable()))
+	if err != nil {
+		return 0, fmt.Errorf("error clearing AI cache: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error checking affected rows: %v", err)
+	}
+	return int(rows), nil
+}
+
+// recordAIUsageSQLite stores one AI request.
+func recordAIUsageSQLite(u AIUsage) error {
+	query := fmt.Sprintf(`INSERT INTO %s (provider, model, prompt_tokens, completion_tokens, latency_ms, cost, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, sqliteAIUsageTable())
+	_, err := db.Exec(query, u.Provider, u.Model, u.PromptTokens, u.CompletionTokens, u.Latency.Milliseconds(), u.Cost, u.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("error recording AI usage: %v", err)
+	}
+	return nil
+}
+
+// aiUsageReportSQLite totals the AI requests made since since per provider
+// and model, most expensive first.
+func aiUsageReportSQLite(since time.Time) ([]AIUsageSummary, error) {
+	query := fmt.Sprintf(`SELECT provider, model, COUNT(*), SUM(prompt_tokens), SUM(completion_tokens), AVG(latency_ms), SUM(cost)
+		FROM %s WHERE created_at >= ?
+		GROUP BY provider, model
+		ORDER BY SUM(cost) DESC, COUNT(*) DESC, provider, model`, sqliteAIUsageTable())
+	rows, err := db.Query(query, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("error reading AI usage: %v", err)
+	}
+	defer rows.Close()
+
+	var report []AIUsageSummary
+	for rows.Next() {
+		var s AIUsageSummary
+		var latency float64
+		if err := rows.Scan(&s.Provider, &s.Model, &s.Requests, &s.PromptTokens, &s.CompletionTokens, &latency, &s.Cost); err != nil {
+			return nil, fmt.Errorf("error reading AI usage: %v", err)
+		}
+		s.AvgLatency = time.Duration(latency * float64(time.Millisecond))
+		report = append(report, s)
+	}
+	return report, rows.Err()
+}
+
+// aiCostSinceSQLite returns the estimated cost of the AI requests made
+// since since.
+func aiCostSinceSQLite(since time.Time) (float64, error) {
+	var cost float64
+	query := fmt.Sprintf("SELECT COALESCE(SUM(cost), 0) FROM %s WHERE created_at >= ?", sqliteAIUsageTable())
+	if err := db.QueryRow(query, since.Unix()).Scan(&cost); err != nil {
+		return 0, fmt.Errorf("error reading AI usage: %v", err)
+	}
+	return cost, nil
+}
+
 // cosineSimilarity computes cosine similarity between two vectors.
 func cosineSimilarity(a, b []float64) float64 {
 	if len(a) != len(b) {
@@ -343,6 +1230,9 @@ func cosineSimilarity(a, b []float64) float64 {
 	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
 }
 
+// sqliteLive is the condition that excludes commands in the trash.
+const sqliteLive = "deleted_at IS NULL"
+
 func sqliteTableName() string {
 	return config.TableName()
 }
@@ -350,3 +1240,27 @@ func sqliteTableName() string {
 func sqliteAccessTable() string {
 	return "access"
 }
+
+func sqliteSessionTable() string {
+	return "sessions"
+}
+
+func sqliteUsageTable() string {
+	return "usage"
+}
+
+func sqlitePinTable() string {
+	return "pins"
+}
+
+func sqliteRevisionTable() string {
+	return "revisions"
+}
+
+func sqliteAICacheTable() string {
+	return "ai_cache"
+}
+
+func sqliteAIUsageTable() string {
+	return "ai_usage"
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRevisions_AddUpdateRevert(t *testing.T) {
+	setupTestSQLite(t)
+	alice := Origin{Author: "alice", Source: SourceCLI}
+	bob := Origin{Author: "bob@example.com", Source: SourceWeb}
+
+	if _, err := AddCommand("ls", "list", alice, nil); err != nil {
+		t.Fatalf("AddCommand: %v", err)
+	}
+	if err := UpdateCommand(1, "ls -la", "list all", []string{"shell"}, bob, nil); err != nil {
+		t.Fatalf("UpdateCommand: %v", err)
+	}
+	// A no-op update records nothing.
+	if err := UpdateCommand(1, "ls -la", "list all", nil, bob, nil); err != nil {
+		t.Fatalf("UpdateCommand: %v", err)
+	}
+
+	revs, err := ListRevisions(1)
+	if err != nil {
+		t.Fatalf("ListRevisions: %v", err)
+	}
+	if len(revs) != 2 {
+		t.Fatalf("got %d revisions, want 2: %+v", len(revs), revs)
+	}
+	if revs[0].Rev != 1 || revs[0].Key != "ls" || revs[0].Author != "alice" || revs[0].Source != SourceCLI {
+		t.Errorf("rev 1 = %+v", revs[0])
+	}
+	if revs[1].Rev != 2 || revs[1].Key != "ls -la" || fmt.Sprint(revs[1].Tags) != "[shell]" || revs[1].Source != SourceWeb {
+		t.Errorf("rev 2 = %+v", revs[1])
+	}
+
+	if err := RevertCommand(1, 1, alice, nil); err != nil {
+		t.Fatalf("RevertCommand: %v", err)
+	}
+	r, _ := GetCommandByID(1)
+	if r.Key != "ls" || r.Data != "list" || len(r.Tags) != 0 {
+		t.Errorf("after revert = %+v", r)
+	}
+	if revs, _ := ListRevisions(1); len(revs) != 3 || revs[2].Key != "ls" {
+		t.Errorf("revert was not recorded: %+v", revs)
+	}
+	if err := RevertCommand(1, 9, alice, nil); err == nil {
+		t.Error("reverting to a missing revision should fail")
+	}
+}
+
+func TestRevisions_BaselineAndConflict(t *testing.T) {
+	setupTestSQLite(t)
+	// A command that predates revision history.
+	db.Exec(fmt.Sprintf("INSERT INTO %s (key, data) VALUES ('old', 'legacy')", sqliteTableName()))
+
+	if err := UpdateCommand(1, "new", "edited", nil, Origin{Source: SourceWeb}, nil); err != nil {
+		t.Fatalf("UpdateCommand: %v", err)
+	}
+	revs, _ := ListRevisions(1)
+	if len(revs) != 2 || revs[0].Key != "old" || revs[0].Source != "" {
+		t.Fatalf("baseline missing: %+v", revs)
+	}
+
+	AddCommand("old", "someone re-added it", Origin{}, nil)
+	if err := RevertCommand(1, 1, Origin{}, nil); !errors.Is(err, ErrCommandExists) {
+		t.Errorf("RevertCommand err = %v, want ErrCommandExists", err)
+	}
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupTestSQLite points the package-level connection at a fresh SQLite file
+// with the current schema and restores the previous connection afterwards.
+func setupTestSQLite(t *testing.T) {
+	t.Helper()
+	setDBType(t, "sqlite")
+
+	conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := ensureSchemaSQLite(conn); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+
+	orig := db
+	db = conn
+	t.Cleanup(func() {
+		conn.Close()
+		db = orig
+	})
+}
+
+func TestSessions_SaveGetDelete(t *testing.T) {
+	setupTestSQLite(t)
+
+	now := time.Now().Truncate(time.Second)
+	rec := SessionRecord{ID: "abc", Email: "dev@example.com", CSRFToken: "tok", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := SaveSession(rec); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+
+	got, found, err := GetSession("abc")
+	if err != nil || !found {
+		t.Fatalf("GetSession: found=%v err=%v", found, err)
+	}
+	if got.Email != rec.Email || got.CSRFToken != rec.CSRFToken || !got.ExpiresAt.Equal(rec.ExpiresAt) {
+		t.Errorf("GetSession = %+v, want %+v", got, rec)
+	}
+
+	later := now.Add(3 * time.Hour)
+	if err := ExtendSession("abc", later); err != nil {
+		t.Fatalf("ExtendSession: %v", err)
+	}
+	got, _, _ = GetSession("abc")
+	if !got.ExpiresAt.Equal(later) {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, later)
+	}
+
+	if err := DeleteSession("abc"); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if _, found, _ := GetSession("abc"); found {
+		t.Error("session still present after DeleteSession")
+	}
+}
+
+func TestSessions_UpgradesOldSchema(t *testing.T) {
+	setDBType(t, "sqlite")
+	conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "old.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	// The sessions table as first released, without csrf_token.
+	if _, err := conn.Exec(fmt.Sprintf(`CREATE TABLE %s (
+		id         TEXT    PRIMARY KEY,
+		email      TEXT    NOT NULL,
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL
+	)`, sqliteSessionTable())); err != nil {
+		t.Fatalf("create old sessions table: %v", err)
+	}
+	if err := ensureSchemaSQLite(conn); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	orig := db
+	db = conn
+	t.Cleanup(func() {
+		conn.Close()
+		db = orig
+	})
+
+	now := time.Now().Truncate(time.Second)
+	if err := SaveSession(SessionRecord{ID: "abc", Email: "dev@example.com", CSRFToken: "tok", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+	got, found, err := GetSession("abc")
+	if err != nil || !found || got.CSRFToken != "tok" {
+		t.Errorf("GetSession = %+v, found=%v, err=%v", got, found, err)
+	}
+}
+
+func TestSessions_DeleteExpired(t *testing.T) {
+	setupTestSQLite(t)
+
+	now := time.Now()
+	SaveSession(SessionRecord{ID: "old", Email: "a@x", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)})
+	SaveSession(SessionRecord{ID: "new", Email: "b@x", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	n, err := DeleteExpiredSessions(now)
+	if err != nil {
+		t.Fatalf("DeleteExpiredSessions: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("removed %d sessions, want 1", n)
+	}
+	if _, found, _ := GetSession("new"); !found {
+		t.Error("live session was removed")
+	}
+}
+
+func TestSessions_UnsupportedOnMCP(t *testing.T) {
+	setDBType(t, "mcp")
+	if err := SaveSession(SessionRecord{ID: "x"}); err == nil {
+		t.Error("SaveSession should fail with the MCP backend")
+	}
+}
@@ -0,0 +1,39 @@
+package database
+
+import "testing"
+
+func TestSimilarCommands(t *testing.T) {
+	setupTestSQLite(t)
+	vectors := map[string][]float64{
+		"docker ps -a": {1, 0, 0},
+		"podman ps":    {0.9, 0.1, 0},
+		"git status":   {0, 1, 0},
+		"no vector":    nil,
+	}
+	for _, key := range []string{"docker ps -a", "podman ps", "git status", "no vector"} {
+		embed := func(string) ([]float64, error) { return vectors[key], nil }
+		if vectors[key] == nil {
+			embed = nil
+		}
+		if _, err := AddCommand(key, "d", Origin{}, embed); err != nil {
+			t.Fatalf("AddCommand: %v", err)
+		}
+	}
+	DeleteCommand(2)
+
+	got, err := SimilarCommands([]float64{1, 0.05, 0}, 5, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Record.Key != "docker ps -a" || got[0].Similarity < 0.99 {
+		t.Errorf("SimilarCommands = %+v, want only docker ps -a (podman is trashed)", got)
+	}
+
+	got, _ = SimilarCommands([]float64{1, 1, 0}, 1, 0)
+	if len(got) != 1 {
+		t.Errorf("limit 1 returned %d results", len(got))
+	}
+	if got, _ := SimilarCommands(nil, 5, 0); got != nil {
+		t.Errorf("empty embedding returned %+v", got)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/gcclinux/scmd/internal/config"
 	_ "modernc.org/sqlite"
@@ -15,6 +17,20 @@ func SQLitePath() string {
 	return filepath.Join(config.ConfigDir(), config.DBName()+".db")
 }
 
+// sizeBytesSQLite returns the size of the database file plus its WAL file.
+func sizeBytesSQLite() (int64, error) {
+	path := SQLitePath()
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	if wal, err := os.Stat(path + "-wal"); err == nil {
+		size += wal.Size()
+	}
+	return size, nil
+}
+
 // InitSQLiteDB initializes a SQLite database connection.
 func InitSQLiteDB() error {
 	config.LoadConfig()
@@ -32,10 +48,152 @@ func InitSQLiteDB() error {
 
 	// Enable WAL mode for better concurrent access
 	if _, err = db.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		log.Printf("Warning: could not enable WAL mode: %v", err)
+		logger.Warn("could not enable WAL mode", "err", err)
+	}
+
+	if err = ensureSchemaSQLite(db); err != nil {
+		return err
 	}
 
-	log.Println("Successfully connected to SQLite database:", dbPath)
+	if n, err := PurgeExpiredTrash(); err != nil {
+		logger.Warn("purging trash", "err", err)
+	} else if n > 0 {
+		logger.Info("purged expired commands from trash", "count", n)
+	}
+
+	logger.Debug("connected to SQLite database", "path", dbPath)
+	return nil
+}
+
+// ensureSchemaSQLite creates any missing tables so that databases created by
+// older releases pick up new features without a manual migration step.
+func ensureSchemaSQLite(conn *sql.DB) error {
+	statements := []string{
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			key        TEXT    NOT NULL,
+			data       TEXT    NOT NULL,
+			embedding  TEXT,
+			tags       TEXT    NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			deleted_at DATETIME
+		)`, config.TableName()),
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id         TEXT    PRIMARY KEY,
+			email      TEXT    NOT NULL,
+			csrf_token TEXT    NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL
+		)`, sqliteSessionTable()),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_expires ON %[1]s (expires_at)", sqliteSessionTable()),
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			command_id INTEGER NOT NULL,
+			event      TEXT    NOT NULL,
+			used_at    INTEGER NOT NULL
+		)`, sqliteUsageTable()),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_command ON %[1]s (command_id)", sqliteUsageTable()),
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			command_id INTEGER NOT NULL,
+			rev        INTEGER NOT NULL,
+			key        TEXT    NOT NULL,
+			data       TEXT    NOT NULL,
+			tags       TEXT    NOT NULL DEFAULT '',
+			author     TEXT    NOT NULL DEFAULT '',
+			source     TEXT    NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (command_id, rev)
+		)`, sqliteRevisionTable()),
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			owner      TEXT    NOT NULL,
+			command_id INTEGER NOT NULL,
+			pinned_at  INTEGER NOT NULL,
+			PRIMARY KEY (owner, command_id)
+		)`, sqlitePinTable()),
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			key        TEXT    PRIMARY KEY,
+			kind       TEXT    NOT NULL,
+			provider   TEXT    NOT NULL,
+			model      TEXT    NOT NULL,
+			value      TEXT    NOT NULL,
+			tokens     INTEGER NOT NULL DEFAULT 0,
+			size       INTEGER NOT NULL,
+			hits       INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL,
+			used_at    INTEGER NOT NULL
+		)`, sqliteAICacheTable()),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_used ON %[1]s (used_at)", sqliteAICacheTable()),
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id                INTEGER PRIMARY KEY AUTOINCREMENT,
+			provider          TEXT    NOT NULL,
+			model             TEXT    NOT NULL,
+			prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			latency_ms        INTEGER NOT NULL DEFAULT 0,
+			cost              REAL    NOT NULL DEFAULT 0,
+			created_at        INTEGER NOT NULL
+		)`, sqliteAIUsageTable()),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_created ON %[1]s (created_at)", sqliteAIUsageTable()),
+	}
+	for _, stmt := range statements {
+		if _, err := conn.Exec(stmt); err != nil {
+			return fmt.Errorf("error updating SQLite schema: %v", err)
+		}
+	}
+
+	// Columns added after the first release.
+	if err := ensureColumnSQLite(conn, config.TableName(), "tags", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumnSQLite(conn, config.TableName(), "deleted_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := ensureColumnSQLite(conn, sqliteSessionTable(), "csrf_token", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureColumnSQLite adds column to table when an older database lacks it.
+func ensureColumnSQLite(conn *sql.DB, table, column, definition string) error {
+	rows, err := conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("error reading SQLite schema: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name, typ string
+			notNull   int
+			dflt      sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("error reading SQLite schema: %v", err)
+		}
+		if strings.EqualFold(name, column) {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading SQLite schema: %v", err)
+	}
+	rows.Close()
+
+	if _, err := conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("error adding column %s.%s: %v", table, column, err)
+	}
 	return nil
 }
 
@@ -61,22 +219,13 @@ func SetupSQLiteDatabase() {
 
 	// Enable WAL mode
 	if _, err = conn.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		log.Printf("Warning: could not enable WAL mode: %v", err)
+		logger.Warn("could not enable WAL mode", "err", err)
 	}
 
 	// Create the main commands table (no vector type in SQLite, use TEXT for embeddings)
+	// along with the supporting tables used by the web server.
 	fmt.Printf("\n=== Step 2: Create table '%s' ===\n", dataTbl)
-	createSQL := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id         INTEGER PRIMARY KEY AUTOINCREMENT,
-			key        TEXT    NOT NULL,
-			data       TEXT    NOT NULL,
-			embedding  TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`, dataTbl)
-
-	if _, err = conn.Exec(createSQL); err != nil {
+	if err = ensureSchemaSQLite(conn); err != nil {
 		log.Fatalf("Failed to create table '%s': %v", dataTbl, err)
 	}
 	fmt.Printf("  Table '%s' created.\n", dataTbl)
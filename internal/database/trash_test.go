@@ -0,0 +1,94 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTrash_DeleteHidesAndRestores(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, 3)
+
+	if ok, err := DeleteCommand(2); err != nil || !ok {
+		t.Fatalf("DeleteCommand = %v, %v", ok, err)
+	}
+	if ok, _ := DeleteCommand(2); ok {
+		t.Error("deleting a trashed command should report false")
+	}
+
+	if _, err := GetCommandByID(2); err == nil {
+		t.Error("trashed command still returned by GetCommandByID")
+	}
+	raw, _ := SearchCommands("cmd", "json")
+	var found []CommandRecord
+	json.Unmarshal(raw, &found)
+	if len(found) != 2 {
+		t.Errorf("search found %d commands, want 2", len(found))
+	}
+	p, _ := ListCommands(ListOptions{})
+	if p.Total != 2 {
+		t.Errorf("ListCommands total = %d, want 2", p.Total)
+	}
+	if exists, _ := CheckCommandExists("cmd-2"); exists {
+		t.Error("trashed command counted as existing")
+	}
+
+	trash, err := ListTrash()
+	if err != nil || len(trash) != 1 || trash[0].Id != 2 || trash[0].Deleted == "" {
+		t.Fatalf("ListTrash = %+v, %v", trash, err)
+	}
+
+	if ok, err := RestoreCommand(2); err != nil || !ok {
+		t.Fatalf("RestoreCommand = %v, %v", ok, err)
+	}
+	if ok, _ := RestoreCommand(2); ok {
+		t.Error("restoring a live command should report false")
+	}
+	if r, err := GetCommandByID(2); err != nil || r.Key != "cmd-2" {
+		t.Errorf("restored command = %+v, %v", r, err)
+	}
+}
+
+func TestTrash_RestoreConflict(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, 1)
+
+	DeleteCommand(1)
+	AddCommand("cmd-1", "added again", Origin{}, nil)
+	if _, err := RestoreCommand(1); !errors.Is(err, ErrCommandExists) {
+		t.Errorf("RestoreCommand err = %v, want ErrCommandExists", err)
+	}
+}
+
+func TestTrash_Purge(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, 3)
+	DeleteCommands([]int{1, 2})
+
+	// Backdate one delete past the retention period.
+	old := time.Now().Add(-48 * time.Hour).UTC().Format("2006-01-02 15:04:05")
+	db.Exec(fmt.Sprintf("UPDATE %s SET deleted_at = ? WHERE id = 1", sqliteTableName()), old)
+
+	t.Setenv("TRASH_RETENTION", "1d")
+	if n, err := PurgeExpiredTrash(); err != nil || n != 1 {
+		t.Fatalf("PurgeExpiredTrash = %d, %v; want 1", n, err)
+	}
+	if trash, _ := ListTrash(); len(trash) != 1 || trash[0].Id != 2 {
+		t.Errorf("trash after expiry purge = %+v", trash)
+	}
+
+	t.Setenv("TRASH_RETENTION", "0")
+	if n, _ := PurgeExpiredTrash(); n != 0 {
+		t.Errorf("retention 0 purged %d commands", n)
+	}
+
+	if n, err := PurgeTrash(0); err != nil || n != 1 {
+		t.Errorf("PurgeTrash(0) = %d, %v; want 1", n, err)
+	}
+	if ok, _ := RestoreCommand(2); ok {
+		t.Error("purged command was restored")
+	}
+}
@@ -1,8 +1,151 @@
 package database
 
+import "time"
+
 // CommandRecord represents a stored command in the database.
 type CommandRecord struct {
-	Id   int    `json:"id"`
-	Key  string `json:"key"`
-	Data string `json:"data"`
+	Id      int      `json:"id"`
+	Key     string   `json:"key"`
+	Data    string   `json:"data"`
+	Tags    []string `json:"tags,omitempty"`
+	Created string   `json:"created_at,omitempty"`
+	Updated string   `json:"updated_at,omitempty"`
+	Usage   int      `json:"usage,omitempty"`
+	Deleted string   `json:"deleted_at,omitempty"`
+}
+
+// Sort orders accepted by ListCommands.
+const (
+	SortID      = "id"
+	SortCreated = "created"
+	SortUpdated = "updated"
+	SortUsage   = "usage"
+)
+
+// ListOptions selects one page of commands for ListCommands.
+type ListOptions struct {
+	Limit  int    // page size; values outside 1..MaxPageSize use DefaultPageSize
+	Offset int    // records to skip; ignored when Cursor is set
+	Cursor string // opaque NextCursor from a previous page
+	Sort   string // SortID (default), SortCreated, SortUpdated or SortUsage
+	Desc   bool   // descending order
+	Query  string // case-insensitive text filter on key and data
+	Tag    string // only commands carrying this tag
+}
+
+// Page sizes used by ListCommands.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// CommandPage is one page of commands returned by ListCommands.
+type CommandPage struct {
+	Records    []CommandRecord
+	Total      int    // number of matching commands across all pages
+	NextCursor string // empty on the last page
+}
+
+// SessionRecord represents a persisted web login session.
+type SessionRecord struct {
+	ID        string
+	Email     string
+	CSRFToken string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Sources recorded on revisions.
+const (
+	SourceCLI = "cli"
+	SourceWeb = "web"
+	SourceMCP = "mcp"
+	SourceAI  = "ai"
+)
+
+// Origin identifies who made a change and through which interface. It is
+// stored on the revision written for every add, edit and revert.
+type Origin struct {
+	Author string
+	Source string
+}
+
+// Revision is a snapshot of a command after one change.
+type Revision struct {
+	CommandID int      `json:"command_id"`
+	Rev       int      `json:"rev"`
+	Key       string   `json:"key"`
+	Data      string   `json:"data"`
+	Tags      []string `json:"tags,omitempty"`
+	Author    string   `json:"author,omitempty"`
+	Source    string   `json:"source,omitempty"`
+	Created   string   `json:"created_at"`
+}
+
+// Usage events recorded by RecordUsage.
+const (
+	UsageView = "view"
+	UsageCopy = "copy"
+	UsageExec = "exec"
+)
+
+// UsageStat summarises how often one command has been used.
+type UsageStat struct {
+	CommandID int       `json:"command_id"`
+	Key       string    `json:"key,omitempty"`
+	Views     int       `json:"views"`
+	Copies    int       `json:"copies"`
+	Execs     int       `json:"execs"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// Total returns the number of recorded events of any kind.
+func (s UsageStat) Total() int {
+	return s.Views + s.Copies + s.Execs
+}
+
+// AI cache entry kinds.
+const (
+	CacheChat  = "chat"
+	CacheEmbed = "embed"
+)
+
+// AICacheEntry is a cached AI answer or embedding.
+type AICacheEntry struct {
+	Key       string // hash of the provider, model and request
+	Kind      string // CacheChat or CacheEmbed
+	Provider  string
+	Model     string
+	Value     string // the answer, or the embedding as JSON
+	Tokens    int    // tokens the provider reported for the answer
+	CreatedAt time.Time
+}
+
+// AICacheStats summarises the AI cache.
+type AICacheStats struct {
+	Entries int
+	Bytes   int64
+	Hits    int // lookups answered from the cache since entries were stored
+}
+
+// AIUsage is one AI request recorded for cost accounting.
+type AIUsage struct {
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Latency          time.Duration
+	Cost             float64 // estimated, in US dollars
+	CreatedAt        time.Time
+}
+
+// AIUsageSummary totals the AI requests made with one provider and model.
+type AIUsageSummary struct {
+	Provider         string
+	Model            string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	AvgLatency       time.Duration
+	Cost             float64
 }
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsage_RecordAndTop(t *testing.T) {
+	setupTestSQLite(t)
+	seedCommands(t, 3)
+
+	for _, u := range []struct {
+		id    int
+		event string
+	}{{1, UsageView}, {2, UsageView}, {2, UsageCopy}, {2, UsageExec}, {3, UsageExec}, {3, UsageExec}} {
+		if err := RecordUsage(u.id, u.event); err != nil {
+			t.Fatalf("RecordUsage(%d, %s): %v", u.id, u.event, err)
+		}
+	}
+	if err := RecordUsage(1, "open"); err == nil {
+		t.Error("unknown event accepted")
+	}
+	if err := RecordUsageByKey("cmd-1", UsageExec); err != nil {
+		t.Fatalf("RecordUsageByKey: %v", err)
+	}
+	if err := RecordUsageByKey("not stored", UsageExec); err != nil {
+		t.Fatalf("RecordUsageByKey without match: %v", err)
+	}
+
+	stats, err := UsageStats()
+	if err != nil {
+		t.Fatalf("UsageStats: %v", err)
+	}
+	if s := stats[2]; s.Views != 1 || s.Copies != 1 || s.Execs != 1 || s.Key != "cmd-2" {
+		t.Errorf("stats[2] = %+v", s)
+	}
+	if s := stats[1]; s.Total() != 2 || s.Execs != 1 {
+		t.Errorf("stats[1] = %+v", s)
+	}
+	if only, err := UsageStats(1, 99); err != nil || len(only) != 1 || only[1].Total() != 2 {
+		t.Errorf("UsageStats(1, 99) = %+v, %v", only, err)
+	}
+
+	top, err := TopCommands(2, time.Time{})
+	if err != nil {
+		t.Fatalf("TopCommands: %v", err)
+	}
+	// 1 and 3 tie on count; 1 was used last.
+	if len(top) != 2 || top[0].CommandID != 2 || top[1].CommandID != 1 {
+		t.Errorf("top = %+v", top)
+	}
+	if top, _ := TopCommands(10, time.Now().Add(time.Hour)); len(top) != 0 {
+		t.Errorf("future since returned %d commands", len(top))
+	}
+
+	DeleteCommand(2)
+	if err := RecordUsage(2, UsageView); err != nil {
+		t.Fatalf("RecordUsage on trashed command: %v", err)
+	}
+	stats, _ = UsageStats()
+	if _, ok := stats[2]; ok {
+		t.Error("trashed command still has usage stats")
+	}
+}
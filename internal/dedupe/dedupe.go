@@ -0,0 +1,233 @@
+// Package dedupe detects when a command about to be saved duplicates one
+// already stored, either textually (after normalisation) or semantically
+// (by embedding similarity), and applies the user's choice to merge, skip or
+// save anyway. It is shared by the CLI, the web add page and the MCP
+// add_command tool.
+package dedupe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/logging"
+)
+
+// Choices offered when a duplicate is found.
+const (
+	ActionMerge = "merge" // append the new description to the nearest record
+	ActionSkip  = "skip"  // keep the store unchanged
+	ActionSave  = "save"  // save as a new record anyway
+)
+
+// MaxMatches is the number of nearest records reported for a new command.
+const MaxMatches = 3
+
+// DefaultSimilarity is the cosine similarity at or above which two commands
+// are treated as duplicates when duplicate_similarity is not configured.
+const DefaultSimilarity = 0.95
+
+// ErrExactDuplicate is returned by Apply when asked to save a command whose
+// key is already stored byte for byte.
+var ErrExactDuplicate = errors.New("command already exists")
+
+var logger = logging.For(logging.DB)
+
+// Match is a stored command that duplicates a new one.
+type Match struct {
+	Record     database.CommandRecord `json:"record"`
+	Similarity float64                `json:"similarity,omitempty"` // cosine similarity, 0 for textual matches
+	Exact      bool                   `json:"exact,omitempty"`      // identical key
+	Normalized bool                   `json:"normalized,omitempty"` // identical after Normalize
+}
+
+// Reason describes why m was reported, e.g. "same command" or "96% similar".
+func (m Match) Reason() string {
+	switch {
+	case m.Exact:
+		return "same command"
+	case m.Normalized:
+		return "same command after normalisation"
+	default:
+		return fmt.Sprintf("%.0f%% similar", m.Similarity*100)
+	}
+}
+
+// HasExact reports whether any match has exactly the same key.
+func HasExact(matches []Match) bool {
+	for _, m := range matches {
+		if m.Exact {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidAction reports whether action is one of the supported choices.
+func ValidAction(action string) bool {
+	return action == ActionMerge || action == ActionSkip || action == ActionSave
+}
+
+// Threshold returns the minimum cosine similarity for an embedding match,
+// read from DUPLICATE_SIMILARITY. Zero disables the embedding check.
+func Threshold() float64 {
+	v := strings.TrimSpace(os.Getenv("DUPLICATE_SIMILARITY"))
+	if v == "" {
+		return DefaultSimilarity
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > 1 {
+		return DefaultSimilarity
+	}
+	return f
+}
+
+// Find returns up to MaxMatches stored commands that duplicate command:
+// identical keys first, then keys equal after Normalize, then records whose
+// embedding is at least Threshold similar to that of command and
+// description. embed may be nil to skip the embedding check; an embedding
+// failure is logged and only the textual checks apply.
+func Find(command, description string, embed func(string) ([]float64, error)) ([]Match, error) {
+	records, err := database.ListAllCommands()
+	if err != nil {
+		return nil, err
+	}
+
+	norm := Normalize(command)
+	var matches []Match
+	seen := make(map[int]bool)
+	for _, r := range records {
+		switch {
+		case r.Key == command:
+			matches = append(matches, Match{Record: r, Exact: true, Normalized: true})
+		case Normalize(r.Key) == norm:
+			matches = append(matches, Match{Record: r, Normalized: true})
+		default:
+			continue
+		}
+		seen[r.Id] = true
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Exact && !matches[j].Exact })
+
+	threshold := Threshold()
+	if embed != nil && threshold > 0 && len(matches) < MaxMatches {
+		emb, err := embed(command + " " + description)
+		if err != nil {
+			logger.Warn("duplicate check: embedding generation failed", "err", err)
+		} else {
+			similar, err := database.SimilarCommands(emb, MaxMatches+len(matches), threshold)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range similar {
+				if !seen[s.Record.Id] {
+					matches = append(matches, Match{Record: s.Record, Similarity: s.Similarity})
+					seen[s.Record.Id] = true
+				}
+			}
+		}
+	}
+
+	if len(matches) > MaxMatches {
+		matches = matches[:MaxMatches]
+	}
+	return matches, nil
+}
+
+// Apply carries out action for a new command whose duplicates are matches.
+// It reports whether the store changed. ActionMerge appends description to
+// the first (nearest) match; ActionSave refuses with ErrExactDuplicate when a
+// match has the same key.
+func Apply(action string, matches []Match, command, description string, origin database.Origin, embed func(string) ([]float64, error)) (bool, error) {
+	switch action {
+	case ActionSkip:
+		return false, nil
+	case ActionMerge:
+		if len(matches) == 0 {
+			return false, fmt.Errorf("no existing command to merge into")
+		}
+		return Merge(matches[0].Record.Id, description, origin, embed)
+	case ActionSave:
+		if HasExact(matches) {
+			return false, ErrExactDuplicate
+		}
+		return database.AddCommand(command, description, origin, embed)
+	}
+	return false, fmt.Errorf("unknown duplicate action %q", action)
+}
+
+// Outcome reports what Save did.
+type Outcome struct {
+	Action  string  // action applied, or "" when a decision is needed
+	Changed bool    // whether the store changed
+	Matches []Match // duplicates found, nearest first
+}
+
+// Save stores command unless it duplicates stored commands. With an empty
+// choice the duplicates are returned for the caller to present and nothing
+// is written; otherwise choice is applied as by Apply. Without duplicates
+// the command is saved whatever the choice.
+func Save(choice, command, description string, origin database.Origin, embed func(string) ([]float64, error)) (Outcome, error) {
+	if choice != "" && !ValidAction(choice) {
+		return Outcome{}, fmt.Errorf("unknown duplicate action %q", choice)
+	}
+	embed = CacheEmbedding(embed)
+	matches, err := Find(command, description, embed)
+	if err != nil {
+		return Outcome{}, err
+	}
+	out := Outcome{Action: ActionSave, Matches: matches}
+	if len(matches) > 0 {
+		if choice == "" {
+			out.Action = ""
+			return out, nil
+		}
+		out.Action = choice
+	}
+	out.Changed, err = Apply(out.Action, matches, command, description, origin, embed)
+	return out, err
+}
+
+// Merge appends description to the description of command id, keeping its
+// key and tags. It reports false without writing when the existing
+// description already contains description.
+func Merge(id int, description string, origin database.Origin, embed func(string) ([]float64, error)) (bool, error) {
+	rec, err := database.GetCommandByID(id)
+	if err != nil {
+		return false, err
+	}
+	description = strings.TrimSpace(description)
+	if description == "" || strings.Contains(strings.ToLower(rec.Data), strings.ToLower(description)) {
+		return false, nil
+	}
+	data := strings.TrimRight(rec.Data, " \t\n") + "\n\n" + description
+	if err := database.UpdateCommand(id, rec.Key, data, nil, origin, embed); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CacheEmbedding wraps embed so that repeated calls for the same text reuse
+// the last result. It lets the duplicate check and the save that follows it
+// share one embedding request. A nil embed returns nil.
+func CacheEmbedding(embed func(string) ([]float64, error)) func(string) ([]float64, error) {
+	if embed == nil {
+		return nil
+	}
+	var lastText string
+	var lastEmb []float64
+	var lastErr error
+	cached := false
+	return func(text string) ([]float64, error) {
+		if !cached || text != lastText {
+			lastText = text
+			lastEmb, lastErr = embed(text)
+			cached = true
+		}
+		return lastEmb, lastErr
+	}
+}
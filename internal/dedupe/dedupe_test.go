@@ -0,0 +1,203 @@
+package dedupe
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DB_TYPE", "sqlite")
+	if err := os.MkdirAll(filepath.Join(home, ".scmd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InitSQLiteDB(); err != nil {
+		t.Fatalf("InitSQLiteDB: %v", err)
+	}
+	t.Cleanup(database.CloseDB)
+}
+
+// fakeEmbed maps texts to fixed vectors by their first word so that
+// similarity is predictable: docker texts point one way, git texts another.
+func fakeEmbed(text string) ([]float64, error) {
+	switch strings.Fields(text)[0] {
+	case "docker":
+		return []float64{1, 0, 0}, nil
+	case "podman":
+		return []float64{0.99, 0.1, 0}, nil
+	case "git":
+		return []float64{0, 1, 0}, nil
+	}
+	return []float64{0, 0, 1}, nil
+}
+
+func TestNormalize(t *testing.T) {
+	same := [][]string{
+		{"docker ps -a", "docker ps  -a", "  docker\tps -a ", "docker 'ps' \"-a\"", `docker p\s -a`},
+		{"ls -la", "ls -al", "ls -a -l", "ls -l -a"},
+		{"ls -la /tmp", "ls -l -a /tmp"},
+		{"tar -x -v -f a.tar", "tar -vxf a.tar"},
+		{"find . -name x", "find .  -name 'x'"},
+		{"grep -r 'two words' .", `grep -r "two words" .`, `grep -r two\ words .`},
+		{"ps aux | grep ssh", "ps aux|grep ssh", "ps aux   |   grep ssh"},
+		{"line one\n\n  line   two", "line one\nline two"},
+	}
+	for _, group := range same {
+		want := Normalize(group[0])
+		for _, c := range group[1:] {
+			if got := Normalize(c); got != want {
+				t.Errorf("Normalize(%q) = %q, want %q (as for %q)", c, got, want, group[0])
+			}
+		}
+	}
+
+	different := [][2]string{
+		{"docker ps -a", "docker ps"},
+		{"rm -- -a -b", "rm -- -b -a"},
+		{"echo 'a|b'", "echo a | b"},
+		{"find . -name x", "find . -type x"},
+		{"head -n 5 -v", "head -v -n 5"},
+		{"head -n -5", "head -5 -n"},
+		{"tar -xvf a.tar", "tar -fvx a.tar"},
+		{"find . -name x", "find . -a -e -m -n x"},
+		{"find . -name x -print", "find . -print -name x"},
+	}
+	for _, pair := range different {
+		if a, b := Normalize(pair[0]), Normalize(pair[1]); a == b {
+			t.Errorf("Normalize(%q) == Normalize(%q) == %q, want different", pair[0], pair[1], a)
+		}
+	}
+
+	for command, want := range map[string]string{
+		"head -n -5":     "head -n -5",
+		"find . -name x": "find . -name x",
+	} {
+		if got := Normalize(command); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", command, got, want)
+		}
+	}
+
+	if got := Normalize(`echo "unterminated  x`); got != `echo "unterminated x` {
+		t.Errorf("unterminated quote = %q, want whitespace collapsed", got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	setupTestDB(t)
+	for _, c := range [][2]string{
+		{"docker ps -a", "list all containers"},
+		{"git status", "show working tree status"},
+		{"docker  ps -a", "already a duplicate"},
+	} {
+		if _, err := database.AddCommand(c[0], c[1], database.Origin{}, fakeEmbed); err != nil {
+			t.Fatalf("AddCommand: %v", err)
+		}
+	}
+
+	matches, err := Find("docker ps -a", "list everything", fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || !matches[0].Exact || matches[0].Record.Id != 1 || matches[1].Exact || !matches[1].Normalized || matches[1].Record.Id != 3 {
+		t.Fatalf("exact lookup = %+v, want exact 1 then normalized 3", matches)
+	}
+
+	matches, err = Find("podman ps", "list containers", fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[0].Normalized || matches[0].Similarity < DefaultSimilarity {
+		t.Fatalf("similar lookup = %+v, want the two docker records", matches)
+	}
+
+	if matches, _ := Find("kubectl get pods", "list pods", fakeEmbed); len(matches) != 0 {
+		t.Errorf("unrelated command matched %+v", matches)
+	}
+
+	t.Setenv("DUPLICATE_SIMILARITY", "0")
+	if matches, _ := Find("podman ps", "list containers", fakeEmbed); len(matches) != 0 {
+		t.Errorf("similarity check disabled but matched %+v", matches)
+	}
+}
+
+func TestApply(t *testing.T) {
+	setupTestDB(t)
+	if _, err := database.AddCommand("docker ps -a", "list all containers", database.Origin{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := Find("docker ps -a", "show stopped containers too", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Apply(ActionSave, matches, "docker ps -a", "x", database.Origin{}, nil); !errors.Is(err, ErrExactDuplicate) {
+		t.Errorf("save of exact duplicate err = %v, want ErrExactDuplicate", err)
+	}
+	if changed, err := Apply(ActionSkip, matches, "docker ps -a", "x", database.Origin{}, nil); changed || err != nil {
+		t.Errorf("skip = %v, %v", changed, err)
+	}
+
+	changed, err := Apply(ActionMerge, matches, "docker ps -a", "show stopped containers too", database.Origin{}, nil)
+	if !changed || err != nil {
+		t.Fatalf("merge = %v, %v", changed, err)
+	}
+	rec, _ := database.GetCommandByID(1)
+	if rec.Key != "docker ps -a" || rec.Data != "list all containers\n\nshow stopped containers too" {
+		t.Errorf("merged record = %q / %q", rec.Key, rec.Data)
+	}
+	if changed, _ := Merge(1, "Show stopped containers TOO", database.Origin{}, nil); changed {
+		t.Error("merging a description already present should change nothing")
+	}
+
+	matches, _ = Find("docker ps  -a", "", nil)
+	if changed, err := Apply(ActionSave, matches, "docker ps  -a", "spacing differs", database.Origin{}, nil); !changed || err != nil {
+		t.Errorf("save anyway of normalized duplicate = %v, %v", changed, err)
+	}
+}
+
+func TestSave(t *testing.T) {
+	setupTestDB(t)
+	out, err := Save("", "docker ps -a", "list all containers", database.Origin{}, nil)
+	if err != nil || out.Action != ActionSave || !out.Changed {
+		t.Fatalf("first save = %+v, %v", out, err)
+	}
+
+	out, err = Save("", "docker ps -a", "again", database.Origin{}, nil)
+	if err != nil || out.Action != "" || out.Changed || len(out.Matches) != 1 {
+		t.Fatalf("duplicate without a choice = %+v, %v", out, err)
+	}
+	out, err = Save(ActionMerge, "docker ps -a", "again", database.Origin{}, nil)
+	if err != nil || out.Action != ActionMerge || !out.Changed {
+		t.Errorf("merge = %+v, %v", out, err)
+	}
+	if _, err := Save("replace", "docker ps -a", "again", database.Origin{}, nil); err == nil {
+		t.Error("unknown choice should fail")
+	}
+	if all, _ := database.ListAllCommands(); len(all) != 1 {
+		t.Errorf("stored %d commands, want 1", len(all))
+	}
+}
+
+func TestCacheEmbedding(t *testing.T) {
+	calls := 0
+	embed := CacheEmbedding(func(text string) ([]float64, error) {
+		calls++
+		return []float64{float64(len(text))}, nil
+	})
+	embed("a")
+	embed("a")
+	embed("bb")
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+	if CacheEmbedding(nil) != nil {
+		t.Error("CacheEmbedding(nil) should be nil")
+	}
+}
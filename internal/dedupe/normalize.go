@@ -0,0 +1,221 @@
+package dedupe
+
+import (
+	"sort"
+	"strings"
+)
+
+// Normalize returns a canonical form of command for duplicate comparison,
+// so that "docker ps  -a", "docker ps -a" and "docker 'ps' -a" compare
+// equal. A single-line command is split into words the way a shell would
+// (honouring quotes and backslashes), short flag bundles are expanded
+// ("-la" becomes "-a -l"), each run of consecutive flags that take no value
+// is sorted, and the words are re-joined with single spaces, quoting only
+// where needed.
+// Multi-line text such as a saved AI answer only has its whitespace
+// collapsed. The result is for comparison and is never stored.
+func Normalize(command string) string {
+	command = strings.TrimSpace(command)
+	if strings.Contains(command, "\n") {
+		var lines []string
+		for _, line := range strings.Split(command, "\n") {
+			if line = strings.Join(strings.Fields(line), " "); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	words, ok := splitWords(command)
+	if !ok {
+		return strings.Join(strings.Fields(command), " ")
+	}
+	words = sortFlags(words)
+	out := make([]string, len(words))
+	for i, w := range words {
+		if w.op {
+			out[i] = w.text
+		} else {
+			out[i] = quote(w.text)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// word is one shell word. op marks an unquoted control operator or
+// redirection such as "|", "&&" or ">".
+type word struct {
+	text string
+	op   bool
+}
+
+const operatorChars = "|&;<>"
+
+// splitWords splits a single-line command into words. It reports false for
+// an unterminated quote or trailing backslash.
+func splitWords(s string) ([]word, bool) {
+	var words []word
+	var cur strings.Builder
+	inWord := false
+	flush := func() {
+		if inWord {
+			words = append(words, word{text: cur.String()})
+			cur.Reset()
+			inWord = false
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			flush()
+		case strings.IndexByte(operatorChars, c) >= 0:
+			flush()
+			j := i
+			for j < len(s) && strings.IndexByte(operatorChars, s[j]) >= 0 {
+				j++
+			}
+			words = append(words, word{text: s[i:j], op: true})
+			i = j - 1
+		case c == '\\':
+			if i+1 >= len(s) {
+				return nil, false
+			}
+			i++
+			cur.WriteByte(s[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, false
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\\\"$`", s[i+1]) >= 0 {
+					i++
+				}
+				cur.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, false
+			}
+			inWord = true
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+	return words, true
+}
+
+// isFlag reports whether w looks like an option: "-x", "-xyz" or "--name".
+// A bare "--" ends option parsing and is not a flag.
+func isFlag(w word) bool {
+	return !w.op && len(w.text) > 1 && w.text[0] == '-' && w.text != "--"
+}
+
+// longSingleDash lists common options that are whole words after a single
+// dash, such as find's "-name". They are neither split nor reordered, since
+// find and java read them in order.
+var longSingleDash = map[string]bool{
+	"-name": true, "-iname": true, "-path": true, "-ipath": true, "-regex": true,
+	"-type": true, "-size": true, "-perm": true, "-user": true, "-group": true,
+	"-mtime": true, "-mmin": true, "-atime": true, "-ctime": true, "-newer": true,
+	"-maxdepth": true, "-mindepth": true, "-exec": true, "-execdir": true,
+	"-ok": true, "-print": true, "-print0": true, "-printf": true, "-delete": true,
+	"-prune": true, "-empty": true, "-not": true, "-and": true, "-or": true,
+	"-jar": true, "-cp": true, "-classpath": true, "-version": true,
+}
+
+// sortable reports whether w is a flag that may be reordered: not a
+// negative number or count such as "-5", and not a known single-dash long
+// option.
+func sortable(w word) bool {
+	if !isFlag(w) || longSingleDash[w.text] {
+		return false
+	}
+	return strings.Trim(w.text[1:], "0123456789") != ""
+}
+
+// sortFlags expands short flag bundles and sorts each run of consecutive
+// flags. A flag followed by a plain word may take that word as its value
+// ("head -n 5", "tar -xf a.tar"), so it keeps its place just before it and
+// only the flags ahead of it are sorted. Words after "--" and up to the
+// next operator are left alone.
+func sortFlags(words []word) []word {
+	var out []word
+	var run []string
+	endOfOpts := false
+	flushRun := func() {
+		sort.Strings(run)
+		for _, f := range run {
+			out = append(out, word{text: f})
+		}
+		run = run[:0]
+	}
+	for i, w := range words {
+		switch {
+		case w.op:
+			endOfOpts = false
+		case !endOfOpts && sortable(w):
+			flags := expandBundle(w.text)
+			if i+1 < len(words) && takesValue(words[i+1]) {
+				run = append(run, flags[:len(flags)-1]...)
+				flushRun()
+				out = append(out, word{text: flags[len(flags)-1]})
+				continue
+			}
+			run = append(run, flags...)
+			continue
+		case w.text == "--":
+			endOfOpts = true
+		}
+		flushRun()
+		out = append(out, w)
+	}
+	flushRun()
+	return out
+}
+
+// takesValue reports whether next could be the value of the flag before
+// it: any word that is not an operator, "--" or another sortable flag.
+func takesValue(next word) bool {
+	return !next.op && next.text != "--" && !sortable(next)
+}
+
+// expandBundle splits a short flag bundle such as "-la" into "-l" and "-a".
+// Only bundles of distinct ASCII letters are expanded, so "-n5" and
+// "-Xmx512m" stay whole.
+func expandBundle(flag string) []string {
+	if len(flag) < 3 || flag[1] == '-' {
+		return []string{flag}
+	}
+	seen := make(map[byte]bool)
+	for i := 1; i < len(flag); i++ {
+		c := flag[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') || seen[c] {
+			return []string{flag}
+		}
+		seen[c] = true
+	}
+	flags := make([]string, 0, len(flag)-1)
+	for i := 1; i < len(flag); i++ {
+		flags = append(flags, "-"+flag[i:i+1])
+	}
+	return flags
+}
+
+// quote single-quotes s when it is empty or contains characters the shell
+// would interpret.
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t'\"\\$`*?[]{}()!#~"+operatorChars) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,155 @@
+// Package diff computes line-based differences between two texts, used to
+// compare revisions of stored commands.
+package diff
+
+import (
+	"errors"
+	"strings"
+)
+
+// Op marks how a line changed.
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Line is one line of a diff.
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// MarshalText encodes Op as its marker character in JSON.
+func (o Op) MarshalText() ([]byte, error) {
+	return []byte{byte(o)}, nil
+}
+
+// MaxCells caps the work of one diff: the number of changed lines of a
+// times the number of changed lines of b, after the common prefix and
+// suffix are removed. Each cell costs four bytes of memory.
+const MaxCells = 1 << 22
+
+// ErrTooLarge is returned by Lines when the texts differ in more lines than
+// MaxCells allows.
+var ErrTooLarge = errors.New("texts too large to diff")
+
+// Lines returns the line diff turning a into b, based on the longest common
+// subsequence of lines. Deleted lines are listed before inserted ones
+// within each changed block.
+func Lines(a, b string) ([]Line, error) {
+	x, y := splitLines(a), splitLines(b)
+
+	// Unchanged lines at either end need no LCS.
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+	mx, my := x[pre:len(x)-suf], y[pre:len(y)-suf]
+	if len(mx) > 0 && len(my) > MaxCells/len(mx) {
+		return nil, ErrTooLarge
+	}
+
+	out := make([]Line, 0, len(x)+len(y)-pre-suf)
+	for _, l := range x[:pre] {
+		out = append(out, Line{Equal, l})
+	}
+	out = lcsLines(out, mx, my)
+	for _, l := range x[len(x)-suf:] {
+		out = append(out, Line{Equal, l})
+	}
+	return out, nil
+}
+
+// lcsLines appends the diff turning x into y to out.
+func lcsLines(out []Line, x, y []string) []Line {
+	// lcs[i*w+j] is the LCS length of x[i:] and y[j:].
+	w := len(y) + 1
+	lcs := make([]int32, (len(x)+1)*w)
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+			} else {
+				lcs[i*w+j] = max(lcs[(i+1)*w+j], lcs[i*w+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			out = append(out, Line{Equal, x[i]})
+			i++
+			j++
+		case lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+			out = append(out, Line{Delete, x[i]})
+			i++
+		default:
+			out = append(out, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		out = append(out, Line{Delete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		out = append(out, Line{Insert, y[j]})
+	}
+	return out
+}
+
+// Changed reports whether a diff contains any insertions or deletions.
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Unified renders a diff in unified style, keeping context unchanged lines
+// around each change and eliding the rest with "@@".
+func Unified(lines []Line, context int) string {
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.Op == Equal {
+			continue
+		}
+		for k := max(0, i-context); k <= min(len(lines)-1, i+context); k++ {
+			keep[k] = true
+		}
+	}
+
+	var sb strings.Builder
+	skipped := false
+	for i, l := range lines {
+		if !keep[i] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			sb.WriteString("@@\n")
+			skipped = false
+		}
+		sb.WriteByte(byte(l.Op))
+		sb.WriteString(l.Text)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,67 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func render(lines []Line) string {
+	s := ""
+	for _, l := range lines {
+		s += string(l.Op) + l.Text + "\n"
+	}
+	return s
+}
+
+func mustLines(t *testing.T, a, b string) []Line {
+	t.Helper()
+	lines, err := Lines(a, b)
+	if err != nil {
+		t.Fatalf("Lines: %v", err)
+	}
+	return lines
+}
+
+func TestLines(t *testing.T) {
+	got := render(mustLines(t, "a\nb\nc\nd", "a\nc\nx\nd\ne"))
+	want := " a\n-b\n c\n+x\n d\n+e\n"
+	if got != want {
+		t.Errorf("Lines =\n%s\nwant\n%s", got, want)
+	}
+	if Changed(mustLines(t, "same\n", "same")) {
+		t.Error("a trailing newline alone should not count as a change")
+	}
+	if got := render(mustLines(t, "", "new")); got != "+new\n" {
+		t.Errorf("diff from empty = %q", got)
+	}
+}
+
+func TestLines_TooLarge(t *testing.T) {
+	numbered := func(prefix string, n int) string {
+		var sb strings.Builder
+		for i := range n {
+			fmt.Fprintf(&sb, "%s%d\n", prefix, i)
+		}
+		return sb.String()
+	}
+	a, b := numbered("a", 3000), numbered("b", 3000)
+	if _, err := Lines(a, b); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Lines of 3000 changed lines each: err = %v, want ErrTooLarge", err)
+	}
+
+	// Unchanged lines around a small edit do not count towards the cap.
+	lines := mustLines(t, a+"x\n"+a, a+"y\n"+a)
+	if got := render(lines[2999:]); !strings.HasPrefix(got, " a2999\n-x\n+y\n a0\n") {
+		t.Errorf("edit inside a large text = %q", got[:min(len(got), 40)])
+	}
+}
+
+func TestUnified(t *testing.T) {
+	lines := mustLines(t, "1\n2\n3\n4\n5\n6\n7", "1\n2\n3\nfour\n5\n6\n7")
+	want := " 3\n-4\n+four\n 5\n"
+	if got := Unified(lines, 1); got != "@@\n"+want {
+		t.Errorf("Unified =\n%s", got)
+	}
+}
@@ -0,0 +1,228 @@
+// Package logging provides the application-wide structured logger built on
+// log/slog. Library packages obtain a logger tagged with their subsystem via
+// For and never print diagnostics directly; the level, output format and
+// destination are chosen once at startup by Setup.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Subsystem names used with For.
+const (
+	Web = "web"
+	CLI = "cli"
+	AI  = "ai"
+	DB  = "db"
+	MCP = "mcp"
+)
+
+// Options configures the root logger.
+type Options struct {
+	Level  string    // debug, info, warn or error (default info)
+	Format string    // text or json (default text)
+	Output io.Writer // defaults to os.Stderr
+}
+
+var (
+	level   = new(slog.LevelVar)
+	current atomic.Pointer[slog.Handler]
+)
+
+func init() {
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	current.Store(&h)
+}
+
+// ParseLevel converts a level name to a slog.Level. Unknown names return
+// an error and slog.LevelInfo.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// Setup installs the root handler. It also routes the standard library log
+// package through the same handler so remaining log.Printf calls share the
+// format and level.
+func Setup(opts Options) error {
+	lvl, err := ParseLevel(opts.Level)
+	level.Set(lvl)
+
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	h := newHandler(out, opts.Format, level)
+	current.Store(&h)
+
+	log.SetFlags(0)
+	log.SetOutput(&stdlogWriter{})
+	return err
+}
+
+// SetupFromEnv configures logging from LOG_LEVEL, LOG_FORMAT and LOG_FILE
+// (set from log_level, log_format and log_file in config.json or the
+// --log-level flag).
+func SetupFromEnv() error {
+	opts := Options{
+		Level:  os.Getenv("LOG_LEVEL"),
+		Format: os.Getenv("LOG_FORMAT"),
+	}
+	if path := os.Getenv("LOG_FILE"); path != "" {
+		w, err := File(path)
+		if err != nil {
+			Setup(opts)
+			return err
+		}
+		opts.Output = w
+	}
+	return Setup(opts)
+}
+
+// SetLevel changes the minimum level at runtime.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// Level returns the current minimum level.
+func Level() slog.Level {
+	return level.Level()
+}
+
+func newHandler(w io.Writer, format string, leveler slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: leveler}
+	if strings.EqualFold(format, "json") {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// For returns a logger tagged with subsystem=name. The logger follows later
+// calls to Setup, so it is safe to create it in a package-level variable.
+func For(name string) *slog.Logger {
+	return slog.New(&dynamicHandler{attrs: []slog.Attr{slog.String("subsystem", name)}})
+}
+
+// dynamicHandler forwards records to the current root handler, applying the
+// attributes and groups collected through With and WithGroup.
+type dynamicHandler struct {
+	attrs  []slog.Attr
+	groups []string
+}
+
+func (d *dynamicHandler) resolve() slog.Handler {
+	h := *current.Load()
+	if len(d.attrs) > 0 {
+		h = h.WithAttrs(d.attrs)
+	}
+	for _, g := range d.groups {
+		h = h.WithGroup(g)
+	}
+	return h
+}
+
+func (d *dynamicHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return (*current.Load()).Enabled(ctx, l)
+}
+
+func (d *dynamicHandler) Handle(ctx context.Context, r slog.Record) error {
+	return d.resolve().Handle(ctx, r)
+}
+
+func (d *dynamicHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(d.groups) > 0 {
+		// Attributes added after a group belong inside it; resolve now.
+		return d.resolve().WithAttrs(attrs)
+	}
+	return &dynamicHandler{attrs: append(append([]slog.Attr(nil), d.attrs...), attrs...)}
+}
+
+func (d *dynamicHandler) WithGroup(name string) slog.Handler {
+	return &dynamicHandler{attrs: d.attrs, groups: append(append([]string(nil), d.groups...), name)}
+}
+
+// stdlogWriter adapts the standard log package to the root handler.
+type stdlogWriter struct{}
+
+func (stdlogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	logger := slog.New(*current.Load())
+	logger.Info(msg)
+	return len(p), nil
+}
+
+// ── Per-file loggers ──
+
+var (
+	filesMu sync.Mutex
+	files   = map[string]*slog.Logger{}
+	writers = map[string]*RotatingFile{}
+)
+
+// File returns the rotating writer for path, opening it on first use.
+// Rotation limits come from WEB_LOG_MAX_SIZE, WEB_LOG_MAX_AGE and
+// WEB_LOG_MAX_BACKUPS.
+func File(path string) (*RotatingFile, error) {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	if w, ok := writers[path]; ok {
+		return w, nil
+	}
+	w, err := OpenRotatingFile(path, rotationFromEnv())
+	if err != nil {
+		return nil, err
+	}
+	writers[path] = w
+	return w, nil
+}
+
+// FileLogger returns a logger that writes to the rotating file at path in
+// the configured format, or nil if the file cannot be opened.
+func FileLogger(path string) *slog.Logger {
+	filesMu.Lock()
+	if l, ok := files[path]; ok {
+		filesMu.Unlock()
+		return l
+	}
+	filesMu.Unlock()
+
+	w, err := File(path)
+	if err != nil {
+		For(Web).Warn("cannot open log file", "path", path, "err", err)
+		return nil
+	}
+
+	l := slog.New(newHandler(w, os.Getenv("LOG_FORMAT"), slog.LevelDebug))
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	files[path] = l
+	return l
+}
+
+// CloseFiles closes every rotating file opened through File.
+func CloseFiles() {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	for path, w := range writers {
+		w.Close()
+		delete(writers, path)
+		delete(files, path)
+	}
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"":        slog.LevelInfo,
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+	}
+	for in, want := range tests {
+		got, err := ParseLevel(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel(loud) should fail")
+	}
+}
+
+func TestFor_FollowsSetup(t *testing.T) {
+	logger := For(DB) // created before Setup, like a package-level variable
+
+	var buf bytes.Buffer
+	if err := Setup(Options{Level: "warn", Format: "json", Output: &buf}); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	defer Setup(Options{})
+
+	logger.Info("hidden")
+	logger.Warn("disk almost full", "free", 12)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1:\n%s", len(lines), buf.String())
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("not JSON: %v", err)
+	}
+	if rec["subsystem"] != DB || rec["msg"] != "disk almost full" || rec["level"] != "WARN" {
+		t.Errorf("unexpected record: %v", rec)
+	}
+}
+
+func TestSetup_RoutesStdLog(t *testing.T) {
+	var buf bytes.Buffer
+	Setup(Options{Output: &buf})
+	defer Setup(Options{})
+
+	log.Printf("legacy %d", 1)
+	if !strings.Contains(buf.String(), `msg="legacy 1"`) {
+		t.Errorf("standard log output not routed: %q", buf.String())
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{"512": 512, "64KB": 64 << 10, "10mb": 10 << 20, "1G": 1 << 30}
+	for in, want := range tests {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := ParseSize("lots"); err == nil {
+		t.Error("ParseSize(lots) should fail")
+	}
+}
+
+func TestRotatingFile_Size(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.log")
+	w, err := OpenRotatingFile(path, Rotation{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer w.Close()
+
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { clock = clock.Add(time.Second); return clock }
+
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("0123456789")); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Errorf("got %d backups, want 2 (MaxBackups)", len(backups))
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "0123456789" {
+		t.Errorf("current file = %q", data)
+	}
+}
+
+func TestRotatingFile_Age(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.log")
+	w, err := OpenRotatingFile(path, Rotation{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer w.Close()
+
+	clock := time.Now()
+	w.now = func() time.Time { return clock }
+	w.Write([]byte("first\n"))
+
+	clock = clock.Add(2 * time.Hour)
+	w.Write([]byte("second\n"))
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 {
+		t.Fatalf("got %d backups, want 1", len(backups))
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "second\n" {
+		t.Errorf("current file = %q", data)
+	}
+}
@@ -0,0 +1,187 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/config"
+)
+
+// Rotation controls when a RotatingFile starts a new file.
+type Rotation struct {
+	MaxSize    int64         // rotate once the file exceeds this many bytes (0 = never)
+	MaxAge     time.Duration // rotate once the file is older than this (0 = never)
+	MaxBackups int           // rotated files to keep (0 = keep all)
+}
+
+// Default rotation limits for the web access log.
+const (
+	defaultMaxSize    = 10 << 20
+	defaultMaxAge     = 24 * time.Hour
+	defaultMaxBackups = 7
+)
+
+// rotationFromEnv reads web_log_max_size, web_log_max_age and
+// web_log_max_backups.
+func rotationFromEnv() Rotation {
+	r := Rotation{
+		MaxSize:    defaultMaxSize,
+		MaxAge:     config.ParseDuration(os.Getenv("WEB_LOG_MAX_AGE"), defaultMaxAge),
+		MaxBackups: defaultMaxBackups,
+	}
+	if v := os.Getenv("WEB_LOG_MAX_SIZE"); v != "" {
+		if size, err := ParseSize(v); err == nil {
+			r.MaxSize = size
+		}
+	}
+	if v := os.Getenv("WEB_LOG_MAX_BACKUPS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			r.MaxBackups = n
+		}
+	}
+	return r
+}
+
+// ParseSize parses a byte size such as "512", "64KB", "10MB" or "1GB".
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			mult = u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// RotatingFile is an io.Writer that appends to a file and rotates it by
+// size and age. Rotated files are renamed to <name>.<timestamp> next to the
+// original. It is safe for concurrent use.
+type RotatingFile struct {
+	path string
+	rot  Rotation
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time
+	now     func() time.Time
+}
+
+// OpenRotatingFile opens (or creates) path for appending.
+func OpenRotatingFile(path string, rot Rotation) (*RotatingFile, error) {
+	w := &RotatingFile{path: path, rot: rot, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotatingFile) open() error {
+	if dir := filepath.Dir(w.path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	w.started = info.ModTime()
+	if w.size == 0 {
+		w.started = w.now()
+	}
+	return nil
+}
+
+// Write appends p, rotating first when the size or age limit is reached.
+func (w *RotatingFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingFile) shouldRotate(incoming int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.rot.MaxSize > 0 && w.size+incoming > w.rot.MaxSize {
+		return true
+	}
+	return w.rot.MaxAge > 0 && w.now().Sub(w.started) >= w.rot.MaxAge
+}
+
+func (w *RotatingFile) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	backup := w.path + "." + w.now().Format("20060102-150405.000")
+	if err := os.Rename(w.path, backup); err != nil {
+		return err
+	}
+	w.prune()
+	return w.open()
+}
+
+// prune removes the oldest rotated files beyond MaxBackups.
+func (w *RotatingFile) prune() {
+	if w.rot.MaxBackups <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(w.path + ".*")
+	if err != nil || len(matches) <= w.rot.MaxBackups {
+		return
+	}
+	// The timestamp suffix sorts chronologically.
+	sort.Strings(matches)
+	for _, old := range matches[:len(matches)-w.rot.MaxBackups] {
+		os.Remove(old)
+	}
+}
+
+// Close closes the underlying file.
+func (w *RotatingFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -61,7 +61,7 @@ func ImportMarkdown(filePath string, embeddingFn func(string) ([]float64, error)
 		return "", fmt.Errorf("document already exists with title: %s", title)
 	}
 
-	success, err := database.AddCommand(title, content, embeddingFn)
+	success, err := database.AddCommand(title, content, database.LocalOrigin(database.SourceCLI), embeddingFn)
 	if err != nil {
 		return "", fmt.Errorf("error storing document: %v", err)
 	}
@@ -3,7 +3,9 @@ package markdown
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"strings"
+	"unicode"
 )
 
 // ANSI escape codes for terminal styling.
@@ -76,63 +78,134 @@ func ContainsMarkdownLink(content string) bool {
 // Render formats markdown content for terminal display.
 func Render(content string) string {
 	var out strings.Builder
-	scanner := bufio.NewScanner(strings.NewReader(content))
+	r := NewRenderer(&out)
+	r.WriteString(content)
+	r.Close()
+	return out.String()
+}
 
-	inCodeBlock := false
-	var codeBlockLang string
+// Renderer formats markdown for terminal display as it arrives, for answers
+// that are streamed a few tokens at a time. Ordinary lines are written as
+// soon as they can no longer turn into a code fence or header; fences and
+// headers are written once their line is complete. The output is the same
+// as Render of the concatenated input.
+type Renderer struct {
+	w             io.Writer
+	pending       string // start of the current line, not yet written
+	streaming     bool   // the current line is plain and partly written
+	inCodeBlock   bool
+	codeBlockLang string
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		trimmed := strings.TrimSpace(line)
+// NewRenderer returns a Renderer writing to w.
+func NewRenderer(w io.Writer) *Renderer {
+	return &Renderer{w: w}
+}
 
-		if strings.HasPrefix(trimmed, "```") {
-			if !inCodeBlock {
-				codeBlockLang = strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
-				if codeBlockLang != "" {
-					out.WriteString(fmt.Sprintf("%s┌─ %s ─%s\n", ansiGreen, codeBlockLang, ansiReset))
-				} else {
-					out.WriteString(fmt.Sprintf("%s┌──────%s\n", ansiGreen, ansiReset))
-				}
-				inCodeBlock = true
-			} else {
-				if codeBlockLang != "" {
-					out.WriteString(fmt.Sprintf("%s└─ %s ─%s\n", ansiGreen, codeBlockLang, ansiReset))
-				} else {
-					out.WriteString(fmt.Sprintf("%s└──────%s\n", ansiGreen, ansiReset))
-				}
-				inCodeBlock = false
-				codeBlockLang = ""
-			}
-			continue
+// WriteString renders s, holding back an incomplete line until it is known
+// how to format it.
+func (r *Renderer) WriteString(s string) {
+	r.pending += s
+	for {
+		i := strings.IndexByte(r.pending, '\n')
+		if i < 0 {
+			break
 		}
+		line := strings.TrimSuffix(r.pending[:i], "\r")
+		r.pending = r.pending[i+1:]
+		r.endLine(line)
+	}
 
-		if inCodeBlock {
-			out.WriteString(fmt.Sprintf("%s│%s %s\n", ansiGreen, ansiReset, line))
-			continue
+	if r.pending == "" {
+		return
+	}
+	if !r.streaming {
+		if r.undecided(r.pending) {
+			return
+		}
+		r.streaming = true
+		if r.inCodeBlock {
+			fmt.Fprintf(r.w, "%s│%s ", ansiGreen, ansiReset)
 		}
+	}
+	// Hold back a trailing \r in case it is part of a \r\n line ending.
+	out := strings.TrimSuffix(r.pending, "\r")
+	io.WriteString(r.w, out)
+	r.pending = r.pending[len(out):]
+}
 
-		if strings.HasPrefix(trimmed, "### ") {
-			headerText := strings.TrimPrefix(trimmed, "### ")
-			out.WriteString(fmt.Sprintf("%s%s%s%s\n", ansiBold, ansiCyan, headerText, ansiReset))
-			continue
+// Close renders any incomplete last line and closes an unterminated code
+// block.
+func (r *Renderer) Close() {
+	if r.pending != "" || r.streaming {
+		r.endLine(strings.TrimSuffix(r.pending, "\r"))
+		r.pending = ""
+	}
+	if r.inCodeBlock {
+		fmt.Fprintf(r.w, "%s└──────%s\n", ansiGreen, ansiReset)
+		r.inCodeBlock = false
+	}
+}
+
+// undecided reports whether the start of a line could still become a code
+// fence or, outside code blocks, a header.
+func (r *Renderer) undecided(start string) bool {
+	trimmed := strings.TrimLeftFunc(start, unicode.IsSpace)
+	markers := []string{"```"}
+	if !r.inCodeBlock {
+		markers = append(markers, "# ", "## ", "### ")
+	}
+	for _, m := range markers {
+		if strings.HasPrefix(m, trimmed) || strings.HasPrefix(trimmed, m) {
+			return true
 		}
-		if strings.HasPrefix(trimmed, "## ") {
-			headerText := strings.TrimPrefix(trimmed, "## ")
-			out.WriteString(fmt.Sprintf("%s%s%s%s\n", ansiBold, ansiCyan, headerText, ansiReset))
-			continue
+	}
+	return false
+}
+
+// endLine writes the rest of the current line; line is all of it unless
+// its start has already been streamed.
+func (r *Renderer) endLine(line string) {
+	if r.streaming {
+		io.WriteString(r.w, line+"\n")
+		r.streaming = false
+		return
+	}
+	r.w.Write([]byte(r.renderLine(line)))
+}
+
+// renderLine formats one complete line.
+func (r *Renderer) renderLine(line string) string {
+	trimmed := strings.TrimSpace(line)
+
+	if strings.HasPrefix(trimmed, "```") {
+		if !r.inCodeBlock {
+			r.codeBlockLang = strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			r.inCodeBlock = true
+			if r.codeBlockLang != "" {
+				return fmt.Sprintf("%s┌─ %s ─%s\n", ansiGreen, r.codeBlockLang, ansiReset)
+			}
+			return fmt.Sprintf("%s┌──────%s\n", ansiGreen, ansiReset)
 		}
-		if strings.HasPrefix(trimmed, "# ") {
-			headerText := strings.TrimPrefix(trimmed, "# ")
-			out.WriteString(fmt.Sprintf("%s%s%s%s\n", ansiBold, ansiCyan, headerText, ansiReset))
-			continue
+		lang := r.codeBlockLang
+		r.inCodeBlock = false
+		r.codeBlockLang = ""
+		if lang != "" {
+			return fmt.Sprintf("%s└─ %s ─%s\n", ansiGreen, lang, ansiReset)
 		}
+		return fmt.Sprintf("%s└──────%s\n", ansiGreen, ansiReset)
+	}
 
-		out.WriteString(line + "\n")
+	if r.inCodeBlock {
+		return fmt.Sprintf("%s│%s %s\n", ansiGreen, ansiReset, line)
 	}
 
-	if inCodeBlock {
-		out.WriteString(fmt.Sprintf("%s└──────%s\n", ansiGreen, ansiReset))
+	for _, prefix := range []string{"### ", "## ", "# "} {
+		if strings.HasPrefix(trimmed, prefix) {
+			headerText := strings.TrimPrefix(trimmed, prefix)
+			return fmt.Sprintf("%s%s%s%s\n", ansiBold, ansiCyan, headerText, ansiReset)
+		}
 	}
 
-	return out.String()
+	return line + "\n"
 }
@@ -0,0 +1,59 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+const sample = "Here is how:\r\n\n## Listing\n```bash\nls -la\n# not a header\n```\n  # Indented header\n#hashtag\n```\nunterminated"
+
+func TestRender(t *testing.T) {
+	got := Render(sample)
+	for _, want := range []string{
+		"Here is how:\n\n",
+		ansiBold + ansiCyan + "Listing" + ansiReset + "\n",
+		ansiGreen + "┌─ bash ─" + ansiReset + "\n",
+		ansiGreen + "│" + ansiReset + " # not a header\n",
+		ansiGreen + "└─ bash ─" + ansiReset + "\n",
+		ansiBold + ansiCyan + "Indented header" + ansiReset + "\n",
+		"#hashtag\n",
+		ansiGreen + "│" + ansiReset + " unterminated\n" + ansiGreen + "└──────" + ansiReset + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRendererMatchesRender(t *testing.T) {
+	want := Render(sample)
+	for _, size := range []int{1, 2, 3, 5, 8, len(sample)} {
+		var out strings.Builder
+		r := NewRenderer(&out)
+		for i := 0; i < len(sample); i += size {
+			r.WriteString(sample[i:min(i+size, len(sample))])
+		}
+		r.Close()
+		if out.String() != want {
+			t.Errorf("chunks of %d:\n got %q\nwant %q", size, out.String(), want)
+		}
+	}
+}
+
+func TestRendererStreamsPlainText(t *testing.T) {
+	var out strings.Builder
+	r := NewRenderer(&out)
+
+	r.WriteString("Use the ")
+	if out.String() != "Use the " {
+		t.Errorf("plain text should be written immediately, got %q", out.String())
+	}
+	r.WriteString("command.\n#")
+	if out.String() != "Use the command.\n" {
+		t.Errorf("a possible header should be held back, got %q", out.String())
+	}
+	r.WriteString("# Title\n")
+	if !strings.HasSuffix(out.String(), ansiBold+ansiCyan+"Title"+ansiReset+"\n") {
+		t.Errorf("header not rendered: %q", out.String())
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/gcclinux/scmd/internal/ai"
 	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/dedupe"
 )
 
 // StartServer starts the MCP server over stdio.
@@ -34,7 +36,7 @@ func StartServer() error {
 	// Add Tool
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "add_command",
-		Description: "Add a new command to the SCMD database.",
+		Description: "Add a new command to the SCMD database. If similar commands already exist nothing is saved and they are listed; call again with on_duplicate to merge, skip or save anyway.",
 	}, handleAdd)
 
 	// Stats Tool
@@ -78,12 +80,38 @@ func handleSearch(ctx context.Context, req *mcp.CallToolRequest, input SearchInp
 }
 
 func handleAdd(ctx context.Context, req *mcp.CallToolRequest, input AddCommandInput) (*mcp.CallToolResult, any, error) {
-	success, err := database.AddCommand(input.Command, input.Description, ai.GetBestEmbedding)
+	choice := input.OnDuplicate
+	if choice == "warn" {
+		choice = ""
+	}
+	out, err := dedupe.Save(choice, input.Command, input.Description, database.LocalOrigin(database.SourceMCP), ai.GetBestEmbedding)
 	if err != nil {
 		return nil, nil, fmt.Errorf("add error: %v", err)
 	}
 
-	if !success {
+	switch out.Action {
+	case "":
+		var b strings.Builder
+		b.WriteString("Not saved: similar commands already exist.\n")
+		for _, m := range out.Matches {
+			fmt.Fprintf(&b, "- ID %d (%s): %s — %s\n", m.Record.Id, m.Reason(), m.Record.Key, m.Record.Data)
+		}
+		if dedupe.HasExact(out.Matches) {
+			b.WriteString("Call add_command again with on_duplicate set to merge or skip.")
+		} else {
+			b.WriteString("Call add_command again with on_duplicate set to merge, skip or save.")
+		}
+		return nil, b.String(), nil
+	case dedupe.ActionMerge:
+		if !out.Changed {
+			return nil, fmt.Sprintf("Command ID %d already has this description; nothing changed", out.Matches[0].Record.Id), nil
+		}
+		return nil, fmt.Sprintf("✓ Merged description into command ID %d", out.Matches[0].Record.Id), nil
+	case dedupe.ActionSkip:
+		return nil, "Skipped; nothing saved", nil
+	}
+
+	if !out.Changed {
 		return nil, "Failed to add command", nil
 	}
 
@@ -10,6 +10,7 @@ type SearchInput struct {
 type AddCommandInput struct {
 	Command     string `json:"command" jsonschema:"The actual CLI command string"`
 	Description string `json:"description" jsonschema:"What the command does"`
+	OnDuplicate string `json:"on_duplicate,omitempty" jsonschema:"What to do if similar commands exist: warn (default, list them and save nothing), merge (append the description to the nearest one), skip, or save (store anyway)"`
 }
 
 // GetCommandInput defines the input for the get_command tool.
@@ -188,6 +188,14 @@ func (c *Client) GetData(uuid string) (*MCPRecord, error) {
 // ListData invokes the list_data tool with optional pagination.
 // The server returns {"records": [...], "total_count": N}.
 func (c *Client) ListData(namespace string, limit, offset int) ([]MCPRecord, error) {
+	records, _, err := c.ListDataPage(namespace, limit, offset)
+	return records, err
+}
+
+// ListDataPage is like ListData but also returns the server's total_count.
+// When the server replies with a bare array the total is the number of
+// records returned.
+func (c *Client) ListDataPage(namespace string, limit, offset int) ([]MCPRecord, int, error) {
 	args := map[string]any{
 		"namespace": namespace,
 		"limit":     limit,
@@ -196,11 +204,11 @@ func (c *Client) ListData(namespace string, limit, offset int) ([]MCPRecord, err
 
 	text, err := c.callTool(context.Background(), "list_data", args)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if text == "" {
-		return []MCPRecord{}, nil
+		return []MCPRecord{}, 0, nil
 	}
 
 	// The server wraps the array in {"records": [...], "total_count": N}.
@@ -209,12 +217,12 @@ func (c *Client) ListData(namespace string, limit, offset int) ([]MCPRecord, err
 		// Fallback: try bare array for forward compatibility.
 		var records []MCPRecord
 		if err2 := json.Unmarshal([]byte(text), &records); err2 != nil {
-			return nil, fmt.Errorf("list_data: failed to parse response: %v", err)
+			return nil, 0, fmt.Errorf("list_data: failed to parse response: %v", err)
 		}
-		return records, nil
+		return records, offset + len(records), nil
 	}
 
-	return resp.Records, nil
+	return resp.Records, int(resp.TotalCount), nil
 }
 
 // UpdateData invokes the update_data tool to update a record's embedding.
@@ -210,6 +210,20 @@ func TestListData_PassesLimitAndOffsetCorrectly(t *testing.T) {
 	}
 }
 
+func TestListDataPage_ReturnsTotalCount(t *testing.T) {
+	c := newTestClient(func(ctx context.Context, toolName string, args map[string]any) (string, error) {
+		return `{"records":[{"id":"a","key":"ls","content":"list"}],"total_count":42}`, nil
+	})
+
+	records, total, err := c.ListDataPage("data", 1, 10)
+	if err != nil {
+		t.Fatalf("ListDataPage: %v", err)
+	}
+	if len(records) != 1 || total != 42 {
+		t.Errorf("got %d records, total %d; want 1, 42", len(records), total)
+	}
+}
+
 func TestListData_ZeroLimitAndOffset(t *testing.T) {
 	var captured toolCall
 	c := newTestClient(func(ctx context.Context, toolName string, args map[string]any) (string, error) {
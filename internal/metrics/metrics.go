@@ -0,0 +1,337 @@
+// Package metrics implements the small subset of Prometheus instrumentation
+// scmd needs (labelled counters, histograms and gauge callbacks) and serves it
+// in the Prometheus text exposition format without external dependencies.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default latency buckets in seconds. They cover fast
+// database lookups as well as slow local model answers.
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// collector is implemented by every metric type in a Registry.
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds a set of metrics exposed together.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default is the registry used by the package-level constructors.
+var Default = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.collectors[c.name()]; exists {
+		panic("metrics: duplicate metric " + c.name())
+	}
+	r.collectors[c.name()] = c
+}
+
+// Write writes all metrics in the text exposition format, sorted by name.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for n := range r.collectors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	cs := make([]collector, len(names))
+	for i, n := range names {
+		cs[i] = r.collectors[n]
+	}
+	r.mu.Unlock()
+
+	for _, c := range cs {
+		c.write(w)
+	}
+}
+
+// Handler serves the registry for Prometheus to scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// Handler serves the default registry.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// series keys a labelled child by its label values.
+type series struct {
+	values []string
+}
+
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(names)+len(extra)/2)
+	for i, n := range names {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, n, escapeLabel(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sortedKeys returns the keys of m in a stable order for output.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ── Counter ──
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct {
+	n, help string
+	labels  []string
+
+	mu     sync.Mutex
+	values map[string]*counterSeries
+}
+
+type counterSeries struct {
+	series
+	v float64
+}
+
+// NewCounterVec creates and registers a counter in the default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// NewCounterVec creates and registers a counter in r.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{n: name, help: help, labels: labels, values: make(map[string]*counterSeries)}
+	r.register(c)
+	return c
+}
+
+// Add increases the counter for the given label values by v. Negative
+// values are ignored because counters only go up.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.check(labelValues)
+	key := labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[key]
+	if !ok {
+		s = &counterSeries{series: series{values: append([]string(nil), labelValues...)}}
+		c.values[key] = s
+	}
+	s.v += v
+}
+
+// Inc increases the counter for the given label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the current count for the given label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[labelKey(labelValues)]; ok {
+		return s.v
+	}
+	return 0
+}
+
+func (c *CounterVec) check(values []string) {
+	if len(values) != len(c.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", c.n, len(c.labels), len(values)))
+	}
+}
+
+func (c *CounterVec) name() string { return c.n }
+
+func (c *CounterVec) write(w io.Writer) {
+	writeHeader(w, c.n, c.help, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range sortedKeys(c.values) {
+		s := c.values[k]
+		fmt.Fprintf(w, "%s%s %s\n", c.n, formatLabels(c.labels, s.values), formatFloat(s.v))
+	}
+}
+
+// ── Histogram ──
+
+// HistogramVec is a histogram partitioned by label values.
+type HistogramVec struct {
+	n, help string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	series
+	counts []uint64 // cumulative counts are computed on output
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates and registers a histogram in the default registry.
+// A nil buckets slice uses DefBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// NewHistogramVec creates and registers a histogram in r.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	h := &HistogramVec{n: name, help: help, labels: labels, buckets: b, values: make(map[string]*histogramSeries)}
+	r.register(h)
+	return h
+}
+
+// Observe records v for the given label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.n, len(h.labels), len(labelValues)))
+	}
+	key := labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSeries{
+			series: series{values: append([]string(nil), labelValues...)},
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations for the given label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.values[labelKey(labelValues)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) name() string { return h.n }
+
+func (h *HistogramVec) write(w io.Writer) {
+	writeHeader(w, h.n, h.help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range sortedKeys(h.values) {
+		s := h.values[k]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.n, formatLabels(h.labels, s.values, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.n, formatLabels(h.labels, s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.n, formatLabels(h.labels, s.values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.n, formatLabels(h.labels, s.values), s.count)
+	}
+}
+
+// ── Gauge ──
+
+// GaugeFunc is a gauge whose value is computed when metrics are scraped.
+type GaugeFunc struct {
+	n, help string
+	fn      func() (float64, bool)
+}
+
+// NewGaugeFunc creates and registers a gauge in the default registry. fn
+// returns the current value, or false when no value is available, in which
+// case the sample is omitted.
+func NewGaugeFunc(name, help string, fn func() (float64, bool)) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, fn)
+}
+
+// NewGaugeFunc creates and registers a gauge in r.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() (float64, bool)) *GaugeFunc {
+	g := &GaugeFunc{n: name, help: help, fn: fn}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string { return g.n }
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.n, g.help, "gauge")
+	if v, ok := g.fn(); ok {
+		fmt.Fprintf(w, "%s %s\n", g.n, formatFloat(v))
+	}
+}
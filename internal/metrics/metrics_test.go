@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "A test counter.", "route")
+	c.Inc("/")
+	c.Add(2, "/")
+	c.Add(-5, "/") // ignored
+	c.Inc(`/a"b`)
+
+	if got := c.Value("/"); got != 3 {
+		t.Errorf("Value = %v, want 3", got)
+	}
+
+	var b strings.Builder
+	r.Write(&b)
+	out := b.String()
+	for _, want := range []string{
+		"# HELP test_total A test counter.\n",
+		"# TYPE test_total counter\n",
+		`test_total{route="/"} 3` + "\n",
+		`test_total{route="/a\"b"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestCounterVec_WrongLabelCount(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "help", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for the wrong number of labels")
+		}
+	}()
+	c.Inc("only-one")
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	h.Observe(0.05, "chat")
+	h.Observe(0.5, "chat")
+	h.Observe(5, "chat")
+
+	var b strings.Builder
+	r.Write(&b)
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE latency_seconds histogram\n",
+		`latency_seconds_bucket{op="chat",le="0.1"} 1` + "\n",
+		`latency_seconds_bucket{op="chat",le="1"} 2` + "\n",
+		`latency_seconds_bucket{op="chat",le="+Inf"} 3` + "\n",
+		`latency_seconds_sum{op="chat"} 5.55` + "\n",
+		`latency_seconds_count{op="chat"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	r.NewGaugeFunc("size_bytes", "Size.", func() (float64, bool) { return 1024, true })
+	r.NewGaugeFunc("missing", "Unavailable.", func() (float64, bool) { return 0, false })
+
+	var b strings.Builder
+	r.Write(&b)
+	out := b.String()
+	if !strings.Contains(out, "size_bytes 1024\n") {
+		t.Errorf("output missing gauge sample:\n%s", out)
+	}
+	if strings.Contains(out, "missing 0") {
+		t.Errorf("unavailable gauge should have no sample:\n%s", out)
+	}
+}
+
+func TestRegistry_DuplicatePanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("dup_total", "help")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a duplicate metric")
+		}
+	}()
+	r.NewCounterVec("dup_total", "help")
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("handler_total", "help").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "handler_total 1\n") {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
+
+func TestRecordAI(t *testing.T) {
+	before := AIRequests.Value("test", "embed", "ok")
+	RecordAI("test", "embed", time.Now(), 0, nil)
+	RecordAI("test", "chat", time.Now(), 42, errors.New("boom"))
+
+	if got := AIRequests.Value("test", "embed", "ok"); got != before+1 {
+		t.Errorf("ok embed requests = %v, want %v", got, before+1)
+	}
+	if got := AIRequests.Value("test", "chat", "error"); got < 1 {
+		t.Errorf("error chat requests = %v, want >= 1", got)
+	}
+	if got := EmbeddingsGenerated.Value("test"); got < 1 {
+		t.Errorf("embeddings generated = %v, want >= 1", got)
+	}
+	if got := AITokens.Value("test"); got < 42 {
+		t.Errorf("tokens = %v, want >= 42", got)
+	}
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import "time"
+
+// Search paths reported by SmartSearch.
+const (
+	PathKeyword  = "keyword"  // database keyword matches were good enough
+	PathVector   = "vector"   // AI answer with vector-search context
+	PathAI       = "ai"       // AI answer with keyword-match context
+	PathFallback = "fallback" // AI answer without any database context
+)
+
+// Application metrics.
+var (
+	HTTPRequests = NewCounterVec("scmd_http_requests_total",
+		"HTTP requests served, by route, method and status code.", "route", "method", "code")
+	HTTPDuration = NewHistogramVec("scmd_http_request_duration_seconds",
+		"HTTP request latency in seconds, by route and method.", nil, "route", "method")
+
+	SearchPaths = NewCounterVec("scmd_search_path_total",
+		"Searches by the path SmartSearch took (keyword, vector, ai, fallback).", "path")
+
+	AIRequests = NewCounterVec("scmd_ai_requests_total",
+		"AI provider calls, by provider, operation (chat, stream, embed) and status (ok, error).", "provider", "operation", "status")
+	AIDuration = NewHistogramVec("scmd_ai_request_duration_seconds",
+		"AI provider call latency in seconds, by provider and operation.", nil, "provider", "operation")
+	AITokens = NewCounterVec("scmd_ai_tokens_total",
+		"Tokens reported by AI providers, by provider.", "provider")
+
+	EmbeddingsGenerated = NewCounterVec("scmd_embeddings_generated_total",
+		"Embeddings generated successfully, by provider.", "provider")
+
+	AICache = NewCounterVec("scmd_ai_cache_total",
+		"AI response cache lookups, by kind (chat, embed) and result (hit, miss).", "kind", "result")
+)
+
+// RecordSearchPath counts a search that took path.
+func RecordSearchPath(path string) {
+	SearchPaths.Inc(path)
+}
+
+// RecordAI records the outcome of one AI provider call started at start.
+// For the "embed" operation a successful call also counts one generated
+// embedding.
+func RecordAI(provider, operation string, start time.Time, tokens int, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	AIRequests.Inc(provider, operation, status)
+	AIDuration.Observe(time.Since(start).Seconds(), provider, operation)
+	if tokens > 0 {
+		AITokens.Add(float64(tokens), provider)
+	}
+	if operation == "embed" && err == nil {
+		EmbeddingsGenerated.Inc(provider)
+	}
+}
+
+// RecordCache counts one AI cache lookup of kind.
+func RecordCache(kind string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	AICache.Inc(kind, result)
+}
@@ -0,0 +1,223 @@
+// Package prompts renders the prompts sent to the AI providers from Go
+// text/template files. The defaults are embedded in the binary; a file with
+// the same name in ~/.scmd/prompts overrides one of them.
+//
+// Every default starts with a comment such as
+//
+//	{{- /* scmd-prompt v2: ... */ -}}
+//
+// declaring its version, so a customised copy made from an older default
+// can be recognised as outdated.
+package prompts
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/gcclinux/scmd/internal/config"
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/logging"
+	"github.com/gcclinux/scmd/internal/sysenv"
+)
+
+//go:embed defaults/*.tmpl
+var defaults embed.FS
+
+// Template names.
+const (
+	SystemTemplate  = "system"  // system instructions for other questions
+	UserTemplate    = "user"    // the question with its context records
+	PersonaTemplate = "persona" // system instructions for a persona question
+)
+
+// Names lists the templates in display order.
+var Names = []string{SystemTemplate, UserTemplate, PersonaTemplate}
+
+var logger = logging.For(logging.AI)
+
+var versionPattern = regexp.MustCompile(`scmd-prompt v(\d+)`)
+
+// Data is the value templates are executed with.
+type Data struct {
+	Query   string                   // the user's question
+	Context []database.CommandRecord // stored commands found for the question
+	Persona Persona                  // set for PersonaTemplate
+	OS      OSInfo
+}
+
+// Persona describes the persona a question is asked through.
+type Persona struct {
+	Key          string // slash command without the slash, e.g. "ubuntu"
+	Name         string
+	Instructions string
+}
+
+// OSInfo describes the machine scmd runs on. Templates can use its
+// fields, such as .OS.Distro and .OS.Tools, and .OS.Summary.
+type OSInfo = sysenv.Env
+
+// CurrentOS returns the OSInfo of this machine, detected once per process.
+func CurrentOS() OSInfo {
+	return sysenv.Current()
+}
+
+// Template is the source of a prompt template and where it came from.
+type Template struct {
+	Name           string
+	Source         string
+	Path           string // override file, whether or not it exists
+	Custom         bool   // Source was read from Path
+	Version        int    // version declared in Source, 0 if none
+	DefaultVersion int    // version of the embedded default
+}
+
+// Outdated reports whether a custom template declares an older version
+// than the embedded default, which may have gained fixes since.
+func (t Template) Outdated() bool {
+	return t.Custom && t.Version < t.DefaultVersion
+}
+
+// Dir returns the directory holding custom templates (~/.scmd/prompts).
+func Dir() string {
+	return filepath.Join(config.ConfigDir(), "prompts")
+}
+
+// Default returns the embedded default source of the named template.
+func Default(name string) (string, error) {
+	data, err := defaults.ReadFile("defaults/" + name + ".tmpl")
+	if err != nil {
+		return "", fmt.Errorf("unknown prompt template '%s' (available: %s)", name, strings.Join(Names, ", "))
+	}
+	return string(data), nil
+}
+
+// Load returns the named template, preferring a custom file in Dir.
+func Load(name string) (Template, error) {
+	def, err := Default(name)
+	if err != nil {
+		return Template{}, err
+	}
+	t := Template{
+		Name:           name,
+		Source:         def,
+		Path:           filepath.Join(Dir(), name+".tmpl"),
+		Version:        version(def),
+		DefaultVersion: version(def),
+	}
+
+	data, err := os.ReadFile(t.Path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return t, fmt.Errorf("error reading %s: %v", t.Path, err)
+	}
+	t.Source = string(data)
+	t.Custom = true
+	t.Version = version(t.Source)
+	return t, nil
+}
+
+// Install copies the default of the named template to its override file,
+// unless one already exists, and returns the file path.
+func Install(name string) (string, error) {
+	t, err := Load(name)
+	if err != nil {
+		return "", err
+	}
+	if t.Custom {
+		return t.Path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(t.Path), 0755); err != nil {
+		return "", fmt.Errorf("error creating %s: %v", filepath.Dir(t.Path), err)
+	}
+	if err := os.WriteFile(t.Path, []byte(t.Source), 0644); err != nil {
+		return "", fmt.Errorf("error writing %s: %v", t.Path, err)
+	}
+	return t.Path, nil
+}
+
+// Check parses the named template and executes it with sample data.
+func Check(name string) error {
+	t, err := Load(name)
+	if err != nil {
+		return err
+	}
+	_, err = execute(t, sampleData())
+	return err
+}
+
+// Render executes the named template with data. A custom template that
+// cannot be read, parsed or executed is logged and the default is used
+// instead, so a broken override never stops the AI from answering.
+func Render(name string, data Data) string {
+	t, err := Load(name)
+	if err == nil {
+		var out string
+		if out, err = execute(t, data); err == nil {
+			return out
+		}
+	}
+	logger.Warn("prompt template failed, using the default", "template", name, "err", err)
+
+	def, err := Default(name)
+	if err != nil {
+		return ""
+	}
+	out, err := execute(Template{Name: name, Source: def}, data)
+	if err != nil {
+		logger.Error("default prompt template failed", "template", name, "err", err)
+	}
+	return out
+}
+
+// Chat renders the system and user prompts for question and its context
+// records. env describes the asking user's machine; the zero OSInfo leaves
+// it out.
+func Chat(question string, records []database.CommandRecord, env OSInfo) (system, user string) {
+	data := Data{Query: question, Context: records, OS: env}
+	return Render(SystemTemplate, data), Render(UserTemplate, data)
+}
+
+var funcs = template.FuncMap{
+	"inc":  func(i int) int { return i + 1 },
+	"join": strings.Join,
+	"trim": strings.TrimSpace,
+}
+
+func execute(t Template, data Data) (string, error) {
+	tmpl, err := template.New(t.Name).Funcs(funcs).Option("missingkey=error").Parse(t.Source)
+	if err != nil {
+		return "", fmt.Errorf("error parsing prompt template '%s': %v", t.Name, err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("error executing prompt template '%s': %v", t.Name, err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+func version(source string) int {
+	m := versionPattern.FindStringSubmatch(source)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+// sampleData is used to check templates.
+func sampleData() Data {
+	return Data{
+		Query:   "list running containers",
+		Context: []database.CommandRecord{{Id: 1, Key: "docker ps", Data: "List running containers"}},
+		Persona: Persona{Key: "ubuntu", Name: "Ubuntu Expert", Instructions: "Prefer apt."},
+		OS:      CurrentOS(),
+	}
+}
@@ -0,0 +1,119 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func useHome(t *testing.T) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+}
+
+func writeOverride(t *testing.T, name, source string) {
+	t.Helper()
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(Dir(), name+".tmpl"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	useHome(t)
+	for _, name := range Names {
+		if err := Check(name); err != nil {
+			t.Errorf("default %s: %v", name, err)
+		}
+		if tmpl, _ := Load(name); tmpl.Custom || tmpl.DefaultVersion < 1 {
+			t.Errorf("default %s: custom %v, version %d", name, tmpl.Custom, tmpl.DefaultVersion)
+		}
+	}
+	if _, err := Load("nope"); err == nil {
+		t.Error("unknown template should fail")
+	}
+}
+
+func TestChat(t *testing.T) {
+	useHome(t)
+	records := []database.CommandRecord{{Key: "docker ps", Data: "List containers"}}
+	system, user := Chat("list containers", records, OSInfo{})
+
+	if !strings.HasPrefix(system, "You are a helpful assistant") || strings.Contains(system, "scmd-prompt") {
+		t.Errorf("system prompt = %q", system)
+	}
+	want := "Here are some relevant commands from the database:\n\n1. Description: List containers\n   Command: docker ps\n\n\nUser question: list containers"
+	if user != want {
+		t.Errorf("user prompt =\n%q\nwant\n%q", user, want)
+	}
+	if _, user := Chat("hello", nil, OSInfo{}); user != "User question: hello" {
+		t.Errorf("user prompt without context = %q", user)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	useHome(t)
+	writeOverride(t, UserTemplate, "Q={{.Query}} N={{len .Context}} OS={{.OS.OS}}")
+
+	tmpl, err := Load(UserTemplate)
+	if err != nil || !tmpl.Custom || !tmpl.Outdated() {
+		t.Errorf("Load = %+v, %v; want an outdated custom template", tmpl, err)
+	}
+	got := Render(UserTemplate, Data{Query: "q", OS: OSInfo{OS: "linux"}})
+	if got != "Q=q N=0 OS=linux" {
+		t.Errorf("Render = %q", got)
+	}
+
+	writeOverride(t, UserTemplate, "{{.Nope}}")
+	if err := Check(UserTemplate); err == nil {
+		t.Error("Check should report an unknown field")
+	}
+	if got := Render(UserTemplate, Data{Query: "q"}); got != "User question: q" {
+		t.Errorf("a broken override should fall back to the default, got %q", got)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	useHome(t)
+	path, err := Install(PersonaTemplate)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	def, _ := Default(PersonaTemplate)
+	if data, err := os.ReadFile(path); err != nil || string(data) != def {
+		t.Fatalf("installed file = %q, %v", data, err)
+	}
+
+	writeOverride(t, PersonaTemplate, "custom")
+	if _, err := Install(PersonaTemplate); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "custom" {
+		t.Error("Install should not overwrite an existing override")
+	}
+}
+
+func TestSystemEnvironment(t *testing.T) {
+	useHome(t)
+	env := OSInfo{OS: "linux", Arch: "amd64", Shell: "bash", Distro: "Fedora Linux 40", PackageManager: "dnf", Tools: []string{"dnf", "git"}, Detected: true}
+
+	got := Render(SystemTemplate, Data{Query: "q", OS: env})
+	if !strings.Contains(got, "The user's system is Fedora Linux 40 (linux/amd64), shell bash, package manager dnf. Installed tools: dnf, git.") {
+		t.Errorf("system prompt does not describe the environment:\n%s", got)
+	}
+	got = Render(PersonaTemplate, Data{Query: "q", OS: env, Persona: Persona{Instructions: "Prefer apt."}})
+	if !strings.HasPrefix(got, "Prefer apt.") || !strings.Contains(got, "own system is Fedora Linux 40") {
+		t.Errorf("persona prompt:\n%s", got)
+	}
+
+	env.Detected = false
+	if got := Render(SystemTemplate, Data{Query: "q", OS: env}); strings.Contains(got, "Fedora") {
+		t.Errorf("system prompt should leave out an undetected environment:\n%s", got)
+	}
+}
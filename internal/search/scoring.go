@@ -1,8 +1,12 @@
 package search
 
 import (
+	"math"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/gcclinux/scmd/internal/config"
 	"github.com/gcclinux/scmd/internal/database"
 )
 
@@ -12,13 +16,74 @@ type CommandScore struct {
 	Score      int
 	MatchCount int
 	TotalWords int
+	Boost      float64 // bonus from usage history and pins
 }
 
-// ScoreCommands scores commands based on how many query words they match.
+// Rank is the value results are ordered by: the match score plus the
+// usage boost. Thresholds such as FilterByMinScore still use Score alone,
+// so popularity never turns a poor match into a good one.
+func (s CommandScore) Rank() float64 {
+	return float64(s.Score) + s.Boost
+}
+
+// Usage boost tuning. The cap is below the score of one extra matched word
+// on queries of up to three words, so usage reorders equally good matches
+// rather than overriding relevance.
+const (
+	MaxUsageBoost   = 25.0
+	usageBoostScale = 4.0
+	usageHalfLife   = 30 * 24 * time.Hour
+	usageViewWeight = 1
+	usageCopyWeight = 2
+	usageExecWeight = 3
+)
+
+// usageStats is swapped out by tests.
+var usageStats = database.UsageStats
+
+// UsageBoost returns the ranking bonus for a command's usage history.
+// Popularity grows logarithmically with weighted use (executions count
+// more than copies, copies more than views) and half of it decays with a
+// 30 day half-life since the last use.
+func UsageBoost(stat database.UsageStat, now time.Time) float64 {
+	weighted := stat.Views*usageViewWeight + stat.Copies*usageCopyWeight + stat.Execs*usageExecWeight
+	if weighted <= 0 {
+		return 0
+	}
+	popularity := usageBoostScale * math.Log2(1+float64(weighted))
+	age := max(0, now.Sub(stat.LastUsed))
+	recency := 0.5 + 0.5*math.Pow(0.5, float64(age)/float64(usageHalfLife))
+	return min(MaxUsageBoost, popularity*recency)
+}
+
+// PinBoost is added to the rank of matching commands the user has pinned.
+// It outweighs any usage boost but, like it, never makes a command that
+// matches no query words rank at all.
+const PinBoost = 40.0
+
+// ScoreCommands scores commands based on how many query words they match,
+// boosted by how often and how recently each one was used unless the
+// usage_boost setting is off.
 func ScoreCommands(commands []database.CommandRecord, query string) []CommandScore {
+	return ScoreCommandsPinned(commands, query, nil)
+}
+
+// ScoreCommandsPinned is ScoreCommands with an extra PinBoost for the
+// command IDs in pinned.
+func ScoreCommandsPinned(commands []database.CommandRecord, query string, pinned map[int]bool) []CommandScore {
 	queryWords := ExtractQueryWords(query)
 	totalWords := len(queryWords)
 
+	var stats map[int]database.UsageStat
+	if len(commands) > 0 && config.GetBool("USAGE_BOOST", true) {
+		ids := make([]int, len(commands))
+		for i, cmd := range commands {
+			ids[i] = cmd.Id
+		}
+		stats, _ = usageStats(ids...) // no history just means no boost
+	}
+	now := time.Now()
+
 	var scored []CommandScore
 
 	for _, cmd := range commands {
@@ -38,22 +103,29 @@ func ScoreCommands(commands []database.CommandRecord, query string) []CommandSco
 			score = (matchCount * 100) / totalWords
 		}
 
+		boost := 0.0
+		if score > 0 {
+			if stat, ok := stats[cmd.Id]; ok {
+				boost = UsageBoost(stat, now)
+			}
+			if pinned[cmd.Id] {
+				boost += PinBoost
+			}
+		}
+
 		scored = append(scored, CommandScore{
 			Record:     cmd,
 			Score:      score,
 			MatchCount: matchCount,
 			TotalWords: totalWords,
+			Boost:      boost,
 		})
 	}
 
-	// Sort by score (highest first)
-	for i := 0; i < len(scored)-1; i++ {
-		for j := i + 1; j < len(scored); j++ {
-			if scored[j].Score > scored[i].Score {
-				scored[i], scored[j] = scored[j], scored[i]
-			}
-		}
-	}
+	// Sort by rank (highest first)
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Rank() > scored[j].Rank()
+	})
 
 	return scored
 }
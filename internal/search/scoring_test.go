@@ -0,0 +1,93 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func TestUsageBoost(t *testing.T) {
+	now := time.Now()
+	if b := UsageBoost(database.UsageStat{}, now); b != 0 {
+		t.Errorf("unused command boost = %v, want 0", b)
+	}
+
+	recent := database.UsageStat{Views: 2, LastUsed: now}
+	old := database.UsageStat{Views: 2, LastUsed: now.Add(-90 * 24 * time.Hour)}
+	if UsageBoost(recent, now) <= UsageBoost(old, now) {
+		t.Error("recent use should boost more than old use")
+	}
+	if UsageBoost(old, now) <= 0 {
+		t.Error("old use should still give some boost")
+	}
+
+	execs := database.UsageStat{Execs: 2, LastUsed: now}
+	if UsageBoost(execs, now) <= UsageBoost(recent, now) {
+		t.Error("executions should weigh more than views")
+	}
+	heavy := database.UsageStat{Views: 1000, Copies: 1000, Execs: 1000, LastUsed: now}
+	if b := UsageBoost(heavy, now); b != MaxUsageBoost {
+		t.Errorf("heavy use boost = %v, want cap %v", b, MaxUsageBoost)
+	}
+}
+
+func TestScoreCommandsUsageBoost(t *testing.T) {
+	orig := usageStats
+	defer func() { usageStats = orig }()
+	var asked []int
+	usageStats = func(ids ...int) (map[int]database.UsageStat, error) {
+		asked = ids
+		return map[int]database.UsageStat{
+			2: {CommandID: 2, Execs: 5, LastUsed: time.Now()},
+			3: {CommandID: 3, Execs: 50, LastUsed: time.Now()},
+		}, nil
+	}
+
+	commands := []database.CommandRecord{
+		{Id: 1, Key: "docker ps", Data: "list docker containers"},
+		{Id: 2, Key: "docker ps -a", Data: "list all docker containers"},
+		{Id: 3, Key: "kubectl get pods", Data: "list pods"},
+	}
+	scored := ScoreCommands(commands, "docker containers")
+	if fmt.Sprint(asked) != "[1 2 3]" {
+		t.Errorf("usage stats asked for %v, want the scored IDs", asked)
+	}
+	got := []int{scored[0].Record.Id, scored[1].Record.Id, scored[2].Record.Id}
+	if got[0] != 2 || got[1] != 1 || got[2] != 3 {
+		t.Errorf("order = %v, want [2 1 3]", got)
+	}
+	if scored[2].Boost != 0 {
+		t.Errorf("non-matching command boost = %v, want 0", scored[2].Boost)
+	}
+	if scored[0].Score != scored[1].Score {
+		t.Errorf("boost changed Score: %d vs %d", scored[0].Score, scored[1].Score)
+	}
+
+	t.Setenv("USAGE_BOOST", "false")
+	scored = ScoreCommands(commands, "docker containers")
+	if scored[0].Record.Id != 1 || scored[0].Boost != 0 {
+		t.Errorf("usage_boost=false: top = %d boost %v, want 1 with no boost", scored[0].Record.Id, scored[0].Boost)
+	}
+}
+
+func TestScoreCommandsPinned(t *testing.T) {
+	orig := usageStats
+	defer func() { usageStats = orig }()
+	usageStats = func(...int) (map[int]database.UsageStat, error) { return nil, nil }
+
+	commands := []database.CommandRecord{
+		{Id: 1, Key: "docker ps", Data: "list docker containers"},
+		{Id: 2, Key: "docker ps -a", Data: "list all docker containers"},
+		{Id: 3, Key: "kubectl get pods", Data: "list pods"},
+	}
+	pinned := map[int]bool{2: true, 3: true}
+	scored := ScoreCommandsPinned(commands, "docker containers", pinned)
+	if scored[0].Record.Id != 2 || scored[0].Boost != PinBoost {
+		t.Errorf("top = %d boost %v, want pinned 2", scored[0].Record.Id, scored[0].Boost)
+	}
+	if scored[2].Record.Id != 3 || scored[2].Boost != 0 {
+		t.Errorf("pinned non-match = %d boost %v, want 3 unboosted", scored[2].Record.Id, scored[2].Boost)
+	}
+}
@@ -3,7 +3,6 @@ package search
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/gcclinux/scmd/internal/database"
@@ -11,17 +10,17 @@ import (
 )
 
 // RunCLISearch prints the result returned from PostgreSQL database.
-func RunCLISearch(pattern string) {
-	util.WriteLogToFile(util.WebLog, "CLI: "+pattern)
+func RunCLISearch(pattern string) error {
+	util.WriteLogToFile(util.WebLog(), "CLI: "+pattern)
 
 	if err := database.InitDB(); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %v", err)
 	}
 	defer database.CloseDB()
 
 	received, err := database.SearchCommands(pattern, "json")
 	if err != nil {
-		log.Fatalf("Error searching commands: %v", err)
+		return fmt.Errorf("error searching commands: %v", err)
 	}
 
 	var dt []database.CommandRecord
@@ -46,4 +45,5 @@ func RunCLISearch(pattern string) {
 			fmt.Println(string(out))
 		}
 	}
+	return nil
 }
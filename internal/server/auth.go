@@ -3,31 +3,61 @@ package server
 import (
 	"crypto/rand"
 	"encoding/base64"
-	"log"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/gcclinux/scmd/internal/config"
 	"github.com/gcclinux/scmd/internal/database"
 )
 
+// defaultSessionTTL is used when session_ttl is not configured.
+const defaultSessionTTL = 24 * time.Hour
+
 // Session represents a user session.
 type Session struct {
 	Email     string
+	CSRFToken string
 	CreatedAt time.Time
 	ExpiresAt time.Time
 }
 
-// SessionStore manages active sessions.
-type SessionStore struct {
+// SessionStore manages active sessions. Implementations must be safe for
+// concurrent use by multiple handlers.
+type SessionStore interface {
+	// CreateSession creates a new session for the user and returns its ID.
+	CreateSession(email string) (string, error)
+	// GetSession retrieves a live session by ID. With sliding expiry enabled
+	// a successful lookup also pushes the expiry time forward.
+	GetSession(sessionID string) (*Session, bool)
+	// DeleteSession removes a session.
+	DeleteSession(sessionID string)
+	// CleanupExpiredSessions removes expired sessions.
+	CleanupExpiredSessions()
+}
+
+// MemorySessionStore keeps sessions in process memory. Sessions are lost when
+// the server restarts and are not shared between replicas.
+type MemorySessionStore struct {
 	sessions map[string]*Session
-	mu       sync.RWMutex
+	mu       sync.Mutex
+	ttl      time.Duration
+	sliding  bool
 }
 
-var sessionStore = &SessionStore{
-	sessions: make(map[string]*Session),
+// NewMemorySessionStore creates an in-memory session store.
+func NewMemorySessionStore(ttl time.Duration, sliding bool) *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*Session),
+		ttl:      ttl,
+		sliding:  sliding,
+	}
 }
 
+var sessionStore SessionStore = NewMemorySessionStore(defaultSessionTTL, false)
+
 func generateSessionID() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
@@ -37,7 +67,7 @@ func generateSessionID() (string, error) {
 }
 
 // CreateSession creates a new session for the user.
-func (s *SessionStore) CreateSession(email string) (string, error) {
+func (s *MemorySessionStore) CreateSession(email string) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -45,11 +75,17 @@ func (s *SessionStore) CreateSession(email string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	csrf, err := generateSessionID()
+	if err != nil {
+		return "", err
+	}
 
+	now := time.Now()
 	session := &Session{
 		Email:     email,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(24 * time.Hour),
+		CSRFToken: csrf,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
 	}
 
 	s.sessions[sessionID] = session
@@ -57,29 +93,34 @@ func (s *SessionStore) CreateSession(email string) (string, error) {
 }
 
 // GetSession retrieves a session by ID.
-func (s *SessionStore) GetSession(sessionID string) (*Session, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *MemorySessionStore) GetSession(sessionID string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	session, exists := s.sessions[sessionID]
 	if !exists {
 		return nil, false
 	}
-	if time.Now().After(session.ExpiresAt) {
+	now := time.Now()
+	if now.After(session.ExpiresAt) {
 		return nil, false
 	}
-	return session, true
+	if s.sliding {
+		session.ExpiresAt = now.Add(s.ttl)
+	}
+	copied := *session
+	return &copied, true
 }
 
 // DeleteSession removes a session.
-func (s *SessionStore) DeleteSession(sessionID string) {
+func (s *MemorySessionStore) DeleteSession(sessionID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.sessions, sessionID)
 }
 
 // CleanupExpiredSessions removes expired sessions.
-func (s *SessionStore) CleanupExpiredSessions() {
+func (s *MemorySessionStore) CleanupExpiredSessions() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -91,6 +132,29 @@ func (s *SessionStore) CleanupExpiredSessions() {
 	}
 }
 
+// sessionTTL returns the configured session lifetime (session_ttl).
+func sessionTTL() time.Duration {
+	return config.ParseDuration(os.Getenv("SESSION_TTL"), defaultSessionTTL)
+}
+
+// newSessionStore builds the session store selected by session_store.
+// "sqlite" keeps sessions in the database so they survive restarts and can
+// be shared by several replicas; anything else uses process memory.
+func newSessionStore() SessionStore {
+	ttl := sessionTTL()
+	sliding := config.GetBool("SESSION_SLIDING", false)
+
+	switch strings.ToLower(os.Getenv("SESSION_STORE")) {
+	case "sqlite", "database", "db":
+		if database.IsMCP() {
+			logger.Warn("session_store=sqlite is not supported with the MCP backend, using memory")
+			break
+		}
+		return NewSQLiteSessionStore(ttl, sliding)
+	}
+	return NewMemorySessionStore(ttl, sliding)
+}
+
 // RequireAuth is middleware that checks if user is authenticated.
 func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -105,20 +169,51 @@ func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
 			return
 		}
+		if config.GetBool("SESSION_SLIDING", false) {
+			setSessionCookie(w, r, cookie.Value)
+		}
 
 		next(w, r)
 	}
 }
 
-// StartSessionCleanup starts a goroutine to periodically clean up expired sessions.
-func StartSessionCleanup() {
-	ticker := time.NewTicker(1 * time.Hour)
+// setSessionCookie sends the session cookie with a lifetime matching the
+// configured session TTL. The cookie is marked Secure when TLS is active.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, sessionID string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    sessionID,
+		Path:     "/",
+		MaxAge:   int(sessionTTL().Seconds()),
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// StartSessionCleanup starts a goroutine that periodically removes expired
+// sessions from store. The returned function stops the goroutine.
+func StartSessionCleanup(store SessionStore) (stop func()) {
+	interval := time.Hour
+	if ttl := sessionTTL(); ttl > 0 && ttl < interval {
+		interval = ttl
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
 	go func() {
-		for range ticker.C {
-			sessionStore.CleanupExpiredSessions()
-			log.Println("Cleaned up expired sessions")
+		for {
+			select {
+			case <-ticker.C:
+				store.CleanupExpiredSessions()
+				logger.Debug("cleaned up expired sessions")
+			case <-done:
+				ticker.Stop()
+				return
+			}
 		}
 	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
 }
 
 // authenticateUser wraps database.AuthenticateUser for use in handlers.
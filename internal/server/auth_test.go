@@ -0,0 +1,59 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemorySessionStore_Lifecycle(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour, false)
+
+	id, err := store.CreateSession("dev@example.com")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	s, ok := store.GetSession(id)
+	if !ok || s.Email != "dev@example.com" {
+		t.Fatalf("GetSession = %+v, %v", s, ok)
+	}
+
+	store.DeleteSession(id)
+	if _, ok := store.GetSession(id); ok {
+		t.Error("session still present after DeleteSession")
+	}
+}
+
+func TestMemorySessionStore_Expiry(t *testing.T) {
+	store := NewMemorySessionStore(-time.Second, false)
+	id, _ := store.CreateSession("dev@example.com")
+	if _, ok := store.GetSession(id); ok {
+		t.Error("expired session should not be returned")
+	}
+	store.CleanupExpiredSessions()
+	if len(store.sessions) != 0 {
+		t.Errorf("CleanupExpiredSessions left %d sessions", len(store.sessions))
+	}
+}
+
+func TestMemorySessionStore_SlidingExpiry(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour, true)
+	id, _ := store.CreateSession("dev@example.com")
+
+	// Pretend the session is about to expire; a lookup should renew it.
+	store.sessions[id].ExpiresAt = time.Now().Add(time.Second)
+	s, ok := store.GetSession(id)
+	if !ok {
+		t.Fatal("session should still be valid")
+	}
+	if time.Until(s.ExpiresAt) < 59*time.Minute {
+		t.Errorf("sliding expiry not applied, expires in %v", time.Until(s.ExpiresAt))
+	}
+
+	fixed := NewMemorySessionStore(time.Hour, false)
+	id, _ = fixed.CreateSession("dev@example.com")
+	fixed.sessions[id].ExpiresAt = time.Now().Add(time.Second)
+	s, _ = fixed.GetSession(id)
+	if time.Until(s.ExpiresAt) > time.Minute {
+		t.Error("fixed expiry should not be extended")
+	}
+}
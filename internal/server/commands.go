@@ -0,0 +1,217 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gcclinux/scmd/internal/ai"
+	"github.com/gcclinux/scmd/internal/bulk"
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/util"
+)
+
+// registerCommandAPI registers the JSON endpoints for stored commands.
+// Read-only servers (-block) only expose GET and bulk export.
+func registerCommandAPI(mux *http.ServeMux, readOnly bool) {
+	mux.HandleFunc("GET /api/v1/commands/{id}", getCommandAPI)
+	mux.HandleFunc("POST /api/v1/commands/bulk", func(w http.ResponseWriter, r *http.Request) {
+		bulkCommandsAPI(w, r, readOnly)
+	})
+	if readOnly {
+		return
+	}
+	mux.HandleFunc("PUT /api/v1/commands/{id}", updateCommandAPI)
+	mux.HandleFunc("DELETE /api/v1/commands/{id}", deleteCommandAPI)
+}
+
+// commandID parses the {id} path value, writing a 400 response when it is
+// not a positive integer.
+func commandID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid command id")
+		return 0, false
+	}
+	return id, true
+}
+
+// webOrigin attributes a change to the logged-in user, or to the client
+// address when authentication is off.
+func webOrigin(r *http.Request, source string) database.Origin {
+	author := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		author = host
+	}
+	if sessionStore != nil {
+		if session := currentSession(r); session != nil {
+			author = session.Email
+		}
+	}
+	return database.Origin{Author: author, Source: source}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("encoding response", "err", err)
+	}
+}
+
+// getCommandAPI returns one command as JSON.
+func getCommandAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	record, err := database.GetCommandByID(id)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "command not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// commandUpdate is the PUT /api/v1/commands/{id} request body. Omitting
+// tags leaves them unchanged.
+type commandUpdate struct {
+	Key  string    `json:"key"`
+	Data string    `json:"data"`
+	Tags *[]string `json:"tags"`
+}
+
+// updateCommandAPI replaces a command's text, description and tags and
+// re-embeds it with the best available provider.
+func updateCommandAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	var req commandUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	req.Key = strings.TrimSpace(req.Key)
+	req.Data = strings.TrimSpace(req.Data)
+	if req.Key == "" || req.Data == "" {
+		writeJSONError(w, http.StatusBadRequest, "command and description are required")
+		return
+	}
+
+	current, err := database.GetCommandByID(id)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "command not found")
+		return
+	}
+	if req.Key != current.Key {
+		exists, err := database.CheckCommandExists(req.Key)
+		if err != nil {
+			logger.Error("checking command existence", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "failed to check for duplicates")
+			return
+		}
+		if exists {
+			writeJSONError(w, http.StatusConflict, "another command with this text already exists")
+			return
+		}
+	}
+
+	var tags []string
+	if req.Tags != nil {
+		tags = *req.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+	}
+	if err := database.UpdateCommand(id, req.Key, req.Data, tags, webOrigin(r, database.SourceWeb), ai.GetBestEmbedding); err != nil {
+		logger.Error("updating command", "id", id, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.WriteLogToFile(util.WebLog(), "EDIT: "+strconv.Itoa(id)+" "+r.RemoteAddr)
+
+	record, err := database.GetCommandByID(id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to reload command")
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// deleteCommandAPI removes a command.
+func deleteCommandAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	deleted, err := database.DeleteCommand(id)
+	if err != nil {
+		logger.Error("deleting command", "id", id, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to delete command")
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "command not found")
+		return
+	}
+	util.WriteLogToFile(util.WebLog(), "DELETE: "+strconv.Itoa(id)+" "+r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bulkRequest is the POST /api/v1/commands/bulk request body.
+type bulkRequest struct {
+	Op         string   `json:"op"`
+	IDs        []int    `json:"ids"`
+	Query      string   `json:"query"`
+	Tag        string   `json:"tag"`
+	AddTags    []string `json:"add_tags"`
+	RemoveTags []string `json:"remove_tags"`
+	DryRun     bool     `json:"dry_run"`
+}
+
+// bulkCommandsAPI applies one bulk operation to the selected commands and
+// returns the bulk.Result. Export returns the selected records without
+// writing anything; every other operation is refused on read-only servers.
+func bulkCommandsAPI(w http.ResponseWriter, r *http.Request, readOnly bool) {
+	var req bulkRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if readOnly && req.Op != bulk.OpExport {
+		writeJSONError(w, http.StatusForbidden, "server is read-only")
+		return
+	}
+	sel := bulk.Selection{Query: req.Query, Tag: req.Tag, IDs: req.IDs}
+	if sel.Empty() {
+		writeJSONError(w, http.StatusBadRequest, "no commands selected")
+		return
+	}
+
+	opts := bulk.Options{
+		Op:         req.Op,
+		Selection:  sel,
+		DryRun:     req.DryRun,
+		AddTags:    req.AddTags,
+		RemoveTags: req.RemoveTags,
+		Origin:     webOrigin(r, database.SourceWeb),
+	}
+	if req.Op == bulk.OpReembed {
+		opts.Embed = ai.GetBestEmbedding
+	}
+	res, err := bulk.Run(opts)
+	if err != nil {
+		logger.Warn("bulk operation failed", "op", req.Op, "err", err)
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !res.DryRun && req.Op != bulk.OpExport {
+		util.WriteLogToFile(util.WebLog(), fmt.Sprintf("BULK %s: %d %s", strings.ToUpper(req.Op), res.Affected, r.RemoteAddr))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func commandRequest(t *testing.T, mux *http.ServeMux, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCommandAPI_GetUpdateDelete(t *testing.T) {
+	setupTestDB(t)
+	database.AddCommand("ls -la", "list files", database.Origin{}, nil)
+	database.AddCommand("pwd", "print directory", database.Origin{}, nil)
+
+	mux := http.NewServeMux()
+	registerCommandAPI(mux, false)
+
+	rec := commandRequest(t, mux, "GET", "/api/v1/commands/1", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"key":"ls -la"`) {
+		t.Fatalf("GET = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = commandRequest(t, mux, "PUT", "/api/v1/commands/1", `{"key":"ls -lah","data":"list *all* files","tags":["shell"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body.String())
+	}
+	var updated database.CommandRecord
+	json.Unmarshal(rec.Body.Bytes(), &updated)
+	if updated.Key != "ls -lah" || len(updated.Tags) != 1 || updated.Tags[0] != "shell" {
+		t.Errorf("updated = %+v", updated)
+	}
+
+	rec = commandRequest(t, mux, "PUT", "/api/v1/commands/1", `{"key":"pwd","data":"dup"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate PUT = %d, want 409", rec.Code)
+	}
+	rec = commandRequest(t, mux, "PUT", "/api/v1/commands/1", `{"key":"","data":"x"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("empty PUT = %d, want 400", rec.Code)
+	}
+
+	rec = commandRequest(t, mux, "DELETE", "/api/v1/commands/2", "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", rec.Code)
+	}
+	rec = commandRequest(t, mux, "DELETE", "/api/v1/commands/2", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("second DELETE = %d, want 404", rec.Code)
+	}
+	rec = commandRequest(t, mux, "GET", "/api/v1/commands/abc", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET bad id = %d, want 400", rec.Code)
+	}
+}
+
+func TestCommandAPI_ReadOnly(t *testing.T) {
+	setupTestDB(t)
+	database.AddCommand("ls -la", "list files", database.Origin{}, nil)
+
+	mux := http.NewServeMux()
+	registerCommandAPI(mux, true)
+
+	if rec := commandRequest(t, mux, "GET", "/api/v1/commands/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET = %d, want 200", rec.Code)
+	}
+	for _, method := range []string{"PUT", "DELETE"} {
+		if rec := commandRequest(t, mux, method, "/api/v1/commands/1", `{}`); rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s in read-only mode = %d, want 405", method, rec.Code)
+		}
+	}
+}
+
+func TestCommandAPI_Bulk(t *testing.T) {
+	setupTestDB(t)
+	database.AddCommand("docker ps", "list containers", database.Origin{}, nil)
+	database.AddCommand("docker images", "list images", database.Origin{}, nil)
+	database.AddCommand("git status", "show status", database.Origin{}, nil)
+
+	mux := http.NewServeMux()
+	registerCommandAPI(mux, false)
+
+	rec := commandRequest(t, mux, "POST", "/api/v1/commands/bulk", `{"op":"delete","query":"docker","dry_run":true}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"matched":2`) {
+		t.Fatalf("dry run = %d %s", rec.Code, rec.Body.String())
+	}
+	rec = commandRequest(t, mux, "POST", "/api/v1/commands/bulk", `{"op":"retag","ids":[1,3],"add_tags":["ops"]}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"affected":2`) {
+		t.Errorf("retag = %d %s", rec.Code, rec.Body.String())
+	}
+	rec = commandRequest(t, mux, "POST", "/api/v1/commands/bulk", `{"op":"delete","ids":[1,2]}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"affected":2`) {
+		t.Errorf("delete = %d %s", rec.Code, rec.Body.String())
+	}
+	if _, err := database.GetCommandByID(1); err == nil {
+		t.Error("command 1 still exists after bulk delete")
+	}
+	rec = commandRequest(t, mux, "POST", "/api/v1/commands/bulk", `{"op":"delete"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("empty selection = %d, want 400", rec.Code)
+	}
+
+	readOnly := http.NewServeMux()
+	registerCommandAPI(readOnly, true)
+	rec = commandRequest(t, readOnly, "POST", "/api/v1/commands/bulk", `{"op":"delete","ids":[3]}`)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("read-only delete = %d, want 403", rec.Code)
+	}
+	rec = commandRequest(t, readOnly, "POST", "/api/v1/commands/bulk", `{"op":"export","tag":"ops"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"key":"git status"`) {
+		t.Errorf("read-only export = %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"log"
 	"net/http"
 	"os"
 	"strconv"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/gcclinux/scmd/internal/ai"
 	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/dedupe"
 	"github.com/gcclinux/scmd/internal/updater"
 	"github.com/gcclinux/scmd/internal/util"
 )
@@ -31,6 +34,7 @@ func helpPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data.Version = updater.Release
+	data.CSRFToken = csrfToken(w, r)
 	sc := make([]string, 0)
 
 	if r.Method == "GET" {
@@ -109,8 +113,9 @@ func addPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	remoteAddr := r.RemoteAddr
-	util.WriteLogToFile(util.WebLog, "ADD: "+remoteAddr)
+	util.WriteLogToFile(util.WebLog(), "ADD: "+remoteAddr)
 	data.Version = updater.Release
+	data.CSRFToken = csrfToken(w, r)
 
 	if r.Method == "GET" {
 		tmpl.Execute(w, data)
@@ -119,29 +124,29 @@ func addPage(w http.ResponseWriter, r *http.Request) {
 		var command = r.Form["command"][0]
 		var description = r.Form["description"][0]
 
-		util.WriteLogToFile(util.WebLog, remoteAddr+" : "+command)
+		util.WriteLogToFile(util.WebLog(), remoteAddr+" : "+command)
 
-		save := true
-		status := false
-
-		exists, err := database.CheckCommandExists(command)
-		if err != nil {
-			log.Printf("Error checking command existence: %v", err)
-			data.Status = "(false) Error checking database!"
-		} else if exists {
-			save = false
+		choice := r.FormValue("on_duplicate")
+		out, err := dedupe.Save(choice, command, description, webOrigin(r, database.SourceWeb), ai.GetBestEmbedding)
+		switch {
+		case errors.Is(err, dedupe.ErrExactDuplicate):
 			data.Status = "(false) Duplicate command!"
-		}
-
-		if save {
-			success, err := database.AddCommand(command, description, ai.GetBestEmbedding)
-			if err != nil {
-				log.Printf("Error adding command: %v", err)
-				data.Status = "(false) Error saving command!"
-			} else {
-				status = success
-				data.Status = fmt.Sprintf("%t", status)
-			}
+		case err != nil:
+			logger.Error("adding command", "err", err)
+			data.Status = "(false) Error saving command!"
+		case out.Action == "":
+			data.Duplicates = out.Matches
+			data.DuplicateExact = dedupe.HasExact(out.Matches)
+			data.Status = "(false) Similar commands already exist"
+		case out.Action == dedupe.ActionMerge && out.Changed:
+			util.WriteLogToFile(util.WebLog(), "MERGE: "+strconv.Itoa(out.Matches[0].Record.Id)+" "+remoteAddr)
+			data.Status = fmt.Sprintf("(true) Merged into command ID %d", out.Matches[0].Record.Id)
+		case out.Action == dedupe.ActionMerge:
+			data.Status = fmt.Sprintf("(false) Command ID %d already has this description", out.Matches[0].Record.Id)
+		case out.Action == dedupe.ActionSkip:
+			data.Status = "(false) Skipped, nothing saved"
+		default:
+			data.Status = fmt.Sprintf("%t", out.Changed)
 		}
 
 		data.Return = "Return Status: "
@@ -159,7 +164,7 @@ func homePage(w http.ResponseWriter, r *http.Request) {
 	tmpl := template.Must(template.ParseFS(tplFolder, "templates/home.html"))
 
 	remoteAddr := r.RemoteAddr
-	util.WriteLogToFile(util.WebLog, "HOME: "+remoteAddr)
+	util.WriteLogToFile(util.WebLog(), "HOME: "+remoteAddr)
 
 	data := BuildStruct{
 		PageTitle: "(SCMD)",
@@ -172,7 +177,10 @@ func homePage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data.Version = updater.Release
+	data.CSRFToken = csrfToken(w, r)
 	data.AIProviderLabel = ai.GetProviderLabel()
+	data.Pins = pinnedRecords(r)
+	data.Personas = ai.PersonaList()
 
 	if r.Method == "GET" {
 		tmpl.Execute(w, data)
@@ -183,11 +191,12 @@ func homePage(w http.ResponseWriter, r *http.Request) {
 		if len(pattern) < 3 {
 			tmpl.Execute(w, data)
 		} else {
-			util.WriteLogToFile(util.WebLog, "SEARCH: "+pattern)
+			util.WriteLogToFile(util.WebLog(), "SEARCH: "+pattern)
 
-			results, aiResponse, aiTokens, err := ai.SmartSearch(pattern, true)
+			data.Persona = r.FormValue("persona")
+			results, aiResponse, aiTokens, err := searchWithPersona(data.Persona, pattern, pinnedIDs(r))
 			if err != nil {
-				log.Printf("Error searching commands: %v", err)
+				logger.Error("searching commands", "err", err)
 				data.Pattern = "Error searching database"
 				tmpl.Execute(w, data)
 				return
@@ -209,31 +218,7 @@ func homePage(w http.ResponseWriter, r *http.Request) {
 			}
 
 			for _, record := range results {
-				code := util.IsCode(record.Key)
-				var cmdFormatted string
-
-				if code {
-					funccmd := record.Key
-					if !strings.HasSuffix(funccmd, "{{end}}") {
-						funccmd = util.ReplaceLast(funccmd, "}", "\n}")
-					}
-					funccmd = strings.ReplaceAll(funccmd, "\n\t\n\t", "\n\n\t")
-					cmdFormatted = fmt.Sprintf("```go\n%s\n```", funccmd)
-				} else {
-					cmd := record.Key
-					if strings.Contains(cmd, "```") || strings.Contains(cmd, "\n") {
-						if strings.Contains(cmd, "```") {
-							cmdFormatted = cmd
-						} else {
-							cmdFormatted = fmt.Sprintf("```\n%s\n```", cmd)
-						}
-					} else {
-						cmdFormatted = fmt.Sprintf("```\n%s\n```", cmd)
-					}
-				}
-
-				pageContent := fmt.Sprintf("## ID: %d Description\n%s\n\n## Command\n\n%s", record.Id, record.Data, cmdFormatted)
-				pages = append(pages, pageContent)
+				pages = append(pages, recordPage(record))
 			}
 
 			data.Pages = pages
@@ -244,18 +229,42 @@ func homePage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// searchWithPersona runs a search from the home page. Without a persona it
+// is a SmartSearch; with one the persona's context records are looked up
+// and the AI is always asked through it.
+func searchWithPersona(key, pattern string, pinned map[int]bool) ([]database.CommandRecord, string, int, error) {
+	if key == "" {
+		return ai.SmartSearch(pattern, true, pinned)
+	}
+	persona, ok := ai.LookupPersona(key)
+	if !ok {
+		return nil, "", 0, fmt.Errorf("persona '%s' not found", key)
+	}
+	results, err := ai.PersonaContext(persona, pattern, pinned)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	answer, tokens, err := ai.AskAIPersona(persona.Key, pattern, results)
+	if err != nil {
+		// The matched records are still worth showing.
+		logger.Warn("asking persona", "persona", persona.Key, "err", err)
+	}
+	return results, answer, tokens, nil
+}
+
 func gamePage(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
 	tmpl := template.Must(template.ParseFS(tplFolder, "templates/game.html"))
 
 	remoteAddr := r.RemoteAddr
-	util.WriteLogToFile(util.WebLog, "GAME: "+remoteAddr)
+	util.WriteLogToFile(util.WebLog(), "GAME: "+remoteAddr)
 
 	data := BuildStruct{
 		PageTitle: "(GAME)",
 	}
 
 	data.Version = updater.Release
+	data.CSRFToken = csrfToken(w, r)
 
 	if os.Args[len(os.Args)-1] == "-block" {
 		data.Insert = false
@@ -265,12 +274,10 @@ func gamePage(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == "GET" {
 		tmpl.Execute(w, data)
-		log.Println("Test 1")
 	} else {
-		log.Println("Test 2")
 		r.ParseForm()
 		var commands = r.Form["commands"][0]
-		util.WriteLogToFile(util.WebLog, "SEARCH: "+commands)
+		util.WriteLogToFile(util.WebLog(), "SEARCH: "+commands)
 	}
 }
 
@@ -289,6 +296,7 @@ func answerFeedback(w http.ResponseWriter, r *http.Request) {
 		PageTitle: "(SCMD)",
 		Version:   updater.Release,
 	}
+	data.CSRFToken = csrfToken(w, r)
 	if os.Args[len(os.Args)-1] == "-block" {
 		data.Insert = false
 	} else {
@@ -300,11 +308,25 @@ func answerFeedback(w http.ResponseWriter, r *http.Request) {
 		if strings.HasPrefix(strings.TrimSpace(aiResponse), "## ID:") {
 			data.SaveStatus = "already"
 		} else {
-			success, err := database.AddCommand(query, aiResponse, ai.GetBestEmbedding)
-			if err != nil || !success {
-				log.Printf("Error saving AI response: %v", err)
+			out, err := dedupe.Save(r.FormValue("on_duplicate"), query, aiResponse, webOrigin(r, database.SourceAI), ai.GetBestEmbedding)
+			switch {
+			case errors.Is(err, dedupe.ErrExactDuplicate):
+				data.SaveStatus = "already"
+			case err != nil:
+				logger.Error("saving AI response", "err", err)
 				data.SaveStatus = "error"
-			} else {
+			case out.Action == "":
+				data.SaveStatus = "duplicate"
+				data.Duplicates = out.Matches
+				data.DuplicateExact = dedupe.HasExact(out.Matches)
+			case out.Action == dedupe.ActionMerge:
+				data.SaveStatus = "merged"
+				data.Id = out.Matches[0].Record.Id
+			case out.Action == dedupe.ActionSkip:
+				data.SaveStatus = "skipped"
+			case !out.Changed:
+				data.SaveStatus = "error"
+			default:
 				data.SaveStatus = "saved"
 			}
 		}
@@ -315,7 +337,7 @@ func answerFeedback(w http.ResponseWriter, r *http.Request) {
 		tmpl.Execute(w, data)
 
 	case "retry":
-		results, newResponse, aiTokens, err := ai.SmartSearch(query, true)
+		results, newResponse, aiTokens, err := ai.SmartSearch(query, true, pinnedIDs(r))
 		_ = results
 		if err != nil || newResponse == "" {
 			data.Pattern = "Could not find a better answer"
@@ -351,6 +373,7 @@ func loginPage(w http.ResponseWriter, r *http.Request) {
 		PageTitle: "Login",
 		Version:   updater.Release,
 	}
+	data.CSRFToken = csrfToken(w, r)
 
 	if r.Method == "GET" {
 		tmpl.Execute(w, data)
@@ -361,14 +384,14 @@ func loginPage(w http.ResponseWriter, r *http.Request) {
 
 		authenticated, err := authenticateUser(email, apiKey)
 		if err != nil {
-			log.Printf("Authentication error: %v", err)
+			logger.Error("authentication failed", "err", err)
 			data.Status = "error"
 			tmpl.Execute(w, data)
 			return
 		}
 
 		if !authenticated {
-			log.Printf("Failed login attempt for email: %s", email)
+			logger.Warn("failed login attempt", "email", email, "remote", r.RemoteAddr)
 			data.Status = "failed"
 			tmpl.Execute(w, data)
 			return
@@ -376,23 +399,15 @@ func loginPage(w http.ResponseWriter, r *http.Request) {
 
 		sessionID, err := sessionStore.CreateSession(email)
 		if err != nil {
-			log.Printf("Session creation error: %v", err)
+			logger.Error("creating session", "err", err)
 			data.Status = "error"
 			tmpl.Execute(w, data)
 			return
 		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session_id",
-			Value:    sessionID,
-			Path:     "/",
-			MaxAge:   86400,
-			HttpOnly: true,
-			Secure:   false,
-			SameSite: http.SameSiteLaxMode,
-		})
-
-		log.Printf("Successful login for email: %s", email)
+		setSessionCookie(w, r, sessionID)
+
+		logger.Info("successful login", "email", email)
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 	}
 }
@@ -423,6 +438,7 @@ func storedPage(w http.ResponseWriter, r *http.Request) {
 		PageTitle: "(SCMD)",
 		Version:   updater.Release,
 	}
+	data.CSRFToken = csrfToken(w, r)
 	if os.Args[len(os.Args)-1] == "-block" {
 		data.Insert = false
 	} else {
@@ -432,26 +448,123 @@ func storedPage(w http.ResponseWriter, r *http.Request) {
 	tmpl.Execute(w, data)
 }
 
-// storedAPIPage returns all stored commands as JSON (used by the stored page via fetch).
+// storedAPIPage returns one page of stored commands as JSON (used by the
+// stored page via fetch). Query parameters:
+//
+//	limit   page size (default 50, max 500)
+//	offset  records to skip, for numbered pages
+//	cursor  next_cursor from the previous response, for infinite scrolling
+//	sort    id (default), created, updated or usage
+//	order   asc (default) or desc
+//	q       case-insensitive text filter on command and description
+//	tag     only commands carrying this tag
+//
+// Responses carry an ETag; a matching If-None-Match returns 304.
 func storedAPIPage(w http.ResponseWriter, r *http.Request) {
-	records, err := database.ListAllCommands()
+	q := r.URL.Query()
+	opts := database.ListOptions{
+		Cursor: q.Get("cursor"),
+		Sort:   q.Get("sort"),
+		Desc:   strings.EqualFold(q.Get("order"), "desc"),
+		Query:  q.Get("q"),
+		Tag:    q.Get("tag"),
+	}
+	for name, dst := range map[string]*int{"limit": &opts.Limit, "offset": &opts.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeJSONError(w, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*dst = n
+		}
+	}
+
+	page, err := database.ListCommands(opts)
 	if err != nil {
-		log.Printf("Error listing commands: %v", err)
-		http.Error(w, `{"error":"failed to list commands"}`, http.StatusInternalServerError)
+		if errors.Is(err, database.ErrInvalidListOptions) {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.Error("listing commands", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to list commands")
 		return
 	}
+	records := page.Records
 	if records == nil {
 		records = []database.CommandRecord{}
 	}
-	w.Header().Set("Content-Type", "application/json")
-	enc := struct {
-		Total   int                      `json:"total"`
-		Records []database.CommandRecord `json:"records"`
+	body, err := json.Marshal(struct {
+		Total      int                      `json:"total"`
+		NextCursor string                   `json:"next_cursor,omitempty"`
+		Records    []database.CommandRecord `json:"records"`
 	}{
-		Total:   len(records),
-		Records: records,
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+		Records:    records,
+	})
+	if err != nil {
+		logger.Error("encoding commands", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to encode commands")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:12]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison RFC 9110 requires for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
 	}
-	if err := json.NewEncoder(w).Encode(enc); err != nil {
-		log.Printf("Error encoding commands: %v", err)
+	return false
+}
+
+// writeJSONError writes {"error": msg} with the given status.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// recordPage formats a command record as a markdown result page.
+func recordPage(record database.CommandRecord) string {
+	code := util.IsCode(record.Key)
+	var cmdFormatted string
+
+	if code {
+		funccmd := record.Key
+		if !strings.HasSuffix(funccmd, "{{end}}") {
+			funccmd = util.ReplaceLast(funccmd, "}", "\n}")
+		}
+		funccmd = strings.ReplaceAll(funccmd, "\n\t\n\t", "\n\n\t")
+		cmdFormatted = fmt.Sprintf("```go\n%s\n```", funccmd)
+	} else {
+		cmd := record.Key
+		if strings.Contains(cmd, "```") || strings.Contains(cmd, "\n") {
+			if strings.Contains(cmd, "```") {
+				cmdFormatted = cmd
+			} else {
+				cmdFormatted = fmt.Sprintf("```\n%s\n```", cmd)
+			}
+		} else {
+			cmdFormatted = fmt.Sprintf("```\n%s\n```", cmd)
+		}
 	}
+
+	return fmt.Sprintf("## ID: %d Description\n%s\n\n## Command\n\n%s", record.Id, record.Data, cmdFormatted)
 }
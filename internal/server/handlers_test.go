@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/logging"
+)
+
+// setupTestDB points HOME at a temporary directory and opens a fresh SQLite
+// database there.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DB_TYPE", "sqlite")
+	t.Setenv("WEB_LOG", filepath.Join(home, "scmdweb.log"))
+	t.Cleanup(logging.CloseFiles)
+	if err := os.MkdirAll(filepath.Join(home, ".scmd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InitSQLiteDB(); err != nil {
+		t.Fatalf("InitSQLiteDB: %v", err)
+	}
+	t.Cleanup(database.CloseDB)
+}
+
+type storedResponse struct {
+	Total      int                      `json:"total"`
+	NextCursor string                   `json:"next_cursor"`
+	Records    []database.CommandRecord `json:"records"`
+}
+
+func getStored(t *testing.T, query string, header http.Header) (*httptest.ResponseRecorder, storedResponse) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/api/stored"+query, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	storedAPIPage(rec, req)
+
+	var resp storedResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v\n%s", err, rec.Body.String())
+		}
+	}
+	return rec, resp
+}
+
+func TestStoredAPI_PagingAndFilters(t *testing.T) {
+	setupTestDB(t)
+	for i := 1; i <= 5; i++ {
+		database.AddCommand(fmt.Sprintf("docker cmd %d", i), "desc", database.Origin{}, nil)
+	}
+	database.AddCommand("ls -la", "list files", database.Origin{}, nil)
+	database.SetTags(6, []string{"shell"}, database.Origin{})
+
+	_, resp := getStored(t, "?limit=2&order=desc", nil)
+	if resp.Total != 6 || len(resp.Records) != 2 || resp.Records[0].Id != 6 || resp.NextCursor == "" {
+		t.Fatalf("first page = %+v", resp)
+	}
+	_, next := getStored(t, "?limit=2&order=desc&cursor="+resp.NextCursor, nil)
+	if len(next.Records) != 2 || next.Records[0].Id != 4 {
+		t.Errorf("cursor page = %+v", next)
+	}
+
+	if _, resp = getStored(t, "?q=docker&offset=4", nil); resp.Total != 5 || len(resp.Records) != 1 {
+		t.Errorf("filtered page = %+v", resp)
+	}
+	if _, resp = getStored(t, "?tag=shell", nil); resp.Total != 1 || resp.Records[0].Key != "ls -la" {
+		t.Errorf("tag page = %+v", resp)
+	}
+}
+
+func TestStoredAPI_ETag(t *testing.T) {
+	setupTestDB(t)
+	database.AddCommand("pwd", "print directory", database.Origin{}, nil)
+
+	rec, _ := getStored(t, "", nil)
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+	rec, _ = getStored(t, "", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("conditional GET = %d with %d bytes, want 304", rec.Code, rec.Body.Len())
+	}
+
+	database.AddCommand("whoami", "current user", database.Origin{}, nil)
+	rec, _ = getStored(t, "", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusOK {
+		t.Errorf("changed data returned %d, want 200", rec.Code)
+	}
+}
+
+func TestStoredAPI_BadParameters(t *testing.T) {
+	setupTestDB(t)
+	for _, q := range []string{"?limit=x", "?offset=-1", "?sort=random", "?cursor=%25%25"} {
+		if rec, _ := getStored(t, q, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/config"
+	"github.com/gcclinux/scmd/internal/tlscert"
+	"github.com/gcclinux/scmd/internal/util"
+)
+
+// Default HTTP server timeouts. The write timeout is generous because search
+// requests may wait on a local model to produce an answer.
+const (
+	defaultReadTimeout     = 30 * time.Second
+	defaultWriteTimeout    = 5 * time.Minute
+	defaultIdleTimeout     = 2 * time.Minute
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+// listenAddr returns the address to listen on for port, using web_bind as
+// the host. An empty bind address listens on all interfaces.
+func listenAddr(port int) string {
+	return net.JoinHostPort(strings.TrimSpace(os.Getenv("WEB_BIND")), strconv.Itoa(port))
+}
+
+// newHTTPServer builds the web server with the configured timeouts.
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       config.ParseDuration(os.Getenv("WEB_READ_TIMEOUT"), defaultReadTimeout),
+		WriteTimeout:      config.ParseDuration(os.Getenv("WEB_WRITE_TIMEOUT"), defaultWriteTimeout),
+		IdleTimeout:       config.ParseDuration(os.Getenv("WEB_IDLE_TIMEOUT"), defaultIdleTimeout),
+	}
+}
+
+// browserURL returns the URL to open in the browser for the server at addr.
+func browserURL(addr string, ssl bool) string {
+	scheme := "http"
+	if ssl {
+		scheme = "https"
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	ip := net.ParseIP(host)
+	if host == "" || (ip != nil && ip.IsUnspecified()) {
+		if out, err := util.OutboundIP(); err == nil {
+			host = out.String()
+		} else {
+			host = "localhost"
+		}
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, port))
+}
+
+// serve runs srv until SIGINT or SIGTERM is received, then stops accepting
+// connections and waits up to web_shutdown_timeout for in-flight requests to
+// finish. With ssl, the crt and key files are loaded first unless
+// srv.TLSConfig already provides certificates. onReady is called once the
+// listening socket is open and the certificate is loaded.
+func serve(srv *http.Server, ssl bool, crt, key string, onReady func()) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if ssl && (crt != "" || key != "") {
+		cert, err := tls.LoadX509KeyPair(crt, key)
+		if err != nil {
+			return fmt.Errorf("load TLS certificate: %v", err)
+		}
+		if srv.TLSConfig == nil {
+			srv.TLSConfig = &tls.Config{}
+		}
+		srv.TLSConfig.Certificates = append(srv.TLSConfig.Certificates, cert)
+	}
+
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %v", srv.Addr, err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if ssl {
+			errCh <- srv.ServeTLS(ln, "", "")
+		} else {
+			errCh <- srv.Serve(ln)
+		}
+	}()
+
+	if onReady != nil {
+		onReady()
+	}
+	if err := sdNotify("READY=1"); err != nil {
+		logger.Warn("systemd notification failed", "err", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down web server")
+	sdNotify("STOPPING=1")
+
+	timeout := config.ParseDuration(os.Getenv("WEB_SHUTDOWN_TIMEOUT"), defaultShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("graceful shutdown: %v", err)
+	}
+	return nil
+}
+
+// sdNotify sends state to the systemd notification socket named by
+// NOTIFY_SOCKET. It is a no-op when the process is not run by systemd with
+// Type=notify.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading @ denotes a socket in the Linux abstract namespace.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// tlsMode returns the configured TLS mode (tls_mode or --tls).
+func tlsMode() string {
+	return strings.ToLower(strings.TrimSpace(os.Getenv("TLS_MODE")))
+}
+
+// autoTLSConfig prepares a self-signed certificate in ~/.scmd/tls covering
+// localhost, this host and its outbound address, and prints the fingerprints
+// so users can verify or pin the certificate.
+func autoTLSConfig() (*tls.Config, error) {
+	var extra []string
+	if ip, err := util.OutboundIP(); err == nil {
+		extra = append(extra, ip.String())
+	}
+	if bind := strings.TrimSpace(os.Getenv("WEB_BIND")); bind != "" {
+		if ip := net.ParseIP(bind); ip == nil || !ip.IsUnspecified() {
+			extra = append(extra, bind)
+		}
+	}
+
+	mgr, err := tlscert.NewManager(filepath.Join(config.ConfigDir(), "tls"), tlscert.DefaultHosts(extra...))
+	if err != nil {
+		return nil, err
+	}
+
+	b := mgr.Bundle()
+	if b.Renewed {
+		fmt.Println("Generated TLS certificate in", filepath.Dir(b.CertFile))
+	}
+	fmt.Println("TLS certificate hosts: ", strings.Join(b.Hosts, ", "))
+	fmt.Println("TLS certificate expires:", b.NotAfter.Format("2006-01-02"))
+	fmt.Println("Server SHA-256 fingerprint:", b.Fingerprint)
+	fmt.Println("CA SHA-256 fingerprint:    ", b.CAFingerprint)
+	fmt.Println("Trust", b.CAFile, "in your browser to avoid certificate warnings.")
+	return mgr.TLSConfig(), nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListenAddr(t *testing.T) {
+	t.Setenv("WEB_BIND", "")
+	if got := listenAddr(3333); got != ":3333" {
+		t.Errorf("listenAddr = %q, want %q", got, ":3333")
+	}
+	t.Setenv("WEB_BIND", "127.0.0.1")
+	if got := listenAddr(8080); got != "127.0.0.1:8080" {
+		t.Errorf("listenAddr = %q, want %q", got, "127.0.0.1:8080")
+	}
+	t.Setenv("WEB_BIND", "::1")
+	if got := listenAddr(8080); got != "[::1]:8080" {
+		t.Errorf("listenAddr = %q, want %q", got, "[::1]:8080")
+	}
+}
+
+func TestNewHTTPServer_Timeouts(t *testing.T) {
+	t.Setenv("WEB_READ_TIMEOUT", "5s")
+	t.Setenv("WEB_WRITE_TIMEOUT", "")
+	srv := newHTTPServer(":0", nil)
+	if srv.ReadTimeout != 5*time.Second {
+		t.Errorf("ReadTimeout = %v, want 5s", srv.ReadTimeout)
+	}
+	if srv.WriteTimeout != defaultWriteTimeout {
+		t.Errorf("WriteTimeout = %v, want %v", srv.WriteTimeout, defaultWriteTimeout)
+	}
+}
+
+func TestSDNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("sdNotify without socket: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("sdNotify: %v", err)
+	}
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := string(buf[:n]); got != "READY=1" {
+		t.Errorf("received %q, want %q", got, "READY=1")
+	}
+}
+
+func TestServe_BadCertificateIsNotReady(t *testing.T) {
+	dir := t.TempDir()
+	srv := newHTTPServer("127.0.0.1:0", nil)
+	ready := false
+	err := serve(srv, true, filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key"), func() { ready = true })
+	if err == nil || !strings.Contains(err.Error(), "load TLS certificate") {
+		t.Errorf("serve = %v, want a certificate error", err)
+	}
+	if ready {
+		t.Error("onReady called although the certificate could not be loaded")
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/config"
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/metrics"
+)
+
+var _ = metrics.NewGaugeFunc("scmd_database_size_bytes",
+	"Size of the SQLite database file including its write-ahead log.",
+	func() (float64, bool) {
+		size, err := database.SizeBytes()
+		if err != nil {
+			return 0, false
+		}
+		return float64(size), true
+	})
+
+// metricsEnabled reports whether /metrics is served (metrics_enabled).
+func metricsEnabled() bool {
+	return config.GetBool("METRICS_ENABLED", true)
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, which
+// the streaming endpoint needs for flushing and deadlines.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// instrument records request counts and latencies. Requests are labelled
+// with the ServeMux pattern that handled them rather than the raw path, so
+// the number of series stays bounded.
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.HTTPRequests.Inc(route, r.Method, strconv.Itoa(status))
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/metrics"
+)
+
+func TestInstrument_LabelsByPattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := instrument(mux)
+
+	before := metrics.HTTPRequests.Value("GET /items/{id}", "GET", "418")
+	for _, path := range []string{"/items/1", "/items/2"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if got := metrics.HTTPRequests.Value("GET /items/{id}", "GET", "418"); got != before+2 {
+		t.Errorf("requests = %v, want %v", got, before+2)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+	if got := metrics.HTTPRequests.Value("unmatched", "GET", "404"); got < 1 {
+		t.Errorf("unmatched requests = %v, want >= 1", got)
+	}
+}
+
+func TestStatusRecorder_Unwrap(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sr := &statusRecorder{ResponseWriter: rec}
+	if err := http.NewResponseController(sr).Flush(); err != nil {
+		t.Errorf("Flush through statusRecorder: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/util"
+)
+
+// anonymousPinOwner holds the pins shared by visitors who are not logged
+// in.
+const anonymousPinOwner = "web"
+
+// registerPinAPI registers the pinned commands endpoints. Read-only servers
+// (-block) show pins but cannot change them.
+func registerPinAPI(mux *http.ServeMux, readOnly bool) {
+	mux.HandleFunc("GET /api/v1/pins", listPinsAPI)
+	if readOnly {
+		return
+	}
+	mux.HandleFunc("PUT /api/v1/pins/{id}", pinCommandAPI)
+	mux.HandleFunc("DELETE /api/v1/pins/{id}", unpinCommandAPI)
+}
+
+// pinOwner returns whose pins a request sees: the logged-in user, or the
+// shared anonymous set when nobody is logged in.
+func pinOwner(r *http.Request) string {
+	if sessionStore != nil {
+		if session := currentSession(r); session != nil {
+			return database.UserPinOwner(session.Email)
+		}
+	}
+	return anonymousPinOwner
+}
+
+// pinnedIDs returns the IDs pinned by the request's owner for search
+// ranking. Failures only lose the boost.
+func pinnedIDs(r *http.Request) map[int]bool {
+	ids, err := database.PinnedIDs(pinOwner(r))
+	if err != nil {
+		logger.Debug("loading pins", "err", err)
+	}
+	return ids
+}
+
+// pinnedRecords returns the commands pinned by the request's owner, or nil
+// when there are none or they cannot be loaded.
+func pinnedRecords(r *http.Request) []database.CommandRecord {
+	if database.IsMCP() {
+		return nil
+	}
+	records, err := database.ListPins(pinOwner(r))
+	if err != nil {
+		logger.Debug("loading pins", "err", err)
+	}
+	return records
+}
+
+// listPinsAPI returns the caller's pinned commands, oldest pin first.
+func listPinsAPI(w http.ResponseWriter, r *http.Request) {
+	records, err := database.ListPins(pinOwner(r))
+	if err != nil {
+		logger.Error("listing pins", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if records == nil {
+		records = []database.CommandRecord{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"records": records, "max": database.MaxPins})
+}
+
+// pinCommandAPI pins a command for the caller.
+func pinCommandAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	added, err := database.PinCommand(pinOwner(r), id)
+	switch {
+	case errors.Is(err, database.ErrCommandNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, database.ErrTooManyPins):
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		logger.Error("pinning command", "id", id, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to pin command")
+		return
+	}
+	if added {
+		util.WriteLogToFile(util.WebLog(), fmt.Sprintf("PIN: %d %s", id, r.RemoteAddr))
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"pinned": true})
+}
+
+// unpinCommandAPI removes one of the caller's pins.
+func unpinCommandAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	removed, err := database.UnpinCommand(pinOwner(r), id)
+	if err != nil {
+		logger.Error("unpinning command", "id", id, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to unpin command")
+		return
+	}
+	if !removed {
+		writeJSONError(w, http.StatusNotFound, "command not pinned")
+		return
+	}
+	util.WriteLogToFile(util.WebLog(), fmt.Sprintf("UNPIN: %d %s", id, r.RemoteAddr))
+	writeJSON(w, http.StatusOK, map[string]bool{"pinned": false})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func TestPinAPI(t *testing.T) {
+	setupTestDB(t)
+	database.AddCommand("ls", "list", database.Origin{}, nil)
+	database.AddCommand("pwd", "print directory", database.Origin{}, nil)
+
+	mux := http.NewServeMux()
+	registerPinAPI(mux, false)
+
+	if rec := commandRequest(t, mux, "PUT", "/api/v1/pins/2", ""); rec.Code != http.StatusOK {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := commandRequest(t, mux, "PUT", "/api/v1/pins/9", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("PUT unknown = %d, want 404", rec.Code)
+	}
+
+	rec := commandRequest(t, mux, "GET", "/api/v1/pins", "")
+	var list struct {
+		Records []database.CommandRecord `json:"records"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || len(list.Records) != 1 || list.Records[0].Key != "pwd" {
+		t.Fatalf("GET = %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := commandRequest(t, mux, "DELETE", "/api/v1/pins/2", ""); rec.Code != http.StatusOK {
+		t.Errorf("DELETE = %d", rec.Code)
+	}
+	if rec := commandRequest(t, mux, "DELETE", "/api/v1/pins/2", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second DELETE = %d, want 404", rec.Code)
+	}
+
+	ro := http.NewServeMux()
+	registerPinAPI(ro, true)
+	if rec := commandRequest(t, ro, "GET", "/api/v1/pins", ""); rec.Code != http.StatusOK {
+		t.Errorf("read-only GET = %d", rec.Code)
+	}
+	if rec := commandRequest(t, ro, "PUT", "/api/v1/pins/1", ""); rec.Code == http.StatusOK {
+		t.Error("read-only server accepted a pin")
+	}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gcclinux/scmd/internal/ai"
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/diff"
+	"github.com/gcclinux/scmd/internal/util"
+)
+
+// registerRevisionAPI registers the revision history endpoints. Read-only
+// servers (-block) can browse history but not revert.
+func registerRevisionAPI(mux *http.ServeMux, readOnly bool) {
+	mux.HandleFunc("GET /api/v1/commands/{id}/revisions", listRevisionsAPI)
+	mux.HandleFunc("GET /api/v1/commands/{id}/revisions/{rev}", getRevisionAPI)
+	mux.HandleFunc("GET /api/v1/commands/{id}/diff", diffRevisionsAPI)
+	if readOnly {
+		return
+	}
+	mux.HandleFunc("POST /api/v1/commands/{id}/revisions/{rev}/revert", revertCommandAPI)
+}
+
+// revisionNumber parses the {rev} path value, writing a 400 response when
+// it is not a positive integer.
+func revisionNumber(w http.ResponseWriter, r *http.Request) (int, bool) {
+	rev, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil || rev <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid revision")
+		return 0, false
+	}
+	return rev, true
+}
+
+// listRevisionsAPI returns every revision of a command, oldest first.
+func listRevisionsAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	revs, err := database.ListRevisions(id)
+	if err != nil {
+		logger.Error("listing revisions", "id", id, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if revs == nil {
+		revs = []database.Revision{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"revisions": revs})
+}
+
+// getRevisionAPI returns one revision.
+func getRevisionAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	rev, ok := revisionNumber(w, r)
+	if !ok {
+		return
+	}
+	revision, err := database.GetRevision(id, rev)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "revision not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, revision)
+}
+
+// revisionDiff is the GET /api/v1/commands/{id}/diff response.
+type revisionDiff struct {
+	From int         `json:"from"`
+	To   int         `json:"to"`
+	Key  []diff.Line `json:"key"`
+	Data []diff.Line `json:"data"`
+	Tags []diff.Line `json:"tags"`
+}
+
+// diffRevisionsAPI compares two revisions given by the from and to query
+// parameters, defaulting to the latest two.
+func diffRevisionsAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	revs, err := database.ListRevisions(id)
+	if err != nil {
+		logger.Error("listing revisions", "id", id, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(revs) == 0 {
+		writeJSONError(w, http.StatusNotFound, "command has no revisions")
+		return
+	}
+
+	from, to := revs[max(0, len(revs)-2)].Rev, revs[len(revs)-1].Rev
+	for name, dst := range map[string]*int{"from": &from, "to": &to} {
+		if v := r.URL.Query().Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s revision", name))
+				return
+			}
+			*dst = n
+		}
+	}
+
+	var a, b *database.Revision
+	for i := range revs {
+		if revs[i].Rev == from {
+			a = &revs[i]
+		}
+		if revs[i].Rev == to {
+			b = &revs[i]
+		}
+	}
+	if a == nil || b == nil {
+		writeJSONError(w, http.StatusNotFound, "revision not found")
+		return
+	}
+	out := revisionDiff{From: from, To: to}
+	for _, part := range []struct {
+		dst  *[]diff.Line
+		a, b string
+	}{
+		{&out.Key, a.Key, b.Key},
+		{&out.Data, a.Data, b.Data},
+		{&out.Tags, strings.Join(a.Tags, "\n"), strings.Join(b.Tags, "\n")},
+	} {
+		lines, err := diff.Lines(part.a, part.b)
+		if err != nil {
+			writeJSONError(w, http.StatusUnprocessableEntity, "revisions too large to diff")
+			return
+		}
+		*part.dst = lines
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// revertCommandAPI restores a command from an earlier revision and returns
+// the updated command.
+func revertCommandAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	rev, ok := revisionNumber(w, r)
+	if !ok {
+		return
+	}
+	if _, err := database.GetRevision(id, rev); err != nil {
+		writeJSONError(w, http.StatusNotFound, "revision not found")
+		return
+	}
+	err := database.RevertCommand(id, rev, webOrigin(r, database.SourceWeb), ai.GetBestEmbedding)
+	if errors.Is(err, database.ErrCommandExists) {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error("reverting command", "id", id, "rev", rev, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to revert command")
+		return
+	}
+	util.WriteLogToFile(util.WebLog(), fmt.Sprintf("REVERT: %d to rev %d %s", id, rev, r.RemoteAddr))
+
+	record, err := database.GetCommandByID(id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to reload command")
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func TestRevisionAPI(t *testing.T) {
+	setupTestDB(t)
+	database.AddCommand("ls", "list", database.Origin{Author: "alice", Source: database.SourceCLI}, nil)
+
+	mux := http.NewServeMux()
+	registerCommandAPI(mux, false)
+	registerRevisionAPI(mux, false)
+
+	if rec := commandRequest(t, mux, "PUT", "/api/v1/commands/1", `{"key":"ls -la","data":"list\nall files"}`); rec.Code != http.StatusOK {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec := commandRequest(t, mux, "GET", "/api/v1/commands/1/revisions", "")
+	var list struct {
+		Revisions []database.Revision `json:"revisions"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || len(list.Revisions) != 2 {
+		t.Fatalf("revisions = %d %s", rec.Code, rec.Body.String())
+	}
+	if r := list.Revisions[1]; r.Source != database.SourceWeb || r.Author == "" {
+		t.Errorf("web revision = %+v", r)
+	}
+
+	rec = commandRequest(t, mux, "GET", "/api/v1/commands/1/diff", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `{"op":"+","text":"all files"}`) {
+		t.Errorf("diff = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec = commandRequest(t, mux, "GET", "/api/v1/commands/1/diff?from=7", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("diff from missing rev = %d, want 404", rec.Code)
+	}
+
+	rec = commandRequest(t, mux, "POST", "/api/v1/commands/1/revisions/1/revert", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"key":"ls"`) {
+		t.Errorf("revert = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec = commandRequest(t, mux, "GET", "/api/v1/commands/1/revisions/3", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET rev 3 = %d, want 200", rec.Code)
+	}
+
+	readOnly := http.NewServeMux()
+	registerRevisionAPI(readOnly, true)
+	if rec = commandRequest(t, readOnly, "POST", "/api/v1/commands/1/revisions/1/revert", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("read-only revert = %d, want 404", rec.Code)
+	}
+}
+
+func TestRevisionAPI_DiffTooLarge(t *testing.T) {
+	setupTestDB(t)
+	database.AddCommand("ls", "list", database.Origin{Author: "alice", Source: database.SourceCLI}, nil)
+
+	mux := http.NewServeMux()
+	registerCommandAPI(mux, false)
+	registerRevisionAPI(mux, false)
+
+	for _, prefix := range []string{"a", "b"} {
+		lines := make([]string, 3000)
+		for i := range lines {
+			lines[i] = prefix + strconv.Itoa(i)
+		}
+		body, _ := json.Marshal(map[string]string{"key": "ls", "data": strings.Join(lines, "\n")})
+		if rec := commandRequest(t, mux, "PUT", "/api/v1/commands/1", string(body)); rec.Code != http.StatusOK {
+			t.Fatalf("PUT = %d %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := commandRequest(t, mux, "GET", "/api/v1/commands/1/diff", "")
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "too large to diff") {
+		t.Errorf("diff = %d %s, want 422", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestCSRFProtect_AllowsSafeMethods(t *testing.T) {
+	rec := httptest.NewRecorder()
+	csrfProtect(okHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET status = %d, want 200", rec.Code)
+	}
+}
+
+func TestCSRFProtect_RejectsMissingToken(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("pattern=ls"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "abc"})
+
+	rec := httptest.NewRecorder()
+	csrfProtect(okHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", rec.Code)
+	}
+}
+
+func TestCSRFProtect_AcceptsCookieToken(t *testing.T) {
+	form := url.Values{"pattern": {"ls"}, csrfFieldName: {"abc"}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "abc"})
+
+	rec := httptest.NewRecorder()
+	csrfProtect(okHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestCSRFProtect_UsesSessionToken(t *testing.T) {
+	prev := sessionStore
+	store := NewMemorySessionStore(time.Hour, false)
+	sessionStore = store
+	defer func() { sessionStore = prev }()
+
+	id, _ := store.CreateSession("dev@example.com")
+	session, _ := store.GetSession(id)
+
+	// A cookie token does not satisfy the check once a session exists.
+	req := httptest.NewRequest(http.MethodDelete, "/api/x", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: id})
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "abc"})
+	req.Header.Set(csrfHeaderName, "abc")
+	rec := httptest.NewRecorder()
+	csrfProtect(okHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("cookie token status = %d, want 403", rec.Code)
+	}
+
+	req.Header.Set(csrfHeaderName, session.CSRFToken)
+	rec = httptest.NewRecorder()
+	csrfProtect(okHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("session token status = %d, want 200", rec.Code)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	securityHeaders(okHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	for _, h := range []string{"Content-Security-Policy", "X-Frame-Options", "X-Content-Type-Options", "Referrer-Policy"} {
+		if rec.Header().Get(h) == "" {
+			t.Errorf("missing header %s", h)
+		}
+	}
+	if rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS should only be sent over TLS")
+	}
+}
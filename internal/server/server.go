@@ -1,19 +1,24 @@
 package server
 
 import (
+	"crypto/tls"
 	"embed"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
-	"sync"
 
 	"github.com/gcclinux/scmd/internal/ai"
 	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/dedupe"
+	"github.com/gcclinux/scmd/internal/logging"
+	"github.com/gcclinux/scmd/internal/metrics"
 	"github.com/gcclinux/scmd/internal/util"
 )
 
+var logger = logging.For(logging.Web)
+
 // BuildStruct holds template data for web pages.
 type BuildStruct struct {
 	PageTitle       string
@@ -35,6 +40,12 @@ type BuildStruct struct {
 	PageQuery       string
 	SaveStatus      string
 	AIProviderLabel string
+	CSRFToken       string
+	Pins            []database.CommandRecord
+	Duplicates      []dedupe.Match
+	DuplicateExact  bool
+	Personas        []ai.AIPersona
+	Persona         string // key of the selected persona
 }
 
 var tplFolder embed.FS
@@ -53,8 +64,11 @@ func Routes() {
 
 	ai.InitProviders()
 
-	wg := new(sync.WaitGroup)
-	wg.Add(2)
+	sessionStore = newSessionStore()
+	stopCleanup := StartSessionCleanup(sessionStore)
+	defer stopCleanup()
+	stopPurge := StartTrashPurge()
+	defer stopPurge()
 
 	HTTP := 3333
 	browser := true
@@ -238,42 +252,54 @@ func Routes() {
 	http.HandleFunc("/help", helpPage)
 	http.HandleFunc("/stored", storedPage)
 	http.HandleFunc("/api/stored", storedAPIPage)
+	registerCommandAPI(http.DefaultServeMux, os.Args[count-1] == "-block")
+	registerTrashAPI(http.DefaultServeMux, os.Args[count-1] == "-block")
+	registerRevisionAPI(http.DefaultServeMux, os.Args[count-1] == "-block")
+	registerUsageAPI(http.DefaultServeMux)
+	registerPinAPI(http.DefaultServeMux, os.Args[count-1] == "-block")
+	http.HandleFunc("/api/v1/ask/stream", askStreamAPI)
+	if metricsEnabled() {
+		http.Handle("/metrics", metrics.Handler())
+	}
 	http.HandleFunc("/answer-feedback", answerFeedback)
+	http.HandleFunc("/login", loginPage)
+	http.HandleFunc("/logout", logoutPage)
 
-	if browser {
-		if SSL {
-			log.Println("Starting scmd web HTTPS UI on port", HTTP)
-			util.OpenBrowser(fmt.Sprintf("https://%s:%v", util.GetOutboundIP(), HTTP))
-			err := http.ListenAndServeTLS(fmt.Sprintf(":%v", HTTP), CRT, KEY, nil)
-			if err != nil {
-				log.Println(err)
-			}
-		} else {
-			log.Println("Starting scmd web HTTP UI on port", HTTP)
-			util.OpenBrowser(fmt.Sprintf("http://%s:%v", util.GetOutboundIP(), HTTP))
-			err := http.ListenAndServe(fmt.Sprintf(":%v", HTTP), nil)
-			if err != nil {
-				log.Println(err)
-			}
+	var autoTLS *tls.Config
+	if tlsMode() == "auto" {
+		cfg, err := autoTLSConfig()
+		if err != nil {
+			log.Fatalf("Failed to prepare TLS certificate: %v", err)
 		}
-	} else {
-		go func() {
-			if SSL {
-				err := http.ListenAndServeTLS(fmt.Sprintf(":%v", HTTP), CRT, KEY, nil)
-				if err != nil {
-					log.Println(err)
-				}
-			} else {
-				err := http.ListenAndServe(fmt.Sprintf(":%v", HTTP), nil)
-				if err != nil {
-					log.Println(err)
-				}
-			}
-			wg.Done()
-		}()
+		autoTLS = cfg
+		SSL = true
+		CRT, KEY = "", ""
+	}
+
+	tlsActive = SSL
+	handler := instrument(securityHeaders(csrfProtect(http.DefaultServeMux)))
+
+	srv := newHTTPServer(listenAddr(HTTP), handler)
+	srv.TLSConfig = autoTLS
+	scheme := "HTTP"
+	if SSL {
+		scheme = "HTTPS"
 	}
 
-	wg.Wait()
+	err := serve(srv, SSL, CRT, KEY, func() {
+		logger.Info("starting web UI", "scheme", scheme, "addr", srv.Addr)
+		if browser {
+			if err := util.OpenBrowser(browserURL(srv.Addr, SSL)); err != nil {
+				logger.Warn("could not open the browser", "err", err)
+			}
+		}
+	})
+	if err != nil {
+		logger.Error("web server failed", "err", err)
+	}
+	stopCleanup()
+	stopPurge()
+	logger.Info("web server stopped")
 }
 
 func wrongSyntax() {
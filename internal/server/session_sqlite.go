@@ -0,0 +1,97 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+// SQLiteSessionStore keeps sessions in the SQLite database so that logins
+// survive restarts and are shared by every replica using the same file.
+type SQLiteSessionStore struct {
+	ttl     time.Duration
+	sliding bool
+}
+
+// NewSQLiteSessionStore creates a database-backed session store. The
+// database must already be initialised with database.InitDB.
+func NewSQLiteSessionStore(ttl time.Duration, sliding bool) *SQLiteSessionStore {
+	return &SQLiteSessionStore{ttl: ttl, sliding: sliding}
+}
+
+// CreateSession creates a new session for the user.
+func (s *SQLiteSessionStore) CreateSession(email string) (string, error) {
+	sessionID, err := generateSessionID()
+	if err != nil {
+		return "", err
+	}
+	csrf, err := generateSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	record := database.SessionRecord{
+		ID:        sessionID,
+		Email:     email,
+		CSRFToken: csrf,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	if err := database.SaveSession(record); err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// GetSession retrieves a session by ID.
+func (s *SQLiteSessionStore) GetSession(sessionID string) (*Session, bool) {
+	record, found, err := database.GetSession(sessionID)
+	if err != nil {
+		logger.Error("loading session", "err", err)
+		return nil, false
+	}
+	if !found {
+		return nil, false
+	}
+
+	now := time.Now()
+	if now.After(record.ExpiresAt) {
+		return nil, false
+	}
+
+	session := &Session{
+		Email:     record.Email,
+		CSRFToken: record.CSRFToken,
+		CreatedAt: record.CreatedAt,
+		ExpiresAt: record.ExpiresAt,
+	}
+
+	// Only write back when the expiry moves noticeably, so a burst of
+	// requests from one browser does not turn into a burst of UPDATEs.
+	if s.sliding {
+		next := now.Add(s.ttl)
+		if next.Sub(record.ExpiresAt) >= time.Minute {
+			if err := database.ExtendSession(sessionID, next); err != nil {
+				logger.Error("extending session", "err", err)
+			} else {
+				session.ExpiresAt = next
+			}
+		}
+	}
+	return session, true
+}
+
+// DeleteSession removes a session.
+func (s *SQLiteSessionStore) DeleteSession(sessionID string) {
+	if err := database.DeleteSession(sessionID); err != nil {
+		logger.Error("deleting session", "err", err)
+	}
+}
+
+// CleanupExpiredSessions removes expired sessions.
+func (s *SQLiteSessionStore) CleanupExpiredSessions() {
+	if _, err := database.DeleteExpiredSessions(time.Now()); err != nil {
+		logger.Error("cleaning up sessions", "err", err)
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/ai"
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/util"
+)
+
+// sseHeartbeat is how often a comment line is sent while waiting for the
+// model, so proxies do not close an idle stream.
+const sseHeartbeat = 15 * time.Second
+
+// sseWriter writes Server-Sent Events. It is safe for concurrent use so the
+// heartbeat and the token callback can share one response.
+type sseWriter struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+
+	rc := http.NewResponseController(w)
+	// Answers can take longer than the server write timeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		logger.Warn("clearing write deadline", "err", err)
+	}
+	w.WriteHeader(http.StatusOK)
+	return &sseWriter{w: w, rc: rc}
+}
+
+// event sends one event whose data is v encoded as JSON.
+func (s *sseWriter) event(name string, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, payload); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// comment sends an SSE comment line, which clients ignore.
+func (s *sseWriter) comment(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.w, ": %s\n\n", text); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// heartbeat sends a "ping" comment every interval until ctx is done or the
+// returned stop function is called. stop waits for the sender to exit, so
+// nothing is written to the response after the handler returns.
+func (s *sseWriter) heartbeat(ctx context.Context, interval time.Duration) (stop func()) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if s.comment("ping") != nil {
+					return
+				}
+			case <-quit:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+// askStreamAPI handles GET /api/v1/ask/stream?q=...[&persona=key] and
+// streams the search results and the AI answer as Server-Sent Events. With
+// a persona the AI is always asked through it:
+//
+//	records  JSON array of markdown result pages for the matched commands
+//	token    JSON string holding the next chunk of the AI answer
+//	done     {"tokens": n, "ai": bool} once the answer is complete
+//	failed   {"message": "..."} when the search or the AI provider fails
+//
+// Closing the connection cancels the provider request.
+func askStreamAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(query) < 3 {
+		http.Error(w, "Query must be at least 3 characters", http.StatusBadRequest)
+		return
+	}
+
+	var persona ai.AIPersona
+	if key := r.URL.Query().Get("persona"); key != "" {
+		var ok bool
+		if persona, ok = ai.LookupPersona(key); !ok {
+			http.Error(w, "Unknown persona", http.StatusBadRequest)
+			return
+		}
+	}
+
+	util.WriteLogToFile(util.WebLog(), "STREAM: "+query)
+
+	ctx := r.Context()
+	sse := newSSEWriter(w)
+
+	stopHeartbeat := sse.heartbeat(ctx, sseHeartbeat)
+	defer stopHeartbeat()
+
+	onRecords := func(records []database.CommandRecord) {
+		pages := make([]string, 0, len(records))
+		for _, record := range records {
+			pages = append(pages, recordPage(record))
+		}
+		sse.event("records", pages)
+	}
+	onToken := func(tok string) {
+		sse.event("token", tok)
+	}
+
+	var answer string
+	var tokens int
+	var err error
+	if persona.Key == "" {
+		_, answer, tokens, err = ai.SmartSearchStream(ctx, query, pinnedIDs(r), onRecords, onToken)
+	} else {
+		var records []database.CommandRecord
+		if records, err = ai.PersonaContext(persona, query, pinnedIDs(r)); err == nil {
+			onRecords(records)
+			answer, tokens, err = ai.AskAIPersonaStream(ctx, persona.Key, query, records, onToken)
+		}
+	}
+	if ctx.Err() != nil {
+		// Client went away or pressed cancel.
+		return
+	}
+	if err != nil {
+		logger.Error("streaming answer", "err", err)
+		sse.event("failed", map[string]string{"message": err.Error()})
+		return
+	}
+	sse.event("done", map[string]any{"tokens": tokens, "ai": answer != ""})
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSEWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sse := newSSEWriter(rec)
+	if err := sse.event("token", "hello\nworld"); err != nil {
+		t.Fatalf("event: %v", err)
+	}
+	if err := sse.comment("ping"); err != nil {
+		t.Fatalf("comment: %v", err)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	want := "event: token\ndata: \"hello\\nworld\"\n\n: ping\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestSSEWriter_HeartbeatStops(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sse := newSSEWriter(rec)
+	stop := sse.heartbeat(context.Background(), time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	stop()
+
+	sent := rec.Body.Len()
+	if sent == 0 {
+		t.Fatal("no heartbeat sent")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if rec.Body.Len() != sent {
+		t.Error("heartbeat written after stop returned")
+	}
+}
+
+func TestAskStreamAPI_RejectsShortQuery(t *testing.T) {
+	rec := httptest.NewRecorder()
+	askStreamAPI(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ask/stream?q=ls", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestAskStreamAPI_RejectsUnknownPersona(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	rec := httptest.NewRecorder()
+	askStreamAPI(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ask/stream?q=list+files&persona=nope", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/database"
+	"github.com/gcclinux/scmd/internal/util"
+)
+
+// registerTrashAPI registers the endpoints for deleted commands. Read-only
+// servers (-block) cannot delete, so they expose nothing here.
+func registerTrashAPI(mux *http.ServeMux, readOnly bool) {
+	if readOnly {
+		return
+	}
+	mux.HandleFunc("GET /api/v1/trash", listTrashAPI)
+	mux.HandleFunc("POST /api/v1/trash/{id}/restore", restoreCommandAPI)
+	mux.HandleFunc("DELETE /api/v1/trash", purgeTrashAPI)
+}
+
+// listTrashAPI returns the commands in the trash, most recently deleted
+// first.
+func listTrashAPI(w http.ResponseWriter, r *http.Request) {
+	records, err := database.ListTrash()
+	if err != nil {
+		logger.Error("listing trash", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if records == nil {
+		records = []database.CommandRecord{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"records":        records,
+		"retention_days": int(database.TrashRetention() / (24 * time.Hour)),
+	})
+}
+
+// restoreCommandAPI moves a command out of the trash and returns it.
+func restoreCommandAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	restored, err := database.RestoreCommand(id)
+	if errors.Is(err, database.ErrCommandExists) {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error("restoring command", "id", id, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to restore command")
+		return
+	}
+	if !restored {
+		writeJSONError(w, http.StatusNotFound, "command not in trash")
+		return
+	}
+	util.WriteLogToFile(util.WebLog(), "RESTORE: "+strconv.Itoa(id)+" "+r.RemoteAddr)
+
+	record, err := database.GetCommandByID(id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to reload command")
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// purgeTrashAPI permanently deletes everything in the trash.
+func purgeTrashAPI(w http.ResponseWriter, r *http.Request) {
+	n, err := database.PurgeTrash(0)
+	if err != nil {
+		logger.Error("purging trash", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to purge trash")
+		return
+	}
+	util.WriteLogToFile(util.WebLog(), "PURGE: "+strconv.Itoa(n)+" "+r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]int{"purged": n})
+}
+
+// StartTrashPurge starts a goroutine that applies trash_retention once an
+// hour, so long-running servers purge old deletes without a restart. The
+// returned function stops the goroutine.
+func StartTrashPurge() (stop func()) {
+	ticker := time.NewTicker(time.Hour)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if n, err := database.PurgeExpiredTrash(); err != nil {
+					logger.Error("purging trash", "err", err)
+				} else if n > 0 {
+					logger.Info("purged expired commands from trash", "count", n)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
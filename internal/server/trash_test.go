@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func TestTrashAPI(t *testing.T) {
+	setupTestDB(t)
+	database.AddCommand("ls -la", "list files", database.Origin{}, nil)
+	database.AddCommand("pwd", "print directory", database.Origin{}, nil)
+
+	mux := http.NewServeMux()
+	registerCommandAPI(mux, false)
+	registerTrashAPI(mux, false)
+
+	commandRequest(t, mux, "DELETE", "/api/v1/commands/1", "")
+	commandRequest(t, mux, "DELETE", "/api/v1/commands/2", "")
+
+	rec := commandRequest(t, mux, "GET", "/api/v1/trash", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"key":"ls -la"`) {
+		t.Fatalf("GET trash = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = commandRequest(t, mux, "POST", "/api/v1/trash/1/restore", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"id":1`) {
+		t.Errorf("restore = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec = commandRequest(t, mux, "POST", "/api/v1/trash/1/restore", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second restore = %d, want 404", rec.Code)
+	}
+	if rec = commandRequest(t, mux, "GET", "/api/v1/commands/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET restored = %d, want 200", rec.Code)
+	}
+
+	rec = commandRequest(t, mux, "DELETE", "/api/v1/trash", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"purged":1`) {
+		t.Errorf("purge = %d %s", rec.Code, rec.Body.String())
+	}
+
+	readOnly := http.NewServeMux()
+	registerTrashAPI(readOnly, true)
+	if rec = commandRequest(t, readOnly, "GET", "/api/v1/trash", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("read-only trash = %d, want 404", rec.Code)
+	}
+}
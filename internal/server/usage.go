@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/config"
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+// registerUsageAPI registers the usage tracking endpoints. Recording a view
+// or copy changes no command, so read-only servers (-block) keep them.
+func registerUsageAPI(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/commands/{id}/usage", recordUsageAPI)
+	mux.HandleFunc("GET /api/v1/stats/top", topCommandsAPI)
+}
+
+// usageEvent is the POST /api/v1/commands/{id}/usage request body.
+type usageEvent struct {
+	Event string `json:"event"`
+}
+
+// recordUsageAPI records that a command was viewed or copied in the browser.
+func recordUsageAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := commandID(w, r)
+	if !ok {
+		return
+	}
+	var req usageEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if req.Event != database.UsageView && req.Event != database.UsageCopy {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("event must be %q or %q", database.UsageView, database.UsageCopy))
+		return
+	}
+	if err := database.RecordUsage(id, req.Event); err != nil {
+		logger.Error("recording usage", "id", id, "event", req.Event, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to record usage")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// topCommandsAPI returns the most used commands. The optional limit
+// (default 10) and since (e.g. 7d) query parameters narrow the report.
+func topCommandsAPI(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > database.MaxPageSize {
+			writeJSONError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		period := config.ParseDuration(v, -1)
+		if period <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid since")
+			return
+		}
+		since = time.Now().Add(-period)
+	}
+
+	stats, err := database.TopCommands(limit, since)
+	if err != nil {
+		logger.Error("listing top commands", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"commands": stats})
+}
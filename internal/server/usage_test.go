@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gcclinux/scmd/internal/database"
+)
+
+func TestUsageAPI(t *testing.T) {
+	setupTestDB(t)
+	database.AddCommand("ls", "list", database.Origin{}, nil)
+	database.AddCommand("pwd", "print directory", database.Origin{}, nil)
+
+	mux := http.NewServeMux()
+	registerUsageAPI(mux)
+
+	for _, ev := range []string{"view", "copy", "copy"} {
+		if rec := commandRequest(t, mux, "POST", "/api/v1/commands/2/usage", `{"event":"`+ev+`"}`); rec.Code != http.StatusNoContent {
+			t.Fatalf("POST %s = %d %s", ev, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := commandRequest(t, mux, "POST", "/api/v1/commands/1/usage", `{"event":"view"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("POST view = %d", rec.Code)
+	}
+	if rec := commandRequest(t, mux, "POST", "/api/v1/commands/1/usage", `{"event":"exec"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("browser exec event = %d, want 400", rec.Code)
+	}
+
+	rec := commandRequest(t, mux, "GET", "/api/v1/stats/top?limit=1&since=7d", "")
+	var top struct {
+		Commands []database.UsageStat `json:"commands"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &top)
+	if rec.Code != http.StatusOK || len(top.Commands) != 1 {
+		t.Fatalf("top = %d %s", rec.Code, rec.Body.String())
+	}
+	if s := top.Commands[0]; s.CommandID != 2 || s.Views != 1 || s.Copies != 2 || s.Key != "pwd" {
+		t.Errorf("top command = %+v", s)
+	}
+	if rec := commandRequest(t, mux, "GET", "/api/v1/stats/top?since=soon", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad since = %d, want 400", rec.Code)
+	}
+}
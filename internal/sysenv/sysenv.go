@@ -0,0 +1,255 @@
+// Package sysenv detects the machine scmd runs on: the OS, the Linux
+// distribution, the package manager, the shell and which common tools are
+// installed. Detection runs once per process; the result is added to AI
+// prompts so answers fit the system, and is used to warn before /run
+// starts a command whose tool is missing.
+package sysenv
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/gcclinux/scmd/internal/config"
+)
+
+// Env describes the local system.
+type Env struct {
+	OS             string   // runtime.GOOS
+	Arch           string   // runtime.GOARCH
+	Shell          string   // base name of $SHELL, or of %ComSpec% on Windows
+	Distro         string   // PRETTY_NAME from /etc/os-release, e.g. "Ubuntu 24.04 LTS"
+	DistroID       string   // ID from /etc/os-release, e.g. "ubuntu"
+	PackageManager string   // the system package manager, e.g. "apt"
+	Tools          []string // installed tools out of knownTools
+	Detected       bool     // false when detection is disabled (system_context)
+}
+
+// packageManagers lists the package managers in the order they are
+// preferred when the distribution does not settle it.
+var packageManagers = []string{"apt", "dnf", "yum", "pacman", "zypper", "apk", "brew", "winget", "choco", "scoop"}
+
+// distroManagers maps /etc/os-release IDs (and ID_LIKE entries) to their
+// package manager.
+var distroManagers = map[string]string{
+	"debian": "apt", "ubuntu": "apt", "linuxmint": "apt", "pop": "apt", "raspbian": "apt",
+	"fedora": "dnf", "rhel": "dnf", "centos": "dnf", "rocky": "dnf", "almalinux": "dnf", "amzn": "dnf",
+	"arch": "pacman", "manjaro": "pacman", "endeavouros": "pacman",
+	"opensuse": "zypper", "opensuse-leap": "zypper", "opensuse-tumbleweed": "zypper", "sles": "zypper", "suse": "zypper",
+	"alpine": "apk",
+}
+
+// knownTools are looked up on the PATH. Package manager front-ends are
+// included so that CheckCommand can tell an apt command on Fedora apart.
+var knownTools = []string{
+	"apt", "apt-get", "dpkg", "dnf", "yum", "rpm", "pacman", "yay", "paru", "zypper", "apk",
+	"brew", "snap", "flatpak", "winget", "choco", "scoop",
+	"systemctl", "journalctl", "service", "ufw", "firewall-cmd", "iptables", "nft", "nmcli", "ip", "ss", "netstat",
+	"git", "docker", "podman", "kubectl", "helm", "terraform", "ansible", "vagrant",
+	"curl", "wget", "jq", "rsync", "tar", "zip", "unzip", "make", "gcc",
+	"python3", "pip3", "node", "npm", "go", "java", "cargo",
+	"psql", "mysql", "sqlite3", "redis-cli", "mongosh", "nginx",
+	"pwsh", "powershell",
+}
+
+// Seams for tests.
+var (
+	osReleasePath = "/etc/os-release"
+	lookPath      = exec.LookPath
+)
+
+var (
+	once    sync.Once
+	current Env
+)
+
+// Enabled reports whether system detection is on (system_context, default
+// true).
+func Enabled() bool {
+	return config.GetBool("SYSTEM_CONTEXT", true)
+}
+
+// Current returns the environment detected for this process, detecting it
+// on first use. With detection disabled only OS, Arch and Shell are set.
+func Current() Env {
+	once.Do(func() {
+		if Enabled() {
+			current = Detect()
+		} else {
+			current = basic()
+		}
+	})
+	return current
+}
+
+// Detect inspects the system. It never fails: anything that cannot be
+// determined is left empty.
+func Detect() Env {
+	env := basic()
+	env.Detected = true
+
+	release := readOSRelease(osReleasePath)
+	env.Distro = release["PRETTY_NAME"]
+	if env.Distro == "" {
+		env.Distro = strings.TrimSpace(release["NAME"] + " " + release["VERSION_ID"])
+	}
+	env.DistroID = release["ID"]
+
+	for _, tool := range knownTools {
+		if _, err := lookPath(tool); err == nil {
+			env.Tools = append(env.Tools, tool)
+		}
+	}
+	env.PackageManager = env.packageManager(strings.Fields(release["ID_LIKE"]))
+	return env
+}
+
+// basic returns what is known without probing the system.
+func basic() Env {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = os.Getenv("ComSpec")
+	}
+	if shell != "" {
+		shell = strings.TrimSuffix(filepath.Base(shell), ".exe")
+	}
+	return Env{OS: runtime.GOOS, Arch: runtime.GOARCH, Shell: shell}
+}
+
+// packageManager picks the distribution's package manager when it is
+// installed, otherwise the first installed one.
+func (e Env) packageManager(idLike []string) string {
+	for _, id := range append([]string{e.DistroID}, idLike...) {
+		if pm, ok := distroManagers[id]; ok && e.Has(pm) {
+			return pm
+		}
+	}
+	for _, pm := range packageManagers {
+		if e.Has(pm) {
+			return pm
+		}
+	}
+	return ""
+}
+
+// readOSRelease parses an os-release file into its KEY=value pairs.
+func readOSRelease(path string) map[string]string {
+	values := map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		values[key] = strings.Trim(value, `"'`)
+	}
+	return values
+}
+
+// Has reports whether tool was found on the PATH.
+func (e Env) Has(tool string) bool {
+	for _, t := range e.Tools {
+		if t == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary describes the system in one line, e.g. "Ubuntu 24.04 LTS
+// (linux/amd64), shell bash, package manager apt".
+func (e Env) Summary() string {
+	name := e.OS
+	if e.Distro != "" {
+		name = e.Distro
+	}
+	parts := []string{fmt.Sprintf("%s (%s/%s)", name, e.OS, e.Arch)}
+	if e.Shell != "" {
+		parts = append(parts, "shell "+e.Shell)
+	}
+	if e.PackageManager != "" {
+		parts = append(parts, "package manager "+e.PackageManager)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CheckCommand returns warnings for the commands in a shell command line
+// that use a known tool which is not installed, such as apt on Fedora. It
+// returns nothing when detection is disabled.
+func (e Env) CheckCommand(command string) []string {
+	if !e.Detected {
+		return nil
+	}
+	var warnings []string
+	seen := map[string]bool{}
+	for _, tool := range commandTools(command) {
+		if seen[tool] || !isKnown(tool) || e.Has(tool) {
+			continue
+		}
+		seen[tool] = true
+		msg := fmt.Sprintf("'%s' is not installed on this system", tool)
+		if isPackageManager(tool) && e.PackageManager != "" {
+			msg = fmt.Sprintf("'%s' is not available; %s uses %s", tool, e.displayName(), e.PackageManager)
+		}
+		warnings = append(warnings, msg)
+	}
+	return warnings
+}
+
+func (e Env) displayName() string {
+	if e.Distro != "" {
+		return e.Distro
+	}
+	return "this system"
+}
+
+// commandTools returns the program name of every simple command in a
+// shell command line, skipping sudo and leading variable assignments.
+func commandTools(command string) []string {
+	separators := strings.NewReplacer("&&", "\n", "||", "\n", ";", "\n", "|", "\n", "$(", "\n", "`", "\n")
+	var tools []string
+	for _, segment := range strings.Split(separators.Replace(command), "\n") {
+		for _, word := range strings.Fields(segment) {
+			if word == "sudo" || word == "env" || strings.HasPrefix(word, "-") || strings.Contains(word, "=") {
+				continue
+			}
+			tools = append(tools, filepath.Base(word))
+			break
+		}
+	}
+	return tools
+}
+
+func isKnown(tool string) bool {
+	for _, t := range knownTools {
+		if t == tool {
+			return true
+		}
+	}
+	return false
+}
+
+func isPackageManager(tool string) bool {
+	switch tool {
+	case "apt-get", "dpkg", "rpm", "yay", "paru":
+		return true
+	}
+	for _, pm := range packageManagers {
+		if pm == tool {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package sysenv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeSystem points detection at an os-release file with content and a
+// PATH holding only tools.
+func fakeSystem(t *testing.T, osRelease string, tools ...string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "os-release")
+	if err := os.WriteFile(path, []byte(osRelease), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prevPath, prevLook := osReleasePath, lookPath
+	osReleasePath = path
+	lookPath = func(name string) (string, error) {
+		for _, tool := range tools {
+			if tool == name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+	t.Cleanup(func() { osReleasePath, lookPath = prevPath, prevLook })
+}
+
+const fedora = `NAME="Fedora Linux"
+VERSION_ID=40
+ID=fedora
+# a comment
+PRETTY_NAME="Fedora Linux 40 (Workstation Edition)"
+`
+
+func TestDetect(t *testing.T) {
+	fakeSystem(t, fedora, "dnf", "rpm", "git", "podman", "brew")
+	t.Setenv("SHELL", "/usr/bin/zsh")
+
+	env := Detect()
+	if !env.Detected || env.Distro != "Fedora Linux 40 (Workstation Edition)" || env.DistroID != "fedora" || env.Shell != "zsh" {
+		t.Errorf("env = %+v", env)
+	}
+	if env.PackageManager != "dnf" {
+		t.Errorf("package manager = %q, want the distribution's dnf over brew", env.PackageManager)
+	}
+	if want := []string{"dnf", "rpm", "brew", "git", "podman"}; !reflect.DeepEqual(env.Tools, want) {
+		t.Errorf("tools = %v, want %v", env.Tools, want)
+	}
+	if got := env.Summary(); !strings.HasPrefix(got, "Fedora Linux 40 (Workstation Edition) (") || !strings.HasSuffix(got, ", shell zsh, package manager dnf") {
+		t.Errorf("summary = %q", got)
+	}
+}
+
+func TestDetect_IDLike(t *testing.T) {
+	fakeSystem(t, "ID=pika\nID_LIKE=\"ubuntu debian\"\nNAME=Pika\nVERSION_ID=4\n", "apt", "snap")
+	env := Detect()
+	if env.PackageManager != "apt" || env.Distro != "Pika 4" {
+		t.Errorf("env = %+v", env)
+	}
+}
+
+func TestDetect_NoOSRelease(t *testing.T) {
+	fakeSystem(t, "", "brew")
+	osReleasePath = filepath.Join(t.TempDir(), "missing")
+	if env := Detect(); env.Distro != "" || env.PackageManager != "brew" {
+		t.Errorf("env = %+v", env)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	t.Setenv("SYSTEM_CONTEXT", "")
+	if !Enabled() {
+		t.Error("detection should be on by default")
+	}
+	t.Setenv("SYSTEM_CONTEXT", "false")
+	if Enabled() {
+		t.Error("system_context false should turn detection off")
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	env := Env{Distro: "Fedora Linux 40", PackageManager: "dnf", Tools: []string{"dnf", "git"}, Detected: true}
+
+	got := env.CheckCommand("sudo apt-get update && DEBIAN_FRONTEND=noninteractive apt install -y docker.io | tee log; git status; docker ps")
+	want := []string{
+		"'apt-get' is not available; Fedora Linux 40 uses dnf",
+		"'apt' is not available; Fedora Linux 40 uses dnf",
+		"'docker' is not installed on this system",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("warnings = %q, want %q", got, want)
+	}
+
+	if got := env.CheckCommand("sudo dnf install -y htop && cd /tmp && ./build.sh"); got != nil {
+		t.Errorf("warnings = %q, want none for installed and unknown tools", got)
+	}
+	env.Detected = false
+	if got := env.CheckCommand("apt install docker"); got != nil {
+		t.Errorf("warnings = %q, want none with detection disabled", got)
+	}
+}
+
+func TestCommandTools(t *testing.T) {
+	got := commandTools("FOO=1 /usr/bin/curl -s x | jq . || echo $(uname -r)")
+	if want := []string{"curl", "jq", "echo", "uname"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tools = %v, want %v", got, want)
+	}
+}
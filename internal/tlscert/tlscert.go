@@ -0,0 +1,354 @@
+// Package tlscert generates and caches a private CA and a self-signed server
+// certificate for running the web interface over HTTPS without supplying
+// certificate files.
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/logging"
+)
+
+// File names inside the certificate directory.
+const (
+	CAFile         = "ca.pem"
+	CAKeyFile      = "ca-key.pem"
+	ServerFile     = "server.pem"
+	ServerKeyFile  = "server-key.pem"
+	caValidity     = 10 * 365 * 24 * time.Hour
+	serverValidity = 365 * 24 * time.Hour
+)
+
+// RenewBefore is how long before expiry a certificate is regenerated.
+var RenewBefore = 30 * 24 * time.Hour
+
+// Bundle describes the certificate files currently on disk.
+type Bundle struct {
+	CertFile      string
+	KeyFile       string
+	CAFile        string
+	Fingerprint   string // SHA-256 of the server certificate
+	CAFingerprint string // SHA-256 of the CA certificate
+	NotAfter      time.Time
+	Hosts         []string
+	Renewed       bool // true when Ensure generated a new server certificate
+}
+
+// Ensure makes sure dir holds a CA and a server certificate valid for all of
+// hosts, generating or renewing them as needed. Certificates are renewed when
+// they expire within RenewBefore, when the server certificate does not cover
+// every host, or when it was not issued by the cached CA.
+func Ensure(dir string, hosts []string) (*Bundle, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating %s: %v", dir, err)
+	}
+
+	caCert, caKey, err := loadOrCreateCA(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{
+		CertFile:      filepath.Join(dir, ServerFile),
+		KeyFile:       filepath.Join(dir, ServerKeyFile),
+		CAFile:        filepath.Join(dir, CAFile),
+		CAFingerprint: Fingerprint(caCert.Raw),
+		Hosts:         hosts,
+	}
+
+	leaf, err := loadCert(b.CertFile)
+	if err != nil || needsRenewal(leaf, caCert, hosts) {
+		leaf, err = createServerCert(dir, caCert, caKey, hosts)
+		if err != nil {
+			return nil, err
+		}
+		b.Renewed = true
+	}
+
+	b.Fingerprint = Fingerprint(leaf.Raw)
+	b.NotAfter = leaf.NotAfter
+	return b, nil
+}
+
+// DefaultHosts returns the names the server certificate should cover:
+// localhost, the loopback addresses, the machine's hostname and extra.
+func DefaultHosts(extra ...string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+	for _, h := range extra {
+		if h != "" && !slices.Contains(hosts, h) {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// Fingerprint returns the colon-separated SHA-256 fingerprint of a DER
+// certificate, in the format shown by browsers and openssl.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// Manager serves the cached certificate through tls.Config.GetCertificate
+// and renews it in place when it approaches expiry, so long-running servers
+// never present an expired certificate.
+type Manager struct {
+	dir   string
+	hosts []string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	bundle  *Bundle
+	checked time.Time
+}
+
+// NewManager prepares the certificates in dir and loads them.
+func NewManager(dir string, hosts []string) (*Manager, error) {
+	m := &Manager{dir: dir, hosts: hosts}
+	if err := m.reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Bundle returns the certificate details loaded most recently.
+func (m *Manager) Bundle() *Bundle {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bundle
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (m *Manager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.Lock()
+	stale := time.Since(m.checked) > time.Hour
+	m.mu.Unlock()
+
+	if stale {
+		if err := m.reload(); err != nil {
+			// Keep serving the current certificate; it is still valid for
+			// at least RenewBefore when this path is taken.
+			logging.For(logging.Web).Warn("reloading TLS certificate failed, serving the cached one",
+				"dir", m.dir, "err", err)
+			m.mu.Lock()
+			m.checked = time.Now()
+			m.mu.Unlock()
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cert, nil
+}
+
+// TLSConfig returns a server TLS configuration backed by the manager.
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: m.GetCertificate,
+	}
+}
+
+func (m *Manager) reload() error {
+	b, err := Ensure(m.dir, m.hosts)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(b.CertFile, b.KeyFile)
+	if err != nil {
+		return fmt.Errorf("error loading certificate: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cert = &cert
+	m.bundle = b
+	m.checked = time.Now()
+	return nil
+}
+
+func needsRenewal(leaf, ca *x509.Certificate, hosts []string) bool {
+	if time.Until(leaf.NotAfter) < RenewBefore {
+		return true
+	}
+	if leaf.CheckSignatureFrom(ca) != nil {
+		return true
+	}
+	for _, h := range hosts {
+		if leaf.VerifyHostname(h) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath := filepath.Join(dir, CAFile)
+	keyPath := filepath.Join(dir, CAKeyFile)
+
+	cert, err := loadCert(certPath)
+	if err == nil && time.Until(cert.NotAfter) >= RenewBefore {
+		if key, err := loadKey(keyPath); err == nil {
+			return cert, key, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating CA key: %v", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"scmd"}, CommonName: "scmd local CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating CA certificate: %v", err)
+	}
+	if err := writeCert(certPath, der); err != nil {
+		return nil, nil, err
+	}
+	if err := writeKey(keyPath, key); err != nil {
+		return nil, nil, err
+	}
+
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func createServerCert(dir string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, hosts []string) (*x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating server key: %v", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"scmd"}, CommonName: "scmd web"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(serverValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("error creating server certificate: %v", err)
+	}
+
+	// Write the chain (leaf followed by CA) so clients that trust the CA can
+	// verify the server without extra configuration.
+	chain := append(pemBlock("CERTIFICATE", der), pemBlock("CERTIFICATE", ca.Raw)...)
+	if err := os.WriteFile(filepath.Join(dir, ServerFile), chain, 0644); err != nil {
+		return nil, fmt.Errorf("error writing server certificate: %v", err)
+	}
+	if err := writeKey(filepath.Join(dir, ServerKeyFile), key); err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("error generating serial number: %v", err)
+	}
+	return serial, nil
+}
+
+// loadCert reads the first certificate in a PEM file.
+func loadCert(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func loadKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no key found in %s", path)
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+func writeCert(path string, der []byte) error {
+	if err := os.WriteFile(path, pemBlock("CERTIFICATE", der), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	return nil
+}
+
+func writeKey(path string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("error encoding key: %v", err)
+	}
+	if err := os.WriteFile(path, pemBlock("EC PRIVATE KEY", der), 0600); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	return nil
+}
+
+func pemBlock(typ string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
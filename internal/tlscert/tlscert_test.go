@@ -0,0 +1,156 @@
+package tlscert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gcclinux/scmd/internal/logging"
+)
+
+func TestEnsure_GeneratesAndCaches(t *testing.T) {
+	dir := t.TempDir()
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+
+	b, err := Ensure(dir, hosts)
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	if !b.Renewed {
+		t.Error("first Ensure should generate a certificate")
+	}
+	if info, err := os.Stat(filepath.Join(dir, ServerKeyFile)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("server key permissions = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	again, err := Ensure(dir, hosts)
+	if err != nil {
+		t.Fatalf("second Ensure: %v", err)
+	}
+	if again.Renewed {
+		t.Error("second Ensure should reuse the cached certificate")
+	}
+	if again.Fingerprint != b.Fingerprint {
+		t.Errorf("fingerprint changed: %s != %s", again.Fingerprint, b.Fingerprint)
+	}
+}
+
+func TestEnsure_VerifiesAgainstCA(t *testing.T) {
+	dir := t.TempDir()
+	b, err := Ensure(dir, []string{"localhost", "10.1.2.3"})
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(b.CertFile, b.KeyFile)
+	if err != nil {
+		t.Fatalf("LoadX509KeyPair: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatalf("parse leaf: %v", err)
+	}
+	caCert, err := loadCert(b.CAFile)
+	if err != nil {
+		t.Fatalf("load CA: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	for _, host := range []string{"localhost", "10.1.2.3"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{Roots: pool, DNSName: host}); err != nil {
+			t.Errorf("verify %s: %v", host, err)
+		}
+	}
+}
+
+func TestEnsure_RenewsOnNewHost(t *testing.T) {
+	dir := t.TempDir()
+	first, err := Ensure(dir, []string{"localhost"})
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	second, err := Ensure(dir, []string{"localhost", "192.168.1.50"})
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	if !second.Renewed || second.Fingerprint == first.Fingerprint {
+		t.Error("adding a host should renew the certificate")
+	}
+	if second.CAFingerprint != first.CAFingerprint {
+		t.Error("CA should be reused when it is still valid")
+	}
+}
+
+func TestEnsure_RenewsBeforeExpiry(t *testing.T) {
+	dir := t.TempDir()
+	first, err := Ensure(dir, []string{"localhost"})
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+
+	prev := RenewBefore
+	RenewBefore = serverValidity + time.Hour
+	defer func() { RenewBefore = prev }()
+
+	second, err := Ensure(dir, []string{"localhost"})
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	if !second.Renewed || second.Fingerprint == first.Fingerprint {
+		t.Error("certificate inside the renewal window should be renewed")
+	}
+}
+
+func TestGetCertificate_LogsFailedReload(t *testing.T) {
+	m, err := NewManager(t.TempDir(), []string{"localhost"})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	cached := m.cert
+
+	// A directory below a regular file can never be created.
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.dir = filepath.Join(blocker, "tls")
+	m.checked = time.Now().Add(-2 * time.Hour)
+
+	var out bytes.Buffer
+	logging.Setup(logging.Options{Output: &out})
+	defer logging.Setup(logging.Options{})
+
+	cert, err := m.GetCertificate(nil)
+	if err != nil || cert != cached {
+		t.Errorf("GetCertificate = %v, %v; want the cached certificate", cert, err)
+	}
+	if got := out.String(); !strings.Contains(got, "level=WARN") || !strings.Contains(got, "reloading TLS certificate failed") {
+		t.Errorf("log = %q, want a warning about the failed reload", got)
+	}
+}
+
+func TestDefaultHosts(t *testing.T) {
+	hosts := DefaultHosts("10.0.0.5", "localhost", "")
+	want := map[string]bool{"localhost": false, "127.0.0.1": false, "::1": false, "10.0.0.5": false}
+	seen := map[string]int{}
+	for _, h := range hosts {
+		seen[h]++
+		if _, ok := want[h]; ok {
+			want[h] = true
+		}
+	}
+	for h, ok := range want {
+		if !ok {
+			t.Errorf("DefaultHosts missing %s", h)
+		}
+	}
+	if seen["localhost"] != 1 {
+		t.Errorf("localhost listed %d times", seen["localhost"])
+	}
+}
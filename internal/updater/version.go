@@ -4,11 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/gcclinux/scmd/internal/logging"
 )
 
 // Release is the current application version.
@@ -30,7 +31,7 @@ func VersionRemote() (msg string, remote string, upgrade bool) {
 
 	lines, err := urlToLines("https://raw.githubusercontent.com/gcclinux/scmd/main/release")
 	if err != nil {
-		log.Println(err)
+		logging.For(logging.CLI).Debug("checking remote version", "err", err)
 	}
 
 	for _, line := range lines {
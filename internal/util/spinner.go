@@ -9,6 +9,7 @@ import (
 var (
 	spinnerMu   sync.Mutex
 	spinnerStop chan struct{}
+	spinnerDone chan struct{}
 	isSpinning  bool
 )
 
@@ -21,8 +22,10 @@ func StartSpinner() {
 	}
 	isSpinning = true
 	spinnerStop = make(chan struct{})
+	spinnerDone = make(chan struct{})
 
 	go func() {
+		defer close(spinnerDone)
 		state := 0
 		for {
 			select {
@@ -58,7 +61,8 @@ func StartSpinner() {
 	}()
 }
 
-// StopSpinner stops the animated loading indicator.
+// StopSpinner stops the animated loading indicator and waits until its line
+// has been cleared, so output printed afterwards is not erased.
 func StopSpinner() {
 	spinnerMu.Lock()
 	defer spinnerMu.Unlock()
@@ -66,5 +70,6 @@ func StopSpinner() {
 		return
 	}
 	close(spinnerStop)
+	<-spinnerDone
 	isSpinning = false
 }
@@ -2,16 +2,24 @@ package util
 
 import (
 	"fmt"
-	"log"
 	"net"
 	"os"
 	"os/exec"
 	"runtime"
 	"strconv"
 	"strings"
+
+	"github.com/gcclinux/scmd/internal/logging"
 )
 
-const WebLog = "scmdweb.log"
+// WebLog returns the path of the web audit log: web_log from the config,
+// or scmdweb.log in the working directory.
+func WebLog() string {
+	if path := os.Getenv("WEB_LOG"); path != "" {
+		return path
+	}
+	return "scmdweb.log"
+}
 
 // IsSnap returns true when the process is running inside a snap package.
 // snapd always sets the SNAP environment variable for confined applications.
@@ -101,31 +109,30 @@ func ReplaceLast(x, y, z string) string {
 	return x[:i] + z + x[i+len(y):]
 }
 
-// WriteLogToFile writes a message to a log file.
+// WriteLogToFile writes a message to a log file. The file is opened once and
+// rotated according to web_log_max_size, web_log_max_age and
+// web_log_max_backups; if it cannot be opened the message is dropped and a
+// warning is logged instead of exiting.
 func WriteLogToFile(logFile, message string) {
-	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		log.Fatal(err)
+	if logger := logging.FileLogger(logFile); logger != nil {
+		logger.Info(message)
 	}
-	defer file.Close()
-
-	logger := log.New(file, "", log.LstdFlags)
-	logger.Println(": " + message)
 }
 
-// GetOutboundIP returns the local outbound IP address.
-func GetOutboundIP() net.IP {
+// OutboundIP returns the preferred outbound IP of this machine, or an error
+// when no route is available (for example on an offline host).
+func OutboundIP() (net.IP, error) {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer conn.Close()
 	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	return localAddr.IP
+	return localAddr.IP, nil
 }
 
 // OpenBrowser launches the default browser depending on the OS.
-func OpenBrowser(url string) {
+func OpenBrowser(url string) error {
 	var err error
 	switch runtime.GOOS {
 	case "linux":
@@ -135,11 +142,12 @@ func OpenBrowser(url string) {
 	case "darwin":
 		err = exec.Command("open", url).Start()
 	default:
-		err = fmt.Errorf("unsupported platform")
+		err = fmt.Errorf("unsupported platform %s", runtime.GOOS)
 	}
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("cannot open browser: %v", err)
 	}
+	return nil
 }
 
 // CopyDB exports all commands from PostgreSQL to a JSON file.